@@ -9,10 +9,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/db"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/logger"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
 )
 
 func main() {
@@ -31,7 +35,41 @@ func main() {
 	}
 	defer sqlDB.Close()
 
-	r := router.New(sqlDB, log, cfg)
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Sugar().Fatalf("storage init failed: %v", err)
+	}
+
+	if cfg.OIDCDefaultRole != "" {
+		if _, ok := rbac.ParseRole(cfg.OIDCDefaultRole); !ok {
+			log.Sugar().Fatalf("OIDC_DEFAULT_ROLE %q is not a defined role", cfg.OIDCDefaultRole)
+		}
+	}
+	var authn *auth.Verifier
+	if cfg.AuthDisabled {
+		log.Sugar().Warn("authentication is disabled (AUTH_DISABLED=true)")
+	} else if authn, err = auth.New(cfg); err != nil {
+		log.Sugar().Fatalf("auth init failed: %v", err)
+	}
+
+	trail := audit.NewRecorder(sqlDB, log, cfg.AuditQueueSize)
+	r, err := router.New(sqlDB, log, cfg, store, authn, trail)
+	if err != nil {
+		log.Sugar().Fatalf("router init failed: %v", err)
+	}
+
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if cfg.AuditSigningKeyFile == "" {
+		log.Sugar().Warn("audit checkpoints are disabled (AUDIT_SIGNING_KEY_FILE not set)")
+	} else {
+		key, err := audit.LoadSigningKey(cfg.AuditSigningKeyFile)
+		if err != nil {
+			log.Sugar().Fatalf("audit signing key: %v", err)
+		}
+		cp := &audit.Checkpointer{DB: sqlDB, Log: log, Key: key}
+		go cp.Run(bgCtx, cfg.AuditCheckpointInterval)
+	}
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Port),
@@ -51,11 +89,14 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Sugar().Info("shutting down server...")
+	stopBackground()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Sugar().Fatal("server forced to shutdown:", err)
 	}
+	// Requests have finished; write the audit entries still queued.
+	trail.Close()
 	log.Sugar().Info("server exiting")
 }
@@ -0,0 +1,62 @@
+// Command auditverify walks the audit log hash chain and its signed
+// checkpoints and reports the first break.
+//
+//	auditverify [-dsn DSN] [-public-key FILE] [-batch N]
+//
+// It exits 0 when the log is intact, 1 at the first break and 2 on error.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/db"
+)
+
+func main() {
+	godotenv.Load() // ignore error (optional .env)
+
+	keyDefault := os.Getenv("AUDIT_PUBLIC_KEY_FILE")
+	if keyDefault == "" {
+		keyDefault = os.Getenv("AUDIT_SIGNING_KEY_FILE")
+	}
+	dsn := flag.String("dsn", os.Getenv("DB_DSN"), "database DSN")
+	keyFile := flag.String("public-key", keyDefault, "Ed25519 public (or private) key PEM that signed the checkpoints")
+	batch := flag.Int("batch", 1000, "entries read per query")
+	flag.Parse()
+
+	if *dsn == "" || *keyFile == "" {
+		fmt.Fprintln(os.Stderr, "auditverify: -dsn and -public-key are required")
+		os.Exit(2)
+	}
+	pub, err := audit.LoadPublicKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auditverify: %v\n", err)
+		os.Exit(2)
+	}
+	sqlDB, err := db.Open(*dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auditverify: %v\n", err)
+		os.Exit(2)
+	}
+	defer sqlDB.Close()
+
+	rep, err := audit.Verify(context.Background(), sqlDB, pub, *batch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auditverify: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Printf("checked %d chained entries (%d unchained), %d checkpoints verified, %d signed by other keys\n",
+		rep.Entries, rep.Unchained, rep.Checkpoints, rep.OtherKeys)
+	if rep.Break != nil {
+		fmt.Printf("BROKEN at %s\n", rep.Break)
+		sqlDB.Close()
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
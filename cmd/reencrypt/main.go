@@ -0,0 +1,61 @@
+// Command reencrypt moves PHI columns under the current field-encryption
+// key: plaintext rows are encrypted and cleared, and values sealed with
+// older keys, or not yet bound to their row, are re-sealed. Run it after enabling field encryption and
+// after every key rotation; retire an old key only once a run reports
+// nothing left to do.
+//
+//	reencrypt [-batch N] [-dry-run] [-decrypt]
+//
+// Keys and the database come from the same environment as the API.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/db"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
+)
+
+func main() {
+	batch := flag.Int("batch", 500, "rows rewritten per transaction")
+	dryRun := flag.Bool("dry-run", false, "count the rows that would change without writing")
+	decrypt := flag.Bool("decrypt", false, "move values back to the plaintext columns")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail(err)
+	}
+	ring, err := fieldcrypt.FromConfig(cfg)
+	if err != nil {
+		fail(err)
+	}
+	if ring == nil {
+		fail(fmt.Errorf("FIELD_ENCRYPTION_KEYS is not set"))
+	}
+	sqlDB, err := db.Open(cfg.DB_DSN)
+	if err != nil {
+		fail(err)
+	}
+	defer sqlDB.Close()
+
+	res, err := fieldcrypt.Reencrypt(context.Background(), sqlDB, ring, fieldcrypt.Options{Batch: *batch, DryRun: *dryRun, Decrypt: *decrypt})
+	verb := "rewrote"
+	if *dryRun {
+		verb = "would rewrite"
+	}
+	fmt.Printf("%s %d patients and %d assessments (current key %s)\n", verb, res.Patients, res.Assessments, ring.CurrentKey())
+	if err != nil {
+		sqlDB.Close()
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "reencrypt: %v\n", err)
+	os.Exit(1)
+}
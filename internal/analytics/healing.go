@@ -0,0 +1,158 @@
+// Package analytics derives healing trajectories from wound measurement
+// history. Everything here is pure computation over the measurements passed
+// in, so every number returned can be reproduced by hand.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// NonHealingThreshold is the minimum percent area reduction expected at week
+// 4; wounds below it are unlikely to heal by week 12 with standard care.
+const NonHealingThreshold = 40.0
+
+// The week-4 checkpoint accepts the measurement closest to day 28 within
+// this window, since visits rarely land exactly four weeks apart.
+const (
+	week4Target      = 28 * 24 * time.Hour
+	week4WindowStart = 21 * 24 * time.Hour
+	week4WindowEnd   = 35 * 24 * time.Hour
+)
+
+// Trajectory statuses.
+const (
+	StatusOnTrack      = "on_track"      // week-4 PAR at or above the threshold
+	StatusNonHealing   = "non_healing"   // week-4 PAR below the threshold
+	StatusPending      = "pending"       // week-4 window not reached yet
+	StatusNotEvaluable = "not_evaluable" // window passed without a measurement, or zero baseline
+)
+
+var ErrNoMeasurements = errors.New("analytics: no measurements")
+
+// Point is a single area measurement.
+type Point struct {
+	AssessmentID int64     `json:"assessment_id"`
+	MeasuredAt   time.Time `json:"measured_at"`
+	AreaCm2      float64   `json:"area_cm2"`
+}
+
+// TrajectoryPoint is a measurement with its reduction relative to baseline.
+type TrajectoryPoint struct {
+	Point
+	DaysFromBaseline     float64  `json:"days_from_baseline"`
+	PercentAreaReduction *float64 `json:"percent_area_reduction,omitempty"`
+}
+
+// Trajectory summarises healing from the first (baseline) measurement.
+type Trajectory struct {
+	Baseline Point `json:"baseline"`
+	Latest   Point `json:"latest"`
+
+	WeeksElapsed         float64  `json:"weeks_elapsed"`
+	PercentAreaReduction *float64 `json:"percent_area_reduction,omitempty"`
+	// WeeklyHealingRateCm2 is the mean area closed per week; negative when
+	// the wound is growing.
+	WeeklyHealingRateCm2 *float64 `json:"weekly_healing_rate_cm2,omitempty"`
+	// WeeklyPercentReduction is PercentAreaReduction spread evenly per week.
+	WeeklyPercentReduction *float64 `json:"weekly_percent_reduction,omitempty"`
+
+	Week4  *TrajectoryPoint `json:"week4,omitempty"`
+	Status string           `json:"status"`
+	Reason string           `json:"reason"`
+
+	Points []TrajectoryPoint `json:"points"`
+}
+
+// PercentAreaReduction is (baseline - current) / baseline * 100. It returns
+// false when the baseline area is zero.
+func PercentAreaReduction(baselineCm2, currentCm2 float64) (float64, bool) {
+	if baselineCm2 <= 0 {
+		return 0, false
+	}
+	return round2((baselineCm2 - currentCm2) / baselineCm2 * 100), true
+}
+
+// Analyze computes the healing trajectory of a wound. Points need not be
+// sorted; the earliest one is the baseline.
+func Analyze(points []Point, now time.Time) (Trajectory, error) {
+	if len(points) == 0 {
+		return Trajectory{}, ErrNoMeasurements
+	}
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].MeasuredAt.Before(sorted[j].MeasuredAt) })
+
+	base := sorted[0]
+	latest := sorted[len(sorted)-1]
+	t := Trajectory{
+		Baseline: base,
+		Latest:   latest,
+		Points:   make([]TrajectoryPoint, 0, len(sorted)),
+	}
+
+	var week4 *TrajectoryPoint
+	for _, p := range sorted {
+		elapsed := p.MeasuredAt.Sub(base.MeasuredAt)
+		tp := TrajectoryPoint{Point: p, DaysFromBaseline: round2(elapsed.Hours() / 24)}
+		if par, ok := PercentAreaReduction(base.AreaCm2, p.AreaCm2); ok {
+			tp.PercentAreaReduction = &par
+		}
+		t.Points = append(t.Points, tp)
+
+		if elapsed >= week4WindowStart && elapsed <= week4WindowEnd {
+			if week4 == nil || absDuration(elapsed-week4Target) < absDuration(week4.MeasuredAt.Sub(base.MeasuredAt)-week4Target) {
+				cp := tp
+				week4 = &cp
+			}
+		}
+	}
+	t.Week4 = week4
+
+	weeks := latest.MeasuredAt.Sub(base.MeasuredAt).Hours() / (24 * 7)
+	t.WeeksElapsed = round2(weeks)
+	if par, ok := PercentAreaReduction(base.AreaCm2, latest.AreaCm2); ok {
+		t.PercentAreaReduction = &par
+		if weeks > 0 {
+			rate := round2((base.AreaCm2 - latest.AreaCm2) / weeks)
+			pct := round2(par / weeks)
+			t.WeeklyHealingRateCm2 = &rate
+			t.WeeklyPercentReduction = &pct
+		}
+	}
+
+	switch {
+	case base.AreaCm2 <= 0:
+		t.Status = StatusNotEvaluable
+		t.Reason = "baseline area is zero, percent area reduction is undefined"
+	case week4 != nil && *week4.PercentAreaReduction < NonHealingThreshold:
+		t.Status = StatusNonHealing
+		t.Reason = fmt.Sprintf("area reduced %.2f%% at day %.0f, below the %.0f%% expected at week 4",
+			*week4.PercentAreaReduction, week4.DaysFromBaseline, NonHealingThreshold)
+	case week4 != nil:
+		t.Status = StatusOnTrack
+		t.Reason = fmt.Sprintf("area reduced %.2f%% at day %.0f, meeting the %.0f%% expected at week 4",
+			*week4.PercentAreaReduction, week4.DaysFromBaseline, NonHealingThreshold)
+	case now.Sub(base.MeasuredAt) <= week4WindowEnd:
+		t.Status = StatusPending
+		t.Reason = "no measurement in the week-4 window (days 21-35) yet"
+	default:
+		t.Status = StatusNotEvaluable
+		t.Reason = "no measurement was taken in the week-4 window (days 21-35)"
+	}
+	return t, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,125 @@
+// Package apikey issues and checks API keys for machine clients such as the
+// EHR interface engine. Keys look like wiq_<prefix>_<secret>; only the
+// prefix (for lookup) and a SHA-256 hash of the whole key are stored.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+)
+
+const keyTag = "wiq_"
+
+// Header carries the API key on requests.
+const Header = "X-API-Key"
+
+// Resources that scopes can name. A scope is "<resource>:read" or
+// "<resource>:write"; write does not imply read.
+var Resources = []string{
+	"patients", "clinicians", "assessments", "wounds", "images", "treatments",
+	"care_plans", "appointments", "braden", "reports",
+}
+
+// Key is a freshly generated key. Plaintext is shown to the caller once and
+// never stored.
+type Key struct {
+	Plaintext string
+	Prefix    string
+	Hash      string
+}
+
+// Generate returns a new random key.
+func Generate() (Key, error) {
+	b := make([]byte, 4+32)
+	if _, err := rand.Read(b); err != nil {
+		return Key{}, err
+	}
+	prefix := hex.EncodeToString(b[:4])
+	plain := keyTag + prefix + "_" + hex.EncodeToString(b[4:])
+	return Key{Plaintext: plain, Prefix: prefix, Hash: Hash(plain)}, nil
+}
+
+// Split returns the lookup prefix of a presented key.
+func Split(key string) (prefix string, ok bool) {
+	rest, ok := strings.CutPrefix(key, keyTag)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != 8 || len(secret) != 64 {
+		return "", false
+	}
+	return prefix, true
+}
+
+// Hash is the stored form of a key. Keys carry 256 bits of entropy, so a
+// plain SHA-256 is sufficient.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches compares key against a stored hash in constant time.
+func Matches(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(key)), []byte(hash)) == 1
+}
+
+// ValidScope reports whether s names a known resource and access level.
+func ValidScope(s string) bool {
+	resource, level, ok := strings.Cut(s, ":")
+	if !ok || (level != "read" && level != "write") {
+		return false
+	}
+	for _, r := range Resources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether scopes grant access to resource; write selects the
+// ":write" scope rather than ":read".
+func Allows(scopes []string, resource string, write bool) bool {
+	want := resource + ":read"
+	if write {
+		want = resource + ":write"
+	}
+	for _, s := range scopes {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope guards a route group. Requests authenticated with an API key
+// need the group's read scope for GET/HEAD and its write scope otherwise;
+// other principals pass through to role checks.
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := auth.PrincipalFrom(c)
+		if !ok || p.APIKeyID == 0 {
+			c.Next()
+			return
+		}
+		write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+		if !Allows(p.Scopes, resource, write) {
+			need := resource + ":read"
+			if write {
+				need = resource + ":write"
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + need})
+			return
+		}
+		c.Next()
+	}
+}
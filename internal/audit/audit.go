@@ -0,0 +1,240 @@
+// Package audit keeps the PHI access trail: one append-only audit_log row
+// per request to an audited route group, naming who did what to which
+// record and whose record it was. Entries are hash-chained and the chain
+// head is periodically signed, so edits to the table can be detected with
+// Verify.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/requestid"
+)
+
+// Actions recorded in audit_log.action.
+const (
+	Read   = "read"
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+const (
+	resourceKey = "audit.resource_id"
+	patientKey  = "audit.patient_id"
+)
+
+// maxBatch caps how many queued entries the writer appends in one
+// transaction.
+const maxBatch = 100
+
+var errQueueFull = errors.New("audit queue is full")
+
+// Recorder writes audit entries. With a queue, entries from Middleware are
+// appended by a single writer goroutine, so requests never wait on the
+// chain lock; Close drains the queue.
+type Recorder struct {
+	DB  *sql.DB
+	Log *zap.Logger
+
+	queue chan models.AuditEntry
+	done  chan struct{}
+}
+
+// NewRecorder returns a Recorder whose Middleware queues up to queueSize
+// entries for a background writer. A queueSize of 0 writes each entry
+// before the request finishes.
+func NewRecorder(db *sql.DB, log *zap.Logger, queueSize int) *Recorder {
+	r := &Recorder{DB: db, Log: log}
+	if queueSize > 0 {
+		r.queue = make(chan models.AuditEntry, queueSize)
+		r.done = make(chan struct{})
+		go r.run()
+	}
+	return r
+}
+
+// Record appends e to the audit log, chained to the previous entry. ID and
+// OccurredAt are assigned here.
+func (r *Recorder) Record(ctx context.Context, e models.AuditEntry) error {
+	return appendChained(ctx, r.DB, e)
+}
+
+// Close stops accepting queued entries and waits until those already queued
+// are written. Call it once the server has stopped handling requests.
+func (r *Recorder) Close() {
+	if r.queue == nil {
+		return
+	}
+	close(r.queue)
+	<-r.done
+}
+
+// run appends queued entries, taking whatever has built up since the last
+// append as one batch.
+func (r *Recorder) run() {
+	defer close(r.done)
+	for e := range r.queue {
+		batch := []models.AuditEntry{e}
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case e, ok := <-r.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+		if err := appendChained(context.Background(), r.DB, batch...); err != nil {
+			for _, e := range batch {
+				r.dropped(e, err)
+			}
+		}
+	}
+}
+
+func (r *Recorder) submit(ctx context.Context, e models.AuditEntry) {
+	if r.queue == nil {
+		if err := r.Record(ctx, e); err != nil {
+			r.dropped(e, err)
+		}
+		return
+	}
+	select {
+	case r.queue <- e:
+	default:
+		r.dropped(e, errQueueFull)
+	}
+}
+
+// dropped logs an entry that did not reach audit_log, with enough of it to
+// reconstruct the access from the logs.
+func (r *Recorder) dropped(e models.AuditEntry, err error) {
+	fields := []zap.Field{
+		zap.Error(err),
+		zap.String("actor", e.Actor),
+		zap.String("action", e.Action),
+		zap.String("resource_type", e.ResourceType),
+		zap.String("resource_id", e.ResourceID),
+		zap.String("route", e.Route),
+		zap.Int("status", e.Status),
+		zap.String("request_id", e.RequestID),
+	}
+	if e.PatientID != nil {
+		fields = append(fields, zap.Int64("patient_id", *e.PatientID))
+	}
+	r.Log.Error("audit log write failed", fields...)
+}
+
+// Middleware records every request to the route group once the handler has
+// finished, including refused and failed ones. The resource is taken from
+// the route's first path segment and :id; the patient from a /patients/:id
+// route or a patient_id query parameter. Handlers fill in what the route
+// cannot tell with SetResource and SetPatient.
+//
+// Auditing fails open: the response has already been written when the entry
+// is recorded, so an entry that cannot be written, or that finds the queue
+// full, is logged at error level and dropped rather than reported to the
+// caller.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		e := models.AuditEntry{
+			Actor:        "anonymous",
+			Action:       action(c.Request.Method),
+			ResourceType: resourceType(route),
+			ResourceID:   c.Param("id"),
+			Route:        c.Request.Method + " " + route,
+			Status:       c.Writer.Status(),
+			RequestID:    requestid.Get(c),
+			ClientIP:     c.ClientIP(),
+		}
+		if p, ok := auth.PrincipalFrom(c); ok {
+			e.Actor = p.Subject
+			if p.ClinicianID != 0 {
+				v := p.ClinicianID
+				e.ActorClinicianID = &v
+			}
+			if p.APIKeyID != 0 {
+				v := p.APIKeyID
+				e.ActorAPIKeyID = &v
+			}
+		}
+		if v, ok := c.Get(resourceKey); ok {
+			e.ResourceID = v.(string)
+		}
+		if v, ok := c.Get(patientKey); ok {
+			id := v.(int64)
+			e.PatientID = &id
+		} else if e.ResourceType == "patients" {
+			e.PatientID = parseID(c.Param("id"))
+		} else {
+			e.PatientID = parseID(c.Query("patient_id"))
+		}
+		r.submit(c.Request.Context(), e)
+	}
+}
+
+// SetResource names the record a request acted on when the route does not,
+// such as the ID assigned by a create.
+func SetResource(c *gin.Context, id int64) {
+	c.Set(resourceKey, strconv.FormatInt(id, 10))
+}
+
+// SetPatient names the patient whose record the request touched.
+func SetPatient(c *gin.Context, id int64) {
+	c.Set(patientKey, id)
+}
+
+func action(method string) string {
+	switch method {
+	case http.MethodPost:
+		return Create
+	case http.MethodPut, http.MethodPatch:
+		return Update
+	case http.MethodDelete:
+		return Delete
+	default:
+		return Read
+	}
+}
+
+// resourceType is the first segment after /v1: "/v1/wounds/:id/trajectory"
+// is a wound resource.
+func resourceType(route string) string {
+	route = strings.TrimPrefix(route, "/v1/")
+	seg, _, _ := strings.Cut(route, "/")
+	return seg
+}
+
+func parseID(s string) *int64 {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Checkpoints sign the chain head with an Ed25519 key kept outside the
+// database. Anyone who can write audit_log can rebuild a consistent chain,
+// but not one that matches an earlier signed checkpoint.
+
+// CheckpointMessage is the signed statement for a chain head.
+func CheckpointMessage(entryID int64, entryHash string) []byte {
+	return []byte("wound_iq audit checkpoint " + strconv.FormatInt(entryID, 10) + " " + entryHash)
+}
+
+// KeyID identifies a public key in audit_checkpoints.key_id.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoadSigningKey reads a PEM-encoded PKCS#8 Ed25519 private key
+// (openssl genpkey -algorithm ed25519).
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %s: %w", path, err)
+	}
+	priv, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("audit: %s is not an Ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 public key, or derives the
+// public key from a private key file.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "")
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		priv, err := LoadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public().(ed25519.PublicKey), nil
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %s: %w", path, err)
+	}
+	pub, ok := k.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("audit: %s is not an Ed25519 key", path)
+	}
+	return pub, nil
+}
+
+func readPEM(path, want string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("audit: %s: no PEM block", path)
+	}
+	if want != "" && block.Type != want {
+		return nil, fmt.Errorf("audit: %s: PEM block is %q, want %q", path, block.Type, want)
+	}
+	return block, nil
+}
+
+// Checkpointer signs the chain head.
+type Checkpointer struct {
+	DB  *sql.DB
+	Log *zap.Logger
+	Key ed25519.PrivateKey
+}
+
+// Checkpoint signs the current chain head unless it is already the latest
+// checkpoint. It returns the head's entry ID, or 0 when nothing was signed.
+func (cp *Checkpointer) Checkpoint(ctx context.Context) (int64, error) {
+	var id int64
+	var hash string
+	err := cp.DB.QueryRowContext(ctx, `SELECT l.id, l.hash FROM audit_log l
+                                       WHERE l.hash IS NOT NULL
+                                         AND NOT EXISTS (SELECT 1 FROM audit_checkpoints c WHERE c.entry_id >= l.id)
+                                       ORDER BY l.id DESC LIMIT 1`).Scan(&id, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	sig := ed25519.Sign(cp.Key, CheckpointMessage(id, hash))
+	_, err = cp.DB.ExecContext(ctx, `INSERT INTO audit_checkpoints (entry_id, entry_hash, key_id, signature, created_at)
+                                     VALUES ($1, $2, $3, $4, now())`,
+		id, hash, KeyID(cp.Key.Public().(ed25519.PublicKey)), base64.StdEncoding.EncodeToString(sig))
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Run checkpoints every interval until ctx is done.
+func (cp *Checkpointer) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if id, err := cp.Checkpoint(ctx); err != nil {
+				cp.Log.Error("audit checkpoint failed", zap.Error(err))
+			} else if id != 0 {
+				cp.Log.Info("audit checkpoint", zap.Int64("entry_id", id))
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+// Break is the first point where the stored log disagrees with its chain or
+// checkpoints.
+type Break struct {
+	EntryID int64
+	Reason  string
+}
+
+func (b *Break) String() string {
+	return fmt.Sprintf("entry %d: %s", b.EntryID, b.Reason)
+}
+
+// Report summarises a Verify run. Break is nil when the log is intact.
+type Report struct {
+	Entries     int64 // chained entries checked
+	Unchained   int64 // entries written before chaining was introduced
+	Checkpoints int   // checkpoints whose signature was verified
+	OtherKeys   int   // checkpoints signed by a key other than the one given
+	Break       *Break
+}
+
+type checkpoint struct {
+	hash, keyID, signature string
+}
+
+// Verify walks audit_log in ID order, batch rows at a time, recomputing
+// every hash and link, and checks each checkpoint signed by pub against the
+// entry it covers. It stops at the first break. Entries appended after the
+// last checkpoint can be dropped without detection; checkpoint often.
+func Verify(ctx context.Context, db *sql.DB, pub ed25519.PublicKey, batch int) (Report, error) {
+	var rep Report
+	if batch <= 0 {
+		batch = 1000
+	}
+	checkpoints, err := loadCheckpoints(ctx, db)
+	if err != nil {
+		return rep, err
+	}
+	keyID := KeyID(pub)
+
+	var last int64
+	var prev string
+	chained := false
+	for {
+		n := 0
+		rows, err := db.QueryContext(ctx, `SELECT id, occurred_at, actor, actor_clinician_id, actor_api_key_id, action, resource_type,
+                                                 COALESCE(resource_id, ''), patient_id, route, status, COALESCE(request_id, ''),
+                                                 COALESCE(client_ip, ''), prev_hash, hash
+                                          FROM audit_log WHERE id > $1 ORDER BY id LIMIT $2`, last, batch)
+		if err != nil {
+			return rep, err
+		}
+		for rows.Next() {
+			n++
+			e, prevHash, hash, err := scanChained(rows)
+			if err != nil {
+				rows.Close()
+				return rep, err
+			}
+			last = e.ID
+
+			if !hash.Valid {
+				if chained {
+					rep.Break = &Break{e.ID, "entry is not chained"}
+					break
+				}
+				rep.Unchained++
+				continue
+			}
+			chained = true
+			rep.Entries++
+			if prevHash.String != prev {
+				rep.Break = &Break{e.ID, "prev_hash does not match the preceding entry (entry removed or inserted)"}
+				break
+			}
+			if EntryHash(prevHash.String, e) != hash.String {
+				rep.Break = &Break{e.ID, "content does not match its hash (entry edited)"}
+				break
+			}
+			prev = hash.String
+
+			for _, cp := range checkpoints[e.ID] {
+				if cp.keyID != keyID {
+					rep.OtherKeys++
+					continue
+				}
+				sig, err := base64.StdEncoding.DecodeString(cp.signature)
+				if err != nil || !ed25519.Verify(pub, CheckpointMessage(e.ID, cp.hash), sig) {
+					rep.Break = &Break{e.ID, "checkpoint signature is invalid"}
+					break
+				}
+				if cp.hash != hash.String {
+					rep.Break = &Break{e.ID, "entry hash differs from its signed checkpoint (chain rewritten)"}
+					break
+				}
+				rep.Checkpoints++
+			}
+			delete(checkpoints, e.ID)
+			if rep.Break != nil {
+				break
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return rep, err
+		}
+		if rep.Break != nil || n < batch {
+			break
+		}
+	}
+	if rep.Break != nil {
+		return rep, nil
+	}
+
+	// Checkpoints left over name entries that no longer exist.
+	var missing int64
+	for id := range checkpoints {
+		if missing == 0 || id < missing {
+			missing = id
+		}
+	}
+	if missing != 0 {
+		rep.Break = &Break{missing, "checkpointed entry is missing (log truncated)"}
+	}
+	return rep, nil
+}
+
+func loadCheckpoints(ctx context.Context, db *sql.DB) (map[int64][]checkpoint, error) {
+	rows, err := db.QueryContext(ctx, `SELECT entry_id, entry_hash, key_id, signature FROM audit_checkpoints ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int64][]checkpoint{}
+	for rows.Next() {
+		var id int64
+		var cp checkpoint
+		if err := rows.Scan(&id, &cp.hash, &cp.keyID, &cp.signature); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], cp)
+	}
+	return out, rows.Err()
+}
+
+func scanChained(rows *sql.Rows) (models.AuditEntry, sql.NullString, sql.NullString, error) {
+	var e models.AuditEntry
+	var clinicianID, apiKeyID, patientID sql.NullInt64
+	var prevHash, hash sql.NullString
+	err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &clinicianID, &apiKeyID, &e.Action, &e.ResourceType,
+		&e.ResourceID, &patientID, &e.Route, &e.Status, &e.RequestID, &e.ClientIP, &prevHash, &hash)
+	if err != nil {
+		return e, prevHash, hash, err
+	}
+	e.ActorClinicianID = nullableID(clinicianID)
+	e.ActorAPIKeyID = nullableID(apiKeyID)
+	e.PatientID = nullableID(patientID)
+	return e, prevHash, hash, nil
+}
+
+func nullableID(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	id := v.Int64
+	return &id
+}
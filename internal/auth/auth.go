@@ -0,0 +1,221 @@
+// Package auth authenticates API callers with bearer JWTs. HS256 tokens are
+// checked against a shared secret; RS256 and ES256 tokens against a JWKS
+// loaded from a file or URL, or found through OpenID Connect discovery.
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+var ErrInvalidToken = errors.New("auth: invalid token")
+
+// Principal is the authenticated caller. ClinicianID and Role are filled in
+// after verification from the caller's clinician record, when there is one.
+// Machine clients authenticated with an API key have APIKeyID and Scopes
+// set instead. Local is set on tokens the API issued itself for local
+// accounts, and SessionID is their numeric sid claim.
+type Principal struct {
+	Subject     string        `json:"sub"`
+	Email       string        `json:"email,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	Roles       []string      `json:"roles,omitempty"`
+	Issuer      string        `json:"iss,omitempty"`
+	ExpiresAt   time.Time     `json:"exp"`
+	ClinicianID int64         `json:"clinician_id,omitempty"`
+	Role        string        `json:"role,omitempty"`
+	APIKeyID    int64         `json:"api_key_id,omitempty"`
+	Scopes      []string      `json:"scopes,omitempty"`
+	SessionID   int64         `json:"sid,omitempty"`
+	Local       bool          `json:"-"`
+	Claims      jwt.MapClaims `json:"-"`
+}
+
+// Options configures a Verifier. At least one of HS256Secret and Keys is
+// required. Issuer and Audience are enforced when set.
+type Options struct {
+	HS256Secret []byte
+	Keys        KeySet
+	Issuer      string
+	Audience    string
+	Leeway      time.Duration
+	RolesClaim  string // defaults to "roles"
+	EmailClaim  string // defaults to "email"
+}
+
+// Verifier validates bearer tokens.
+type Verifier struct {
+	opts   Options
+	parser *jwt.Parser
+}
+
+func NewVerifier(opts Options) (*Verifier, error) {
+	var methods []string
+	if len(opts.HS256Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("auth: no verification key configured")
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+	if opts.EmailClaim == "" {
+		opts.EmailClaim = "email"
+	}
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	return &Verifier{opts: opts, parser: jwt.NewParser(parserOpts...)}, nil
+}
+
+// New builds the Verifier described by cfg.
+func New(cfg *config.Config) (*Verifier, error) {
+	opts := Options{
+		HS256Secret: []byte(cfg.JWTHS256Secret),
+		Issuer:      cfg.JWTIssuer,
+		Audience:    cfg.JWTAudience,
+		Leeway:      cfg.JWTLeeway,
+		RolesClaim:  cfg.JWTRolesClaim,
+		EmailClaim:  cfg.OIDCEmailClaim,
+	}
+	switch {
+	case cfg.OIDCIssuerURL != "":
+		opts.Keys = NewOIDCKeySet(cfg.OIDCIssuerURL, nil)
+	case cfg.JWTJWKSFile != "":
+		keys, err := LoadJWKSFile(cfg.JWTJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Keys = keys
+	case cfg.JWTJWKSURL != "":
+		opts.Keys = NewRemoteKeySet(cfg.JWTJWKSURL, nil)
+	}
+	return NewVerifier(opts)
+}
+
+// Verify checks the signature and registered claims of raw and returns the
+// principal it identifies. Every failure wraps ErrInvalidToken.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	tok, err := v.parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return v.key(ctx, t)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	p := &Principal{Claims: claims}
+	p.Subject, _ = claims["sub"].(string)
+	if p.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	p.Email, _ = claims[v.opts.EmailClaim].(string)
+	// An address the issuer has not verified must not pick the caller's
+	// clinician record. Some issuers send the flag as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		if !verified {
+			p.Email = ""
+		}
+	case string:
+		if verified == "false" {
+			p.Email = ""
+		}
+	}
+	p.Name, _ = claims["name"].(string)
+	p.Issuer, _ = claims["iss"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+	p.Roles = stringList(claims[v.opts.RolesClaim])
+	// Only the local HS256 key signs local account tokens; an identity
+	// provider's token claiming to be one is an ordinary token. Providers
+	// may also send their own sid, which is not the API's.
+	if _, hmac := tok.Method.(*jwt.SigningMethodHMAC); hmac && claims[LocalAccountClaim] == true {
+		p.Local = true
+		if sid, ok := claims["sid"].(float64); ok {
+			p.SessionID = int64(sid)
+		}
+	}
+	return p, nil
+}
+
+func (v *Verifier) key(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.opts.HS256Secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	key, err := v.opts.Keys.Key(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	// A key may only verify the algorithm family it was published for.
+	switch t.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return nil, errors.New("auth: key type does not match alg")
+		}
+	case *jwt.SigningMethodECDSA:
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return nil, errors.New("auth: key type does not match alg")
+		}
+	}
+	return key, nil
+}
+
+// stringList accepts a JSON array of strings or a space-separated string.
+func stringList(v interface{}) []string {
+	switch vv := v.(type) {
+	case string:
+		return strings.Fields(vv)
+	case []interface{}:
+		out := make([]string, 0, len(vv))
+		for _, item := range vv {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+const principalKey = "auth.principal"
+
+// SetPrincipal stores p on the request context.
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// PrincipalFrom returns the principal stored by Middleware.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok && p != nil
+}
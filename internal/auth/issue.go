@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+// LocalAccountClaim marks access tokens minted by Signer. The Verifier only
+// honours it on HS256 tokens, which no identity provider can sign.
+const LocalAccountClaim = "local_account"
+
+// Signer mints HS256 access tokens for local user accounts. They carry
+// JWT_ISSUER and JWT_AUDIENCE when configured, so the Verifier built from
+// the same config accepts them like any other bearer token.
+type Signer struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+	// EmailClaim matches the Verifier's; defaults to "email".
+	EmailClaim string
+	TTL        time.Duration
+}
+
+// NewSigner returns the Signer described by cfg.
+func NewSigner(cfg *config.Config) *Signer {
+	return &Signer{
+		Secret:     []byte(cfg.JWTHS256Secret),
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		EmailClaim: cfg.OIDCEmailClaim,
+		TTL:        cfg.AccessTokenTTL,
+	}
+}
+
+// AccessToken describes the token to sign. Email links it to a clinician
+// record; Methods lists how the user authenticated ("pwd", "otp") and is
+// sent as the amr claim.
+type AccessToken struct {
+	Subject   string
+	Email     string
+	Name      string
+	SessionID int64
+	Methods   []string
+}
+
+// Sign returns a signed access token and its expiry.
+func (s *Signer) Sign(t AccessToken) (string, time.Time, error) {
+	if len(s.Secret) == 0 {
+		return "", time.Time{}, errors.New("auth: no signing secret configured")
+	}
+	iat := time.Now()
+	exp := iat.Add(s.TTL)
+	emailClaim := s.EmailClaim
+	if emailClaim == "" {
+		emailClaim = "email"
+	}
+	claims := jwt.MapClaims{
+		"sub":             t.Subject,
+		emailClaim:        t.Email,
+		"iat":             iat.Unix(),
+		"exp":             exp.Unix(),
+		LocalAccountClaim: true,
+	}
+	if t.SessionID != 0 {
+		claims["sid"] = t.SessionID
+	}
+	if len(t.Methods) > 0 {
+		claims["amr"] = t.Methods
+	}
+	if t.Name != "" {
+		claims["name"] = t.Name
+	}
+	if s.Issuer != "" {
+		claims["iss"] = s.Issuer
+	}
+	if s.Audience != "" {
+		claims["aud"] = s.Audience
+	}
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.Secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return raw, exp, nil
+}
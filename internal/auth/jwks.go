@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned by a KeySet that has no key with the requested ID.
+var ErrUnknownKey = errors.New("auth: unknown signing key")
+
+// KeySet resolves a JWT "kid" header to a public key. An empty kid matches a
+// key set holding exactly one key.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS decodes an RFC 7517 key set. RSA and P-256 EC signing keys are
+// kept; encryption keys and other key types are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("auth: jwks: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			key, err = k.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("auth: jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("auth: jwks contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) < 256 || !exp.IsInt64() || exp.Int64() < 3 {
+		return nil, errors.New("weak or malformed RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func (k jwk) ecKey() (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("point is not on P-256")
+	}
+	return key, nil
+}
+
+func lookup(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// StaticKeySet is a key set loaded once, typically from a local file.
+type StaticKeySet map[string]crypto.PublicKey
+
+// LoadJWKSFile reads a JWKS document from disk.
+func LoadJWKSFile(path string) (StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: jwks file: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return StaticKeySet(keys), nil
+}
+
+func (s StaticKeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := lookup(s, kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// RemoteKeySet fetches a JWKS over HTTP and caches it for TTL. A stale cache
+// or an unknown kid triggers a refetch, at most once per MinRefresh, so that
+// rotated keys are picked up without hammering the issuer. Only one fetch
+// runs at a time; callers that need its result wait for it, the rest keep
+// using the cached set.
+type RemoteKeySet struct {
+	URL        string
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+	fetching    chan struct{} // closed when the running fetch finishes
+}
+
+// NewRemoteKeySet returns a key set backed by url. client may be nil.
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RemoteKeySet{URL: url, TTL: time.Hour, MinRefresh: time.Minute, client: client, now: time.Now}
+}
+
+func (r *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	for {
+		r.mu.Lock()
+		now := r.now()
+		key, ok := lookup(r.keys, kid)
+		stale := r.keys == nil || now.Sub(r.fetchedAt) > r.TTL
+		if !stale && ok {
+			r.mu.Unlock()
+			return key, nil
+		}
+		if wait := r.fetching; wait != nil {
+			r.mu.Unlock()
+			// Serve the cached key rather than queue behind a refresh.
+			if ok {
+				return key, nil
+			}
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if now.Sub(r.lastAttempt) < r.MinRefresh {
+			keys, err := r.keys, r.lastErr
+			r.mu.Unlock()
+			return result(keys, key, ok, err)
+		}
+		done := make(chan struct{})
+		r.fetching = done
+		r.lastAttempt = now
+		r.mu.Unlock()
+
+		// The fetch serves every waiting caller, so it outlives this one.
+		keys, err := r.fetch(context.WithoutCancel(ctx))
+
+		r.mu.Lock()
+		r.fetching = nil
+		close(done)
+		r.lastErr = err
+		if err == nil {
+			r.keys = keys
+			r.fetchedAt = now
+		}
+		// Keep serving the cached set through an issuer outage.
+		keys = r.keys
+		r.mu.Unlock()
+		key, ok = lookup(keys, kid)
+		return result(keys, key, ok, err)
+	}
+}
+
+// result reports a lookup in keys, or fetchErr when there are no keys yet.
+func result(keys map[string]crypto.PublicKey, key crypto.PublicKey, ok bool, fetchErr error) (crypto.PublicKey, error) {
+	if keys == nil && fetchErr != nil {
+		return nil, fetchErr
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (r *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetch jwks: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetch jwks: %w", err)
+	}
+	return ParseJWKS(data)
+}
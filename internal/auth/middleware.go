@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Middleware rejects requests without a valid bearer token with 401 and
+// stores the caller's Principal on the context otherwise. Requests already
+// authenticated by an earlier middleware (API keys) pass through.
+func Middleware(v *Verifier, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := PrincipalFrom(c); ok {
+			c.Next()
+			return
+		}
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="wound_iq"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+		p, err := v.Verify(c.Request.Context(), raw)
+		if err != nil {
+			log.Debug("rejected bearer token", zap.Error(err), zap.String("path", c.FullPath()))
+			c.Header("WWW-Authenticate", `Bearer realm="wound_iq", error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			return
+		}
+		SetPrincipal(c, p)
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
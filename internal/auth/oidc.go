@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is the part of an OpenID Connect discovery document the API
+// uses.
+type Provider struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// Discover fetches issuer's /.well-known/openid-configuration. The document
+// must name issuer exactly, as OIDC Discovery requires, so a compromised or
+// misconfigured endpoint cannot hand out another issuer's keys.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*Provider, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: oidc discovery: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("auth: oidc discovery: %w", err)
+	}
+	var p Provider
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("auth: oidc discovery: %w", err)
+	}
+	if p.Issuer != issuer {
+		return nil, fmt.Errorf("auth: oidc discovery: document is for issuer %q, want %q", p.Issuer, issuer)
+	}
+	if p.JWKSURI == "" {
+		return nil, errors.New("auth: oidc discovery: no jwks_uri")
+	}
+	return &p, nil
+}
+
+// OIDCKeySet is the key set of an OpenID Connect issuer. The JWKS URL is
+// discovered on first use and again every MetadataTTL, so an issuer that
+// moves its keys is followed; the keys themselves are cached and rotated
+// by a RemoteKeySet. Discovery failures are retried at most once per
+// MinRefresh, and the last known keys keep being served meanwhile.
+type OIDCKeySet struct {
+	Issuer      string
+	MetadataTTL time.Duration
+	KeyTTL      time.Duration
+	MinRefresh  time.Duration
+
+	client *http.Client
+	now    func() time.Time
+
+	mu           sync.Mutex
+	keys         *RemoteKeySet
+	discoveredAt time.Time
+	lastAttempt  time.Time
+	lastErr      error
+	discovering  chan struct{} // closed when the running discovery finishes
+}
+
+// NewOIDCKeySet returns the key set of issuer. client may be nil.
+func NewOIDCKeySet(issuer string, client *http.Client) *OIDCKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCKeySet{
+		Issuer:      issuer,
+		MetadataTTL: 24 * time.Hour,
+		KeyTTL:      time.Hour,
+		MinRefresh:  time.Minute,
+		client:      client,
+		now:         time.Now,
+	}
+}
+
+func (o *OIDCKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	keys, err := o.jwks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys.Key(ctx, kid)
+}
+
+// jwks returns the RemoteKeySet for the currently advertised JWKS URL.
+// Discovery runs outside the lock; callers holding a key set keep using it
+// meanwhile, and the others wait for the one discovery in flight.
+func (o *OIDCKeySet) jwks(ctx context.Context) (*RemoteKeySet, error) {
+	for {
+		o.mu.Lock()
+		now := o.now()
+		keys := o.keys
+		if keys != nil && now.Sub(o.discoveredAt) <= o.MetadataTTL {
+			o.mu.Unlock()
+			return keys, nil
+		}
+		if wait := o.discovering; wait != nil {
+			o.mu.Unlock()
+			if keys != nil {
+				return keys, nil
+			}
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if !o.lastAttempt.IsZero() && now.Sub(o.lastAttempt) < o.MinRefresh {
+			err := o.lastErr
+			o.mu.Unlock()
+			if keys != nil {
+				return keys, nil
+			}
+			return nil, err
+		}
+		done := make(chan struct{})
+		o.discovering = done
+		o.lastAttempt = now
+		o.mu.Unlock()
+
+		// Discovery serves every waiting caller, so it outlives this one.
+		p, err := Discover(context.WithoutCancel(ctx), o.client, o.Issuer)
+
+		o.mu.Lock()
+		o.discovering = nil
+		close(done)
+		o.lastErr = err
+		if err == nil {
+			if o.keys == nil || o.keys.URL != p.JWKSURI {
+				rks := NewRemoteKeySet(p.JWKSURI, o.client)
+				rks.TTL, rks.MinRefresh = o.KeyTTL, o.MinRefresh
+				o.keys = rks
+			}
+			o.discoveredAt = now
+		}
+		keys = o.keys
+		o.mu.Unlock()
+		if keys == nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+}
@@ -0,0 +1,78 @@
+// Package braden scores the Braden Scale for Predicting Pressure Sore Risk.
+// Six subscales are summed to a total between 6 and 23; lower totals mean
+// higher risk.
+package braden
+
+import "fmt"
+
+// Risk bands, from lowest to highest risk.
+const (
+	RiskNone     = "no_risk"   // 19-23
+	RiskMild     = "mild"      // 15-18
+	RiskModerate = "moderate"  // 13-14
+	RiskHigh     = "high"      // 10-12
+	RiskVeryHigh = "very_high" // 9 or less
+)
+
+// Subscales holds the six Braden subscale ratings. Friction/shear is rated
+// 1-3, every other subscale 1-4.
+type Subscales struct {
+	SensoryPerception int `json:"sensory_perception"`
+	Moisture          int `json:"moisture"`
+	Activity          int `json:"activity"`
+	Mobility          int `json:"mobility"`
+	Nutrition         int `json:"nutrition"`
+	FrictionShear     int `json:"friction_shear"`
+}
+
+// Validate reports the first subscale outside its allowed range.
+func (s Subscales) Validate() error {
+	checks := []struct {
+		name  string
+		value int
+		max   int
+	}{
+		{"sensory_perception", s.SensoryPerception, 4},
+		{"moisture", s.Moisture, 4},
+		{"activity", s.Activity, 4},
+		{"mobility", s.Mobility, 4},
+		{"nutrition", s.Nutrition, 4},
+		{"friction_shear", s.FrictionShear, 3},
+	}
+	for _, c := range checks {
+		if c.value < 1 || c.value > c.max {
+			return fmt.Errorf("braden: %s must be between 1 and %d, got %d", c.name, c.max, c.value)
+		}
+	}
+	return nil
+}
+
+// Total sums the subscales.
+func (s Subscales) Total() int {
+	return s.SensoryPerception + s.Moisture + s.Activity + s.Mobility + s.Nutrition + s.FrictionShear
+}
+
+// RiskBand maps a Braden total to its risk band.
+func RiskBand(total int) string {
+	switch {
+	case total >= 19:
+		return RiskNone
+	case total >= 15:
+		return RiskMild
+	case total >= 13:
+		return RiskModerate
+	case total >= 10:
+		return RiskHigh
+	default:
+		return RiskVeryHigh
+	}
+}
+
+// Score validates the subscales and returns the total and risk band.
+func Score(s Subscales) (int, string, error) {
+	if err := s.Validate(); err != nil {
+		return 0, "", err
+	}
+	total := s.Total()
+	return total, RiskBand(total), nil
+}
@@ -1,8 +1,15 @@
 package config
 
 import (
+    "encoding/base64"
     "errors"
+    "fmt"
+    "net"
+    "net/url"
     "os"
+    "strconv"
+    "strings"
+    "time"
 
     "github.com/joho/godotenv"
 )
@@ -12,6 +19,129 @@ type Config struct {
     Port     string
     AppEnv   string
     LogLevel string
+    // LogRedactFields replaces logger.DefaultRedactFields when
+    // LOG_REDACT_FIELDS (comma-separated) is set.
+    LogRedactFields []string
+
+    // Image storage: STORAGE_DRIVER is "local" (default) or "s3".
+    StorageDriver     string
+    StorageLocalDir   string
+    S3Endpoint        string
+    S3Region          string
+    S3Bucket          string
+    S3AccessKeyID     string
+    S3SecretAccessKey string
+    MaxUploadBytes    int64
+
+    // Authentication: bearer JWTs signed with JWT_HS256_SECRET (HS256) or a
+    // key from the JWKS at JWT_JWKS_FILE / JWT_JWKS_URL (RS256, ES256).
+    AuthDisabled   bool // AUTH_DISABLED=true, development only
+    JWTHS256Secret string
+    JWTJWKSFile    string
+    JWTJWKSURL     string
+    JWTIssuer      string
+    JWTAudience    string
+    JWTLeeway      time.Duration
+    JWTRolesClaim  string
+
+    // OpenID Connect: OIDC_ISSUER_URL replaces JWT_JWKS_FILE/JWT_JWKS_URL;
+    // the JWKS is found through discovery and JWT_ISSUER defaults to it.
+    // Callers are matched to clinicians by the OIDC_EMAIL_CLAIM address;
+    // with OIDC_AUTO_PROVISION=true an unknown address gets a new clinician
+    // record whose role is the token's, or OIDC_DEFAULT_ROLE.
+    OIDCIssuerURL     string
+    OIDCEmailClaim    string
+    OIDCAutoProvision bool
+    OIDCDefaultRole   string
+
+    // Audit log checkpoints are signed with the Ed25519 key in
+    // AUDIT_SIGNING_KEY_FILE every AUDIT_CHECKPOINT_INTERVAL. Up to
+    // AUDIT_QUEUE_SIZE entries wait for the background audit writer.
+    AuditSigningKeyFile     string
+    AuditCheckpointInterval time.Duration
+    AuditQueueSize          int
+
+    // Field encryption: FIELD_ENCRYPTION_KEYS is "id:base64key,..." of
+    // 32-byte keys, FIELD_ENCRYPTION_CURRENT_KEY the ID that seals new
+    // values (defaults to the only key), FIELD_BLIND_INDEX_KEY a base64 key
+    // of at least 32 bytes for MRN lookups.
+    FieldKeys       map[string][]byte
+    FieldCurrentKey string
+    FieldIndexKey   []byte
+
+    // CORS: CORS_ALLOWED_ORIGINS defaults to "*" in development and to none
+    // elsewhere; empty method and header lists fall back to the defaults in
+    // internal/cors.
+    CORSAllowedOrigins   []string
+    CORSAllowedMethods   []string
+    CORSAllowedHeaders   []string
+    CORSExposedHeaders   []string
+    CORSAllowCredentials bool
+    CORSMaxAge           time.Duration
+
+    // Rate limiting: token buckets per API key, user or client IP. Writes
+    // and exports get their own, stricter buckets; RATE_LIMIT_ROUTES
+    // overrides single routes ("METHOD /v1/route=N/period,..."). A zero
+    // limit means unlimited. RATE_LIMIT_IP applies per client IP before
+    // authentication, so that bad credentials are throttled too.
+    // RATE_LIMIT_STORE is "memory" (default, per instance) or "redis"
+    // (shared, at REDIS_URL).
+    RateLimitDefault RateLimit
+    RateLimitWrite   RateLimit
+    RateLimitExport  RateLimit
+    RateLimitIP      RateLimit
+    RateLimitRoutes  map[string]RateLimit
+    RateLimitStore   string
+    RedisURL         string
+
+    // TrustedProxies lists the proxy addresses and CIDRs whose
+    // X-Forwarded-For is believed when working out the client IP. Empty
+    // (the default) uses the connection's address.
+    TrustedProxies []string
+
+    // Break-the-glass grants to restricted patients expire after
+    // BREAK_GLASS_DURATION (default 1h).
+    BreakGlassDuration time.Duration
+
+    // Local accounts: with LOCAL_AUTH=true users log in at /v1/auth/login
+    // with a password and, once enrolled, a TOTP code, and get access
+    // tokens signed with JWT_HS256_SECRET that last ACCESS_TOKEN_TTL plus a
+    // refresh token that lasts REFRESH_TOKEN_TTL. LOGIN_MAX_FAILURES
+    // consecutive failures lock an account for LOGIN_LOCKOUT; password reset
+    // tokens expire after PASSWORD_RESET_TTL. Each login is a session that
+    // lasts REFRESH_TOKEN_TTL; revoked sessions are reloaded from the
+    // database every SESSION_REVOCATION_REFRESH.
+    LocalAuth                bool
+    AccessTokenTTL           time.Duration
+    RefreshTokenTTL          time.Duration
+    LoginMaxFailures         int
+    LoginLockout             time.Duration
+    PasswordResetTTL         time.Duration
+    SessionRevocationRefresh time.Duration
+}
+
+// RateLimit allows Requests per Per, in bursts of up to Requests.
+type RateLimit struct {
+    Requests int
+    Per      time.Duration
+}
+
+// ParseRateLimit reads "N/period", e.g. "120/1m"; "0" or "off" disables.
+func ParseRateLimit(s string) (RateLimit, error) {
+    s = strings.TrimSpace(s)
+    if s == "0" || s == "off" {
+        return RateLimit{}, nil
+    }
+    n, per, ok := strings.Cut(s, "/")
+    requests, err := strconv.Atoi(n)
+    if !ok || err != nil || requests <= 0 {
+        return RateLimit{}, fmt.Errorf("rate limit must be N/period, got %q", s)
+    }
+    d, err := time.ParseDuration(per)
+    if err != nil || d <= 0 {
+        return RateLimit{}, fmt.Errorf("rate limit must be N/period, got %q", s)
+    }
+    return RateLimit{Requests: requests, Per: d}, nil
 }
 
 func Load() (*Config, error) {
@@ -34,10 +164,366 @@ func Load() (*Config, error) {
     if logLevel == "" {
         logLevel = "info"
     }
+
+    storageDriver := os.Getenv("STORAGE_DRIVER")
+    if storageDriver == "" {
+        storageDriver = "local"
+    }
+    storageDir := os.Getenv("STORAGE_LOCAL_DIR")
+    if storageDir == "" {
+        storageDir = "data/images"
+    }
+    maxUpload := int64(10 << 20)
+    if v := os.Getenv("MAX_UPLOAD_BYTES"); v != "" {
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || n <= 0 {
+            return nil, fmt.Errorf("MAX_UPLOAD_BYTES must be a positive integer, got %q", v)
+        }
+        maxUpload = n
+    }
+
+    authDisabled := os.Getenv("AUTH_DISABLED") == "true"
+    if authDisabled && env != "development" {
+        return nil, fmt.Errorf("AUTH_DISABLED is only allowed when APP_ENV=development, got %q", env)
+    }
+    hsSecret := os.Getenv("JWT_HS256_SECRET")
+    jwksFile := os.Getenv("JWT_JWKS_FILE")
+    jwksURL := os.Getenv("JWT_JWKS_URL")
+    oidcIssuer := os.Getenv("OIDC_ISSUER_URL")
+    if !authDisabled && hsSecret == "" && jwksFile == "" && jwksURL == "" && oidcIssuer == "" {
+        return nil, errors.New("one of JWT_HS256_SECRET, JWT_JWKS_FILE, JWT_JWKS_URL or OIDC_ISSUER_URL is required")
+    }
+    keySources := 0
+    for _, v := range []string{jwksFile, jwksURL, oidcIssuer} {
+        if v != "" {
+            keySources++
+        }
+    }
+    if keySources > 1 {
+        return nil, errors.New("set only one of JWT_JWKS_FILE, JWT_JWKS_URL and OIDC_ISSUER_URL")
+    }
+    issuer := os.Getenv("JWT_ISSUER")
+    audience := os.Getenv("JWT_AUDIENCE")
+    if oidcIssuer != "" {
+        if u, err := url.Parse(oidcIssuer); err != nil || (u.Scheme != "https" && !(u.Scheme == "http" && env == "development")) || u.Host == "" {
+            return nil, fmt.Errorf("OIDC_ISSUER_URL must be an https URL, got %q", oidcIssuer)
+        }
+        if issuer == "" {
+            issuer = oidcIssuer
+        } else if issuer != oidcIssuer {
+            return nil, errors.New("JWT_ISSUER must match OIDC_ISSUER_URL when both are set")
+        }
+        // Without an audience any token the issuer minted for any client
+        // would be accepted.
+        if audience == "" {
+            return nil, errors.New("JWT_AUDIENCE is required with OIDC_ISSUER_URL")
+        }
+    }
+    oidcEmailClaim := os.Getenv("OIDC_EMAIL_CLAIM")
+    if oidcEmailClaim == "" {
+        oidcEmailClaim = "email"
+    }
+    oidcProvision := os.Getenv("OIDC_AUTO_PROVISION") == "true"
+    if oidcProvision && oidcIssuer == "" {
+        return nil, errors.New("OIDC_AUTO_PROVISION requires OIDC_ISSUER_URL")
+    }
+    if hsSecret != "" && len(hsSecret) < 32 {
+        return nil, errors.New("JWT_HS256_SECRET must be at least 32 bytes")
+    }
+    leeway := 30 * time.Second
+    if v := os.Getenv("JWT_LEEWAY"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d < 0 {
+            return nil, fmt.Errorf("JWT_LEEWAY must be a non-negative duration, got %q", v)
+        }
+        leeway = d
+    }
+    rolesClaim := os.Getenv("JWT_ROLES_CLAIM")
+    if rolesClaim == "" {
+        rolesClaim = "roles"
+    }
+
+    checkpointInterval := time.Hour
+    if v := os.Getenv("AUDIT_CHECKPOINT_INTERVAL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 {
+            return nil, fmt.Errorf("AUDIT_CHECKPOINT_INTERVAL must be a positive duration, got %q", v)
+        }
+        checkpointInterval = d
+    }
+    auditQueue := 4096
+    if v := os.Getenv("AUDIT_QUEUE_SIZE"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n <= 0 {
+            return nil, fmt.Errorf("AUDIT_QUEUE_SIZE must be a positive integer, got %q", v)
+        }
+        auditQueue = n
+    }
+
+    fieldKeys, currentKey, indexKey, err := loadFieldKeys()
+    if err != nil {
+        return nil, err
+    }
+    if len(fieldKeys) == 0 && env != "development" {
+        return nil, fmt.Errorf("FIELD_ENCRYPTION_KEYS is required unless APP_ENV=development, got %q", env)
+    }
+
+    corsOrigins := splitList("CORS_ALLOWED_ORIGINS")
+    if os.Getenv("CORS_ALLOWED_ORIGINS") == "" && env == "development" {
+        corsOrigins = []string{"*"}
+    }
+    corsCredentials := os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"
+    for _, o := range corsOrigins {
+        if o == "*" {
+            if env != "development" {
+                return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS=* is only allowed when APP_ENV=development, got %q", env)
+            }
+            if corsCredentials {
+                return nil, errors.New("CORS_ALLOWED_ORIGINS=* cannot be combined with CORS_ALLOW_CREDENTIALS")
+            }
+            continue
+        }
+        if u, err := url.Parse(o); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+            return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS entries must be scheme://host[:port], got %q", o)
+        }
+    }
+    corsMaxAge := 10 * time.Minute
+    if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d < 0 {
+            return nil, fmt.Errorf("CORS_MAX_AGE must be a non-negative duration, got %q", v)
+        }
+        corsMaxAge = d
+    }
+
+    rateDefault, err := envRateLimit("RATE_LIMIT_DEFAULT", "300/1m")
+    if err != nil {
+        return nil, err
+    }
+    rateWrite, err := envRateLimit("RATE_LIMIT_WRITE", "60/1m")
+    if err != nil {
+        return nil, err
+    }
+    rateExport, err := envRateLimit("RATE_LIMIT_EXPORT", "10/1m")
+    if err != nil {
+        return nil, err
+    }
+    rateIP, err := envRateLimit("RATE_LIMIT_IP", "1200/1m")
+    if err != nil {
+        return nil, err
+    }
+    rateRoutes := map[string]RateLimit{}
+    for _, entry := range splitList("RATE_LIMIT_ROUTES") {
+        route, limit, ok := strings.Cut(entry, "=")
+        method, path, okRoute := strings.Cut(strings.TrimSpace(route), " ")
+        if !ok || !okRoute || method == "" || !strings.HasPrefix(path, "/") {
+            return nil, fmt.Errorf("RATE_LIMIT_ROUTES entries must be \"METHOD /path=N/period\", got %q", entry)
+        }
+        l, err := ParseRateLimit(limit)
+        if err != nil {
+            return nil, fmt.Errorf("RATE_LIMIT_ROUTES %s: %w", route, err)
+        }
+        rateRoutes[strings.ToUpper(method)+" "+path] = l
+    }
+    rateStore := os.Getenv("RATE_LIMIT_STORE")
+    if rateStore == "" {
+        rateStore = "memory"
+    }
+    redisURL := os.Getenv("REDIS_URL")
+    switch rateStore {
+    case "memory":
+    case "redis":
+        if u, err := url.Parse(redisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+            return nil, fmt.Errorf("RATE_LIMIT_STORE=redis needs REDIS_URL as redis://host:port/db, got %q", redisURL)
+        }
+    default:
+        return nil, fmt.Errorf("RATE_LIMIT_STORE must be memory or redis, got %q", rateStore)
+    }
+
+    trustedProxies := splitList("TRUSTED_PROXIES")
+    for _, p := range trustedProxies {
+        if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+            return nil, fmt.Errorf("TRUSTED_PROXIES entries must be IP addresses or CIDRs, got %q", p)
+        }
+    }
+
+    breakGlass := time.Hour
+    if v := os.Getenv("BREAK_GLASS_DURATION"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 || d > 24*time.Hour {
+            return nil, fmt.Errorf("BREAK_GLASS_DURATION must be a positive duration of at most 24h, got %q", v)
+        }
+        breakGlass = d
+    }
+
+    localAuth := os.Getenv("LOCAL_AUTH") == "true"
+    if localAuth && hsSecret == "" {
+        return nil, errors.New("LOCAL_AUTH requires JWT_HS256_SECRET to sign access tokens")
+    }
+    if localAuth && oidcIssuer != "" {
+        // Local tokens would have to claim the identity provider's issuer.
+        return nil, errors.New("LOCAL_AUTH cannot be combined with OIDC_ISSUER_URL")
+    }
+    accessTTL, err := envDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+    if err != nil {
+        return nil, err
+    }
+    refreshTTL, err := envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+    if err != nil {
+        return nil, err
+    }
+    lockout, err := envDuration("LOGIN_LOCKOUT", 15*time.Minute)
+    if err != nil {
+        return nil, err
+    }
+    resetTTL, err := envDuration("PASSWORD_RESET_TTL", time.Hour)
+    if err != nil {
+        return nil, err
+    }
+    revocationRefresh, err := envDuration("SESSION_REVOCATION_REFRESH", 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    maxFailures := 5
+    if v := os.Getenv("LOGIN_MAX_FAILURES"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n <= 0 {
+            return nil, fmt.Errorf("LOGIN_MAX_FAILURES must be a positive integer, got %q", v)
+        }
+        maxFailures = n
+    }
+
     return &Config{
-        DB_DSN:   dsn,
-        Port:     port,
-        AppEnv:   env,
-        LogLevel: logLevel,
+        DB_DSN:          dsn,
+        Port:            port,
+        AppEnv:          env,
+        LogLevel:        logLevel,
+        LogRedactFields: splitList("LOG_REDACT_FIELDS"),
+
+        StorageDriver:     storageDriver,
+        StorageLocalDir:   storageDir,
+        S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+        S3Region:          os.Getenv("S3_REGION"),
+        S3Bucket:          os.Getenv("S3_BUCKET"),
+        S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+        S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+        MaxUploadBytes:    maxUpload,
+
+        AuthDisabled:   authDisabled,
+        JWTHS256Secret: hsSecret,
+        JWTJWKSFile:    jwksFile,
+        JWTJWKSURL:     jwksURL,
+        JWTIssuer:      issuer,
+        JWTAudience:    audience,
+        JWTLeeway:      leeway,
+        JWTRolesClaim:  rolesClaim,
+
+        OIDCIssuerURL:     oidcIssuer,
+        OIDCEmailClaim:    oidcEmailClaim,
+        OIDCAutoProvision: oidcProvision,
+        OIDCDefaultRole:   os.Getenv("OIDC_DEFAULT_ROLE"),
+
+        AuditSigningKeyFile:     os.Getenv("AUDIT_SIGNING_KEY_FILE"),
+        AuditCheckpointInterval: checkpointInterval,
+        AuditQueueSize:          auditQueue,
+
+        FieldKeys:       fieldKeys,
+        FieldCurrentKey: currentKey,
+        FieldIndexKey:   indexKey,
+
+        CORSAllowedOrigins:   corsOrigins,
+        CORSAllowedMethods:   splitList("CORS_ALLOWED_METHODS"),
+        CORSAllowedHeaders:   splitList("CORS_ALLOWED_HEADERS"),
+        CORSExposedHeaders:   splitList("CORS_EXPOSED_HEADERS"),
+        CORSAllowCredentials: corsCredentials,
+        CORSMaxAge:           corsMaxAge,
+
+        RateLimitDefault: rateDefault,
+        RateLimitWrite:   rateWrite,
+        RateLimitExport:  rateExport,
+        RateLimitIP:      rateIP,
+        RateLimitRoutes:  rateRoutes,
+        RateLimitStore:   rateStore,
+        RedisURL:         redisURL,
+        TrustedProxies:   trustedProxies,
+
+        BreakGlassDuration: breakGlass,
+
+        LocalAuth:                localAuth,
+        AccessTokenTTL:           accessTTL,
+        RefreshTokenTTL:          refreshTTL,
+        LoginMaxFailures:         maxFailures,
+        LoginLockout:             lockout,
+        PasswordResetTTL:         resetTTL,
+        SessionRevocationRefresh: revocationRefresh,
     }, nil
 }
+
+// envDuration reads a positive duration, defaulting to def.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+    v := os.Getenv(name)
+    if v == "" {
+        return def, nil
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d <= 0 {
+        return 0, fmt.Errorf("%s must be a positive duration, got %q", name, v)
+    }
+    return d, nil
+}
+
+func envRateLimit(name, def string) (RateLimit, error) {
+    v := os.Getenv(name)
+    if v == "" {
+        v = def
+    }
+    l, err := ParseRateLimit(v)
+    if err != nil {
+        return l, fmt.Errorf("%s: %w", name, err)
+    }
+    return l, nil
+}
+
+// splitList reads a comma-separated environment variable, dropping blanks.
+func splitList(name string) []string {
+    var out []string
+    for _, v := range strings.Split(os.Getenv(name), ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            out = append(out, v)
+        }
+    }
+    return out
+}
+
+func loadFieldKeys() (map[string][]byte, string, []byte, error) {
+    raw := os.Getenv("FIELD_ENCRYPTION_KEYS")
+    if raw == "" {
+        return nil, "", nil, nil
+    }
+    keys := map[string][]byte{}
+    var last string
+    for _, entry := range strings.Split(raw, ",") {
+        id, b64, ok := strings.Cut(strings.TrimSpace(entry), ":")
+        key, err := base64.StdEncoding.DecodeString(b64)
+        if !ok || id == "" || err != nil || len(key) != 32 {
+            return nil, "", nil, fmt.Errorf("FIELD_ENCRYPTION_KEYS entries must be id:base64 of 32 bytes, got %q", id)
+        }
+        keys[id] = key
+        last = id
+    }
+    current := os.Getenv("FIELD_ENCRYPTION_CURRENT_KEY")
+    if current == "" {
+        if len(keys) > 1 {
+            return nil, "", nil, errors.New("FIELD_ENCRYPTION_CURRENT_KEY is required with more than one key")
+        }
+        current = last
+    }
+    if _, ok := keys[current]; !ok {
+        return nil, "", nil, fmt.Errorf("FIELD_ENCRYPTION_CURRENT_KEY %q is not in FIELD_ENCRYPTION_KEYS", current)
+    }
+    indexKey, err := base64.StdEncoding.DecodeString(os.Getenv("FIELD_BLIND_INDEX_KEY"))
+    if err != nil || len(indexKey) < 32 {
+        return nil, "", nil, errors.New("FIELD_BLIND_INDEX_KEY must be base64 of at least 32 bytes")
+    }
+    return keys, current, indexKey, nil
+}
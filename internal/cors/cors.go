@@ -0,0 +1,154 @@
+// Package cors answers browser cross-origin requests from an explicit
+// origin allow-list. Requests from other origins are refused outright
+// rather than merely left without CORS headers, so a disallowed page
+// cannot trigger writes it is not allowed to read back.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+// Any allows every origin. config.Load only accepts it in development and
+// never together with credentials.
+const Any = "*"
+
+// Defaults used when the configuration leaves a list empty.
+var (
+	DefaultMethods        = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	DefaultHeaders        = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Request-ID"}
+	DefaultExposedHeaders = []string{"X-Request-ID", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+)
+
+// Policy is the CORS configuration for one deployment.
+type Policy struct {
+	Origins          []string
+	Methods          []string
+	Headers          []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// FromConfig returns the policy configured in cfg, with defaults for
+// empty lists. No origins means every cross-origin request is refused.
+func FromConfig(cfg *config.Config) Policy {
+	p := Policy{
+		Origins:          cfg.CORSAllowedOrigins,
+		Methods:          cfg.CORSAllowedMethods,
+		Headers:          cfg.CORSAllowedHeaders,
+		ExposedHeaders:   cfg.CORSExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+	if len(p.Methods) == 0 {
+		p.Methods = DefaultMethods
+	}
+	if len(p.Headers) == 0 {
+		p.Headers = DefaultHeaders
+	}
+	if len(p.ExposedHeaders) == 0 {
+		p.ExposedHeaders = DefaultExposedHeaders
+	}
+	return p
+}
+
+// Middleware applies p. Requests without an Origin header (same-origin
+// GETs, server-to-server clients) pass through untouched.
+func Middleware(p Policy, log *zap.Logger) gin.HandlerFunc {
+	origins := set(p.Origins, strings.ToLower)
+	methods := set(p.Methods, strings.ToUpper)
+	headers := set(p.Headers, strings.ToLower)
+	allowMethods := strings.Join(p.Methods, ", ")
+	allowHeaders := strings.Join(p.Headers, ", ")
+	exposeHeaders := strings.Join(p.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(p.MaxAge / time.Second))
+
+	return func(c *gin.Context) {
+		// The response depends on Origin whether or not it is allowed, so
+		// caches must key on it.
+		c.Writer.Header().Add("Vary", "Origin")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !origins[Any] && !origins[normalize(origin)] {
+			reject(c, log, "origin not allowed", origin)
+			return
+		}
+		allowOrigin := origin
+		if origins[Any] && !p.AllowCredentials {
+			allowOrigin = Any
+		}
+
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			if !methods[strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))] {
+				reject(c, log, "method not allowed", origin)
+				return
+			}
+			for _, h := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+				if h = strings.TrimSpace(h); h != "" && !headers[strings.ToLower(h)] {
+					reject(c, log, "header not allowed", origin)
+					return
+				}
+			}
+			c.Header("Access-Control-Allow-Origin", allowOrigin)
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			if p.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			if p.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowOrigin)
+		if p.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+func reject(c *gin.Context, log *zap.Logger, reason, origin string) {
+	log.Warn("cors request rejected",
+		zap.String("reason", reason),
+		zap.String("origin", origin),
+		zap.String("method", c.Request.Method),
+		zap.String("route", c.FullPath()),
+		zap.String("request_method", c.GetHeader("Access-Control-Request-Method")),
+		zap.String("request_headers", c.GetHeader("Access-Control-Request-Headers")))
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "cors: " + reason})
+}
+
+// normalize lower-cases an origin and drops a trailing slash so that
+// configured and sent origins compare equal.
+func normalize(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+func set(values []string, fold func(string) string) map[string]bool {
+	out := make(map[string]bool, len(values))
+	for _, v := range values {
+		out[fold(normalize(v))] = true
+	}
+	return out
+}
@@ -0,0 +1,212 @@
+// Package fieldcrypt encrypts individual PHI columns (MRN, date of birth,
+// assessment notes) with envelope encryption. Each value gets a fresh
+// AES-256-GCM data key, which is itself sealed with a key-encryption key
+// from a locally configured key ring. Values are bound to their column and
+// row, so ciphertext copied into another column or another row does not
+// decrypt.
+//
+// Stored form: v2:<key id>:<base64 wrapped data key>:<base64 ciphertext>.
+// v1 values, bound to their column only, still decrypt; Reencrypt rewrites
+// them as v2.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+const (
+	version       = "v2"
+	legacyVersion = "v1" // bound to the column only
+)
+
+// Column identifiers, used with the row ID as associated data, and as
+// blind-index domains.
+const (
+	PatientMRN      = "patients.medical_record_number"
+	PatientDOB      = "patients.date_of_birth"
+	AssessmentNotes = "assessments.notes"
+	UserTOTPSecret  = "user_accounts.totp_secret"
+)
+
+var (
+	ErrMalformed  = errors.New("fieldcrypt: malformed ciphertext")
+	ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+)
+
+// KeyRing holds the key-encryption keys. New values are sealed with the
+// current key; any key in the ring can open existing values.
+type KeyRing struct {
+	current  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyRing builds a ring from 32-byte keys. indexKey keys the blind
+// index; it is separate from the ring so rotating keys does not change
+// index values.
+func NewKeyRing(keys map[string][]byte, current string, indexKey []byte) (*KeyRing, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: current key %q is not in the ring", current)
+	}
+	if len(indexKey) < 32 {
+		return nil, errors.New("fieldcrypt: blind index key must be at least 32 bytes")
+	}
+	r := &KeyRing{current: current, keys: map[string]cipher.AEAD{}, indexKey: indexKey}
+	for id, k := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("fieldcrypt: invalid key id %q", id)
+		}
+		aead, err := newGCM(k)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %q: %w", id, err)
+		}
+		r.keys[id] = aead
+	}
+	return r, nil
+}
+
+// FromConfig returns the ring configured in cfg, or nil when field
+// encryption is not configured (development only; see config.Load).
+func FromConfig(cfg *config.Config) (*KeyRing, error) {
+	if len(cfg.FieldKeys) == 0 {
+		return nil, nil
+	}
+	return NewKeyRing(cfg.FieldKeys, cfg.FieldCurrentKey, cfg.FieldIndexKey)
+}
+
+// CurrentKey is the ID of the key new values are sealed with.
+func (r *KeyRing) CurrentKey() string {
+	return r.current
+}
+
+// Encrypt seals plaintext for column of row id.
+func (r *KeyRing) Encrypt(column string, id int64, plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	ad := rowAD(column, id)
+	ct, err := seal(data, []byte(plaintext), []byte(ad))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(r.keys[r.current], dek, []byte(ad+"|"+r.current))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{version, r.current, enc(wrapped), enc(ct)}, ":"), nil
+}
+
+// Decrypt opens a value sealed for column of row id by any key in the ring.
+func (r *KeyRing) Decrypt(column string, id int64, value string) (string, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return "", ErrMalformed
+	}
+	var ad string
+	switch parts[0] {
+	case version:
+		ad = rowAD(column, id)
+	case legacyVersion:
+		ad = column
+	default:
+		return "", ErrMalformed
+	}
+	kek, ok := r.keys[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, parts[1])
+	}
+	wrapped, err1 := base64.RawStdEncoding.DecodeString(parts[2])
+	ct, err2 := base64.RawStdEncoding.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return "", ErrMalformed
+	}
+	dek, err := open(kek, wrapped, []byte(ad+"|"+parts[1]))
+	if err != nil {
+		return "", err
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	pt, err := open(data, ct, []byte(ad))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// rowAD is the associated data binding a value to column of row id.
+func rowAD(column string, id int64) string {
+	return column + "#" + strconv.FormatInt(id, 10)
+}
+
+// KeyID returns the ID of the key value was sealed with, or "" when value
+// is not ciphertext.
+func KeyID(value string) string {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) < 3 || (parts[0] != version && parts[0] != legacyVersion) {
+		return ""
+	}
+	return parts[1]
+}
+
+// BlindIndex is a keyed hash of the normalised value, so exact-match
+// lookups work without storing or revealing the plaintext.
+func (r *KeyRing) BlindIndex(column, value string) string {
+	m := hmac.New(sha256.New, r.indexKey)
+	m.Write([]byte(column))
+	m.Write([]byte{0})
+	m.Write([]byte(strings.ToUpper(strings.TrimSpace(value))))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, pt, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, pt, ad), nil
+}
+
+func open(aead cipher.AEAD, ct, ad []byte) ([]byte, error) {
+	if len(ct) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	pt, err := aead.Open(nil, ct[:aead.NonceSize()], ct[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: decrypt: %w", err)
+	}
+	return pt, nil
+}
+
+func enc(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,237 @@
+package fieldcrypt
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DateLayout is how an encrypted date of birth is stored.
+const DateLayout = "2006-01-02"
+
+// Options control Reencrypt.
+type Options struct {
+	// Batch is the number of rows read and rewritten per transaction.
+	Batch int
+	// DryRun counts the rows that would change without writing.
+	DryRun bool
+	// Decrypt moves values back to the plaintext columns, e.g. before
+	// rolling back the encrypted-column migration.
+	Decrypt bool
+}
+
+// Result counts the rows Reencrypt rewrote (or would rewrite).
+type Result struct {
+	Patients    int
+	Assessments int
+}
+
+// Reencrypt brings every PHI value under the ring's current key: plaintext
+// is encrypted and cleared, and values sealed with older keys or in the v1
+// form are re-sealed. Rows are walked by id in batches, each in its own
+// transaction, so an interrupted run can simply be restarted.
+func Reencrypt(ctx context.Context, db *sql.DB, r *KeyRing, opts Options) (Result, error) {
+	var res Result
+	if opts.Batch <= 0 {
+		opts.Batch = 500
+	}
+	var err error
+	if res.Patients, err = r.reencryptPatients(ctx, db, opts); err != nil {
+		return res, err
+	}
+	res.Assessments, err = r.reencryptAssessments(ctx, db, opts)
+	return res, err
+}
+
+type patientPHI struct {
+	id                   int64
+	mrn                  sql.NullString
+	dob                  sql.NullTime
+	mrnSealed, dobSealed sql.NullString
+}
+
+func (r *KeyRing) reencryptPatients(ctx context.Context, db *sql.DB, opts Options) (int, error) {
+	cond := `medical_record_number IS NOT NULL OR date_of_birth IS NOT NULL OR mrn_encrypted NOT LIKE $3 OR dob_encrypted NOT LIKE $3`
+	if opts.Decrypt {
+		cond = `(mrn_encrypted IS NOT NULL OR dob_encrypted IS NOT NULL)`
+	}
+	query := `SELECT id, medical_record_number, date_of_birth, mrn_encrypted, dob_encrypted FROM patients
+              WHERE id > $1 AND (` + cond + `) ORDER BY id LIMIT $2`
+
+	total := 0
+	var last int64
+	for {
+		rows, err := db.QueryContext(ctx, query, r.batchArgs(last, opts)...)
+		if err != nil {
+			return total, err
+		}
+		var batch []patientPHI
+		for rows.Next() {
+			var p patientPHI
+			if err := rows.Scan(&p.id, &p.mrn, &p.dob, &p.mrnSealed, &p.dobSealed); err != nil {
+				rows.Close()
+				return total, err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+		last = batch[len(batch)-1].id
+		total += len(batch)
+		if opts.DryRun {
+			continue
+		}
+		if err := r.rewritePatients(ctx, db, batch, opts.Decrypt); err != nil {
+			return total, err
+		}
+	}
+}
+
+func (r *KeyRing) rewritePatients(ctx context.Context, db *sql.DB, batch []patientPHI, decrypt bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, p := range batch {
+		mrn, err := r.open(PatientMRN, p.id, p.mrn, p.mrnSealed)
+		if err != nil {
+			return err
+		}
+		dob := sql.NullString{String: p.dob.Time.Format(DateLayout), Valid: p.dob.Valid}
+		if dob, err = r.open(PatientDOB, p.id, dob, p.dobSealed); err != nil {
+			return err
+		}
+
+		var plainMRN, sealedMRN, index, plainDOB, sealedDOB interface{}
+		if decrypt {
+			if mrn.Valid {
+				plainMRN = mrn.String
+			}
+			if dob.Valid {
+				if plainDOB, err = time.Parse(DateLayout, dob.String); err != nil {
+					return err
+				}
+			}
+		} else {
+			if mrn.Valid && mrn.String != "" {
+				if sealedMRN, err = r.Encrypt(PatientMRN, p.id, mrn.String); err != nil {
+					return err
+				}
+				index = r.BlindIndex(PatientMRN, mrn.String)
+			}
+			if dob.Valid {
+				if sealedDOB, err = r.Encrypt(PatientDOB, p.id, dob.String); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE patients SET medical_record_number = $1, mrn_encrypted = $2, mrn_index = $3,
+                                          date_of_birth = $4, dob_encrypted = $5 WHERE id = $6`,
+			plainMRN, sealedMRN, index, plainDOB, sealedDOB, p.id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type assessmentPHI struct {
+	id            int64
+	notes, sealed sql.NullString
+}
+
+func (r *KeyRing) reencryptAssessments(ctx context.Context, db *sql.DB, opts Options) (int, error) {
+	cond := `notes IS NOT NULL OR notes_encrypted NOT LIKE $3`
+	if opts.Decrypt {
+		cond = `notes_encrypted IS NOT NULL`
+	}
+	query := `SELECT id, notes, notes_encrypted FROM assessments
+              WHERE id > $1 AND (` + cond + `) ORDER BY id LIMIT $2`
+
+	total := 0
+	var last int64
+	for {
+		rows, err := db.QueryContext(ctx, query, r.batchArgs(last, opts)...)
+		if err != nil {
+			return total, err
+		}
+		var batch []assessmentPHI
+		for rows.Next() {
+			var a assessmentPHI
+			if err := rows.Scan(&a.id, &a.notes, &a.sealed); err != nil {
+				rows.Close()
+				return total, err
+			}
+			batch = append(batch, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+		last = batch[len(batch)-1].id
+		total += len(batch)
+		if opts.DryRun {
+			continue
+		}
+		if err := r.rewriteAssessments(ctx, db, batch, opts.Decrypt); err != nil {
+			return total, err
+		}
+	}
+}
+
+func (r *KeyRing) rewriteAssessments(ctx context.Context, db *sql.DB, batch []assessmentPHI, decrypt bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, a := range batch {
+		notes, err := r.open(AssessmentNotes, a.id, a.notes, a.sealed)
+		if err != nil {
+			return err
+		}
+		var plain, sealed interface{}
+		if decrypt {
+			if notes.Valid {
+				plain = notes.String
+			}
+		} else if notes.Valid {
+			if sealed, err = r.Encrypt(AssessmentNotes, a.id, notes.String); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE assessments SET notes = $1, notes_encrypted = $2 WHERE id = $3`,
+			plain, sealed, a.id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// open returns the value held by a plaintext/encrypted column pair of row
+// id, preferring the encrypted one.
+func (r *KeyRing) open(column string, id int64, plain, sealed sql.NullString) (sql.NullString, error) {
+	if !sealed.Valid {
+		return plain, nil
+	}
+	v, err := r.Decrypt(column, id, sealed.String)
+	return sql.NullString{String: v, Valid: err == nil}, err
+}
+
+// batchArgs are the query arguments for the batch after id last; when
+// encrypting, $3 matches values already sealed with the current key.
+func (r *KeyRing) batchArgs(last int64, opts Options) []interface{} {
+	args := []interface{}{last, opts.Batch}
+	if !opts.Decrypt {
+		args = append(args, version+":"+r.current+":%")
+	}
+	return args
+}
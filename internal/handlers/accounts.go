@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/totp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// passwordCost is the bcrypt work factor for new hashes.
+	passwordCost = 12
+	// minPasswordLength is in characters; bcrypt ignores anything past 72
+	// bytes, so longer passwords are refused rather than truncated.
+	minPasswordLength = 12
+	maxPasswordBytes  = 72
+	// totpIssuer labels the account in authenticator apps.
+	totpIssuer = "Wound IQ"
+	// localSubjectPrefix marks access tokens minted for local accounts.
+	localSubjectPrefix = "user:"
+)
+
+const userAccountColumns = `id, clinician_id, username, totp_enabled, locked_until, password_changed_at, last_login_at, created_at`
+
+func scanUserAccount(s rowScanner) (models.UserAccount, error) {
+	var u models.UserAccount
+	var locked, lastLogin sql.NullTime
+	if err := s.Scan(&u.ID, &u.ClinicianID, &u.Username, &u.MFAEnabled, &locked, &u.PasswordChangedAt, &lastLogin, &u.CreatedAt); err != nil {
+		return u, err
+	}
+	if locked.Valid && locked.Time.After(time.Now()) {
+		u.Locked = true
+		u.LockedUntil = &locked.Time
+	}
+	if lastLogin.Valid {
+		u.LastLoginAt = &lastLogin.Time
+	}
+	return u, nil
+}
+
+// checkPassword returns why password is unacceptable, or "".
+func checkPassword(password string) string {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return "password must be at least " + strconv.Itoa(minPasswordLength) + " characters"
+	}
+	if len(password) > maxPasswordBytes {
+		return "password must be at most " + strconv.Itoa(maxPasswordBytes) + " bytes"
+	}
+	return ""
+}
+
+func hashPassword(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	return string(b), err
+}
+
+// newOpaqueToken returns a random token for a refresh or reset link and the
+// hash it is stored under. Tokens carry 256 bits of entropy, so a plain
+// SHA-256 is sufficient.
+func newOpaqueToken() (plain, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	plain = hex.EncodeToString(b)
+	return plain, tokenHash(plain), nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sealTOTPSecret encrypts user userID's TOTP secret when field encryption
+// is configured; openTOTPSecret reverses it.
+func (h *Handlers) sealTOTPSecret(userID int64, secret string) (string, error) {
+	if h.Crypt == nil {
+		return secret, nil
+	}
+	return h.encryptField(fieldcrypt.UserTOTPSecret, userID, secret)
+}
+
+func (h *Handlers) openTOTPSecret(userID int64, stored string) (string, error) {
+	if fieldcrypt.KeyID(stored) == "" {
+		return stored, nil
+	}
+	return h.decryptField(fieldcrypt.UserTOTPSecret, userID, sql.NullString{}, sql.NullString{String: stored, Valid: true})
+}
+
+// localAccount returns the user account behind the caller's access token.
+// Callers authenticated any other way, including identity provider tokens
+// whose subject merely looks local, have no account here and get 404.
+func localAccount(c *gin.Context) (int64, bool) {
+	if p, ok := auth.PrincipalFrom(c); ok && p.APIKeyID == 0 && p.Local {
+		if rest, found := strings.CutPrefix(p.Subject, localSubjectPrefix); found {
+			if id, err := strconv.ParseInt(rest, 10, 64); err == nil {
+				return id, true
+			}
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "caller has no local user account"})
+	return 0, false
+}
+
+// ListUserAccounts GET /v1/users
+func (h *Handlers) ListUserAccounts(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+
+	rows, err := h.DB.Query(`SELECT `+userAccountColumns+` FROM user_accounts ORDER BY id DESC LIMIT $1 OFFSET $2`, pageSize, offset)
+	if err != nil {
+		h.Log.Sugar().Errorf("list user accounts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user accounts"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.UserAccount{}
+	for rows.Next() {
+		u, err := scanUserAccount(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan user account: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read user accounts"})
+			return
+		}
+		out = append(out, u)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
+
+// CreateUserAccount POST /v1/users
+// The clinician needs an email address: access tokens carry it, and it is
+// how each request is linked back to the clinician and their role.
+func (h *Handlers) CreateUserAccount(c *gin.Context) {
+	var in struct {
+		ClinicianID int64  `json:"clinician_id" binding:"required"`
+		Username    string `json:"username" binding:"required"`
+		Password    string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	in.Username = strings.TrimSpace(in.Username)
+	if in.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is required"})
+		return
+	}
+	if msg := checkPassword(in.Password); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var email string
+	err := h.DB.QueryRow(`SELECT COALESCE(email, '') FROM clinicians WHERE id = $1`, in.ClinicianID).Scan(&email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clinician not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("create user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user account"})
+		return
+	}
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clinician needs an email address before they can log in"})
+		return
+	}
+
+	hash, err := hashPassword(in.Password)
+	if err != nil {
+		h.Log.Sugar().Errorf("create user account: hash password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user account"})
+		return
+	}
+	u, err := scanUserAccount(h.DB.QueryRow(`INSERT INTO user_accounts (clinician_id, username, password_hash, created_at, updated_at)
+                                               SELECT $1, $2, $3, now(), now()
+                                               WHERE NOT EXISTS (SELECT 1 FROM user_accounts
+                                                                 WHERE clinician_id = $1 OR lower(username) = lower($2))
+                                               RETURNING `+userAccountColumns, in.ClinicianID, in.Username, hash))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "username is taken or clinician already has an account"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("create user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user account"})
+		return
+	}
+	c.JSON(http.StatusCreated, u)
+}
+
+// DeleteUserAccount DELETE /v1/users/:id
+// The clinician record stays; only the login goes, and its sessions are
+// revoked.
+func (h *Handlers) DeleteUserAccount(c *gin.Context) {
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("delete user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user account"})
+		return
+	}
+	defer tx.Rollback()
+	revoked, err := revokeSessions(c, tx, "account deleted", `user_id = $2`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("delete user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user account"})
+		return
+	}
+	res, err := tx.Exec(`DELETE FROM user_accounts WHERE id = $1`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("delete user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user account"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("delete user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user account"})
+		return
+	}
+	h.Sessions.Add(revoked...)
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockUserAccount POST /v1/users/:id/unlock
+func (h *Handlers) UnlockUserAccount(c *gin.Context) {
+	res, err := h.DB.Exec(`UPDATE user_accounts SET failed_logins = 0, locked_until = NULL, updated_at = now()
+                           WHERE id = $1`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("unlock user account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user account"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResetUserMFA DELETE /v1/users/:id/mfa
+// For a user who has lost their authenticator; they can enrol again after
+// their next login.
+func (h *Handlers) ResetUserMFA(c *gin.Context) {
+	res, err := h.DB.Exec(`UPDATE user_accounts SET totp_secret = NULL, totp_enabled = false, totp_last_step = NULL, updated_at = now()
+                           WHERE id = $1`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("reset mfa: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset mfa"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreatePasswordReset POST /v1/users/:id/password-reset
+// Issues a single-use reset token for an admin to hand to the user. The
+// token is in the response and cannot be retrieved again.
+func (h *Handlers) CreatePasswordReset(c *gin.Context) {
+	var createdBy interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		createdBy = p.ClinicianID
+	}
+	plain, hash, err := newOpaqueToken()
+	if err != nil {
+		h.Log.Sugar().Errorf("create password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create password reset"})
+		return
+	}
+	var expires time.Time
+	err = h.DB.QueryRow(`INSERT INTO password_reset_tokens (user_id, token_hash, created_by, created_at, expires_at)
+                         SELECT id, $2, $3, now(), now() + $4 * interval '1 second' FROM user_accounts WHERE id = $1
+                         RETURNING expires_at`,
+		c.Param("id"), hash, createdBy, int64(h.Cfg.PasswordResetTTL/time.Second)).Scan(&expires)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("create password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create password reset"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": plain, "expires_at": expires})
+}
+
+// ChangePassword POST /v1/auth/password
+// Signs out the user's other sessions; the current one continues.
+func (h *Handlers) ChangePassword(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := checkPassword(in.NewPassword); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	var current string
+	var lockedUntil sql.NullTime
+	err := h.DB.QueryRow(`SELECT password_hash, locked_until FROM user_accounts WHERE id = $1`, userID).Scan(&current, &lockedUntil)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("change password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	// Guesses here count towards the login lockout, and a locked account
+	// cannot change its password: a stolen access token must not become a
+	// way round LOGIN_MAX_FAILURES.
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		c.JSON(http.StatusLocked, gin.H{"error": "account is locked after repeated failed logins", "locked_until": lockedUntil.Time})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(in.CurrentPassword)) != nil {
+		h.recordLoginFailure(userID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "current password is incorrect"})
+		return
+	}
+	hash, err := hashPassword(in.NewPassword)
+	if err != nil {
+		h.Log.Sugar().Errorf("change password: hash password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("change password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	defer tx.Rollback()
+	p, _ := auth.PrincipalFrom(c)
+	revoked, err := setPassword(c, tx, userID, hash, p.SessionID)
+	if err != nil {
+		h.Log.Sugar().Errorf("change password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("change password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	h.Sessions.Add(revoked...)
+	c.Status(http.StatusNoContent)
+}
+
+// setPassword stores a new password hash, clears any lockout and revokes
+// the user's sessions other than keep, returning the revoked IDs.
+func setPassword(c *gin.Context, tx *sql.Tx, userID int64, hash string, keep int64) ([]int64, error) {
+	if _, err := tx.Exec(`UPDATE user_accounts SET password_hash = $2, password_changed_at = now(), failed_logins = 0,
+                                                  locked_until = NULL, updated_at = now()
+                          WHERE id = $1`, userID, hash); err != nil {
+		return nil, err
+	}
+	return revokeSessions(c, tx, "password changed", `user_id = $2 AND id <> $3`, userID, keep)
+}
+
+// EnrollMFA POST /v1/auth/mfa/enroll
+// Starts TOTP enrolment. The secret only takes effect once a code from it
+// is confirmed with VerifyMFA; enrolling again before then replaces it.
+func (h *Handlers) EnrollMFA(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		h.Log.Sugar().Errorf("enroll mfa: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+	sealed, err := h.sealTOTPSecret(userID, secret)
+	if err != nil {
+		h.Log.Sugar().Errorf("enroll mfa: seal secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+	var username string
+	err = h.DB.QueryRow(`UPDATE user_accounts SET totp_secret = $2, totp_last_step = NULL, updated_at = now()
+                         WHERE id = $1 AND NOT totp_enabled RETURNING username`, userID, sealed).Scan(&username)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("enroll mfa: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll mfa"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": totp.URI(totpIssuer, username, secret)})
+}
+
+// VerifyMFA POST /v1/auth/mfa/verify
+// Confirms enrolment with a code from the authenticator app; from then on
+// every login needs a code.
+func (h *Handlers) VerifyMFA(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var stored sql.NullString
+	var enabled bool
+	err := h.DB.QueryRow(`SELECT totp_secret, totp_enabled FROM user_accounts WHERE id = $1`, userID).Scan(&stored, &enabled)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("verify mfa: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify mfa"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+		return
+	}
+	if !stored.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "start enrolment with POST /v1/auth/mfa/enroll first"})
+		return
+	}
+	secret, err := h.openTOTPSecret(userID, stored.String)
+	if err != nil {
+		h.Log.Sugar().Errorf("verify mfa: open secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify mfa"})
+		return
+	}
+	step, ok := totp.Validate(secret, in.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+	if _, err := h.DB.Exec(`UPDATE user_accounts SET totp_enabled = true, totp_last_step = $2, updated_at = now()
+                           WHERE id = $1`, userID, step); err != nil {
+		h.Log.Sugar().Errorf("verify mfa: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify mfa"})
+		return
+	}
+	h.Log.Info("mfa enabled", zap.Int64("user_id", userID))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/apikey"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAPIKey(s rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes []byte
+	var createdBy sql.NullInt64
+	var expires, lastUsed, revoked sql.NullTime
+	if err := s.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &createdBy, &k.CreatedAt, &expires, &lastUsed, &revoked); err != nil {
+		return k, err
+	}
+	if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+		return k, err
+	}
+	if createdBy.Valid {
+		v := createdBy.Int64
+		k.CreatedBy = &v
+	}
+	for _, f := range []struct {
+		src sql.NullTime
+		dst **time.Time
+	}{{expires, &k.ExpiresAt}, {lastUsed, &k.LastUsedAt}, {revoked, &k.RevokedAt}} {
+		if f.src.Valid {
+			v := f.src.Time
+			*f.dst = &v
+		}
+	}
+	return k, nil
+}
+
+// AuthenticateAPIKey is middleware that authenticates requests carrying an
+// X-API-Key header. Invalid, revoked and expired keys get 401; requests
+// without the header continue to bearer-token authentication.
+func (h *Handlers) AuthenticateAPIKey(c *gin.Context) {
+	key := c.GetHeader(apikey.Header)
+	if key == "" {
+		c.Next()
+		return
+	}
+	prefix, ok := apikey.Split(key)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+		return
+	}
+
+	var id int64
+	var name, hash string
+	var scopes []byte
+	var expires, revoked sql.NullTime
+	err := h.DB.QueryRowContext(c.Request.Context(), `SELECT id, name, key_hash, scopes, expires_at, revoked_at
+                                                      FROM api_keys WHERE prefix = $1`, prefix).
+		Scan(&id, &name, &hash, &scopes, &expires, &revoked)
+	if err != nil && err != sql.ErrNoRows {
+		h.Log.Sugar().Errorf("authenticate api key: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to authenticate"})
+		return
+	}
+	if err == sql.ErrNoRows || !apikey.Matches(key, hash) || revoked.Valid || (expires.Valid && time.Now().After(expires.Time)) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+		return
+	}
+
+	p := &auth.Principal{Subject: "api-key:" + strconv.FormatInt(id, 10), Name: name, APIKeyID: id}
+	if err := json.Unmarshal(scopes, &p.Scopes); err != nil {
+		h.Log.Sugar().Errorf("authenticate api key %d: scopes: %v", id, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to authenticate"})
+		return
+	}
+	if expires.Valid {
+		p.ExpiresAt = expires.Time
+	}
+	// Coarse last-used tracking: at most one write per key per minute.
+	if _, err := h.DB.Exec(`UPDATE api_keys SET last_used_at = now()
+                           WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, id); err != nil {
+		h.Log.Sugar().Errorf("api key last used: %v", err)
+	}
+	auth.SetPrincipal(c, p)
+	c.Next()
+}
+
+// ListAPIKeys GET /v1/api-keys
+func (h *Handlers) ListAPIKeys(c *gin.Context) {
+	rows, err := h.DB.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id DESC`)
+	if err != nil {
+		h.Log.Sugar().Errorf("list api keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch api keys"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan api key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read api keys"})
+			return
+		}
+		out = append(out, k)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// CreateAPIKey POST /v1/api-keys
+// The plaintext key is in the response and cannot be retrieved again.
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	var in struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes" binding:"required,min=1"`
+		ExpiresAt string   `json:"expires_at"` // ISO-8601 expected
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, s := range in.Scopes {
+		if !apikey.ValidScope(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope " + s})
+			return
+		}
+	}
+	var expiresParam interface{}
+	if in.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, in.ExpiresAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+		if !t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		expiresParam = t
+	}
+	var createdBy interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		createdBy = p.ClinicianID
+	}
+
+	key, err := apikey.Generate()
+	if err != nil {
+		h.Log.Sugar().Errorf("create api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+	scopes, _ := json.Marshal(uniqueStrings(in.Scopes))
+	k, err := scanAPIKey(h.DB.QueryRow(`INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, created_at, expires_at)
+                                         VALUES ($1, $2, $3, $4, $5, now(), $6) RETURNING `+apiKeyColumns,
+		in.Name, key.Prefix, key.Hash, string(scopes), createdBy, expiresParam))
+	if err != nil {
+		h.Log.Sugar().Errorf("create api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+	k.Key = key.Plaintext
+	c.JSON(http.StatusCreated, k)
+}
+
+// RevokeAPIKey DELETE /v1/api-keys/:id
+// Keys are revoked rather than deleted so last-used history survives.
+func (h *Handlers) RevokeAPIKey(c *gin.Context) {
+	res, err := h.DB.Exec(`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("revoke api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func uniqueStrings(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
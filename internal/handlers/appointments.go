@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const appointmentColumns = `id, patient_id, clinician_id, wound_id, starts_at, ends_at, location, visit_type, status, assessment_id, created_at, updated_at`
+
+func scanAppointment(s rowScanner) (models.Appointment, error) {
+	var a models.Appointment
+	var woundID, assessmentID sql.NullInt64
+	if err := s.Scan(&a.ID, &a.PatientID, &a.ClinicianID, &woundID, &a.StartsAt, &a.EndsAt, &a.Location, &a.VisitType,
+		&a.Status, &assessmentID, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return a, err
+	}
+	if woundID.Valid {
+		v := woundID.Int64
+		a.WoundID = &v
+	}
+	if assessmentID.Valid {
+		v := assessmentID.Int64
+		a.AssessmentID = &v
+	}
+	return a, nil
+}
+
+func (h *Handlers) queryAppointments(query string, args ...interface{}) ([]models.Appointment, error) {
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Appointment{}
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// blocksCalendar reports whether an appointment in this status occupies the
+// clinician's time.
+func blocksCalendar(status string) bool {
+	return status == models.AppointmentStatusScheduled || status == models.AppointmentStatusCompleted
+}
+
+// checkClinicianAvailable locks the clinician row, serialising concurrent
+// bookings for the same clinician, then looks for an overlapping
+// appointment. It writes the error response and returns false when the slot
+// cannot be booked.
+func (h *Handlers) checkClinicianAvailable(c *gin.Context, tx *sql.Tx, clinicianID int64, start, end time.Time, excludeID int64) bool {
+	var locked int64
+	err := tx.QueryRow(`SELECT id FROM clinicians WHERE id = $1 FOR UPDATE`, clinicianID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clinician not found"})
+		return false
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("check clinician availability: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check availability"})
+		return false
+	}
+
+	conflict, err := scanAppointment(tx.QueryRow(`SELECT `+appointmentColumns+` FROM appointments
+                                                  WHERE clinician_id = $1 AND id <> $2
+                                                    AND status IN ('scheduled', 'completed')
+                                                    AND starts_at < $4 AND ends_at > $3
+                                                  ORDER BY starts_at LIMIT 1`, clinicianID, excludeID, start, end))
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("check clinician availability: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check availability"})
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "clinician is already booked for this time", "conflicting_appointment": conflict})
+	return false
+}
+
+// ListAppointments GET /v1/appointments?patient_id=&clinician_id=&status=&from=&to=&page=&page_size=
+func (h *Handlers) ListAppointments(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+
+	var args []interface{}
+	where := []string{}
+	idx := 1
+
+	for _, f := range []string{"patient_id", "clinician_id", "status"} {
+		if v := c.Query(f); v != "" {
+			where = append(where, f+" = $"+strconv.Itoa(idx))
+			args = append(args, v)
+			idx++
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			where = append(where, "ends_at > $"+strconv.Itoa(idx))
+			args = append(args, t)
+			idx++
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			where = append(where, "starts_at < $"+strconv.Itoa(idx))
+			args = append(args, t)
+			idx++
+		}
+	}
+
+	where, args, idx = scoped(c, "appointments.patient_id", where, args, idx)
+
+	base := `SELECT ` + appointmentColumns + ` FROM appointments`
+	if len(where) > 0 {
+		base += " WHERE " + strings.Join(where, " AND ")
+	}
+	base += " ORDER BY starts_at DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	args = append(args, pageSize, offset)
+
+	out, err := h.queryAppointments(base, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("list appointments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch appointments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
+
+// GetClinicianSchedule GET /v1/clinicians/:id/schedule?from=&to=&include_cancelled=
+// Defaults to the seven days starting today (UTC).
+func (h *Handlers) GetClinicianSchedule(c *gin.Context) {
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be ISO-8601 (RFC3339)"})
+			return
+		}
+		from = t
+	}
+	to := from.Add(7 * 24 * time.Hour)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be ISO-8601 (RFC3339)"})
+			return
+		}
+		to = t
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	query := `SELECT ` + appointmentColumns + ` FROM appointments
+              WHERE clinician_id = $1 AND starts_at < $3 AND ends_at > $2`
+	if c.Query("include_cancelled") != "true" {
+		query += ` AND status IN ('scheduled', 'completed')`
+	}
+	query, args := withAccess(c, query, "appointments.patient_id", c.Param("id"), from, to)
+	out, err := h.queryAppointments(query+` ORDER BY starts_at`, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("clinician schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch schedule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clinician_id": c.Param("id"), "from": from, "to": to, "data": out})
+}
+
+// GetAppointment GET /v1/appointments/:id
+func (h *Handlers) GetAppointment(c *gin.Context) {
+	query, args := withAccess(c, `SELECT `+appointmentColumns+` FROM appointments WHERE id=$1`, "appointments.patient_id", c.Param("id"))
+	a, err := scanAppointment(h.DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, appointmentRestricted, c.Param("id"), "appointment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("get appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get appointment"})
+		return
+	}
+	audit.SetPatient(c, a.PatientID)
+	c.JSON(http.StatusOK, a)
+}
+
+// CreateAppointment POST /v1/appointments
+// Returns 409 with the conflicting appointment when the clinician is double-booked.
+func (h *Handlers) CreateAppointment(c *gin.Context) {
+	var in struct {
+		PatientID   int64  `json:"patient_id" binding:"required"`
+		ClinicianID int64  `json:"clinician_id" binding:"required"`
+		WoundID     *int64 `json:"wound_id"`
+		StartsAt    string `json:"starts_at" binding:"required"` // ISO-8601 expected
+		EndsAt      string `json:"ends_at" binding:"required"`
+		Location    string `json:"location"`
+		VisitType   string `json:"visit_type" binding:"required,oneof=initial follow_up dressing_change telehealth"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startsAt, err := time.Parse(time.RFC3339, in.StartsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at must be ISO-8601 (RFC3339)"})
+		return
+	}
+	endsAt, err := time.Parse(time.RFC3339, in.EndsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be ISO-8601 (RFC3339)"})
+		return
+	}
+	if !endsAt.After(startsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+	if !h.checkPatientAccess(c, in.PatientID) {
+		return
+	}
+	audit.SetPatient(c, in.PatientID)
+	if in.WoundID != nil && !h.checkWoundPatient(c, *in.WoundID, in.PatientID) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("create appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create appointment"})
+		return
+	}
+	defer tx.Rollback()
+
+	if !h.checkClinicianAvailable(c, tx, in.ClinicianID, startsAt, endsAt, 0) {
+		return
+	}
+	var newID int64
+	err = tx.QueryRow(`INSERT INTO appointments (patient_id, clinician_id, wound_id, starts_at, ends_at, location, visit_type, status, created_at, updated_at)
+                       VALUES ($1, $2, $3, $4, $5, $6, $7, 'scheduled', now(), now()) RETURNING id`,
+		in.PatientID, in.ClinicianID, in.WoundID, startsAt, endsAt, in.Location, in.VisitType).Scan(&newID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("create appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create appointment"})
+		return
+	}
+	audit.SetResource(c, newID)
+	c.JSON(http.StatusCreated, gin.H{"id": newID})
+}
+
+// UpdateAppointment PUT /v1/appointments/:id
+// Rescheduling, reassigning or reactivating re-runs conflict detection.
+func (h *Handlers) UpdateAppointment(c *gin.Context) {
+	var in struct {
+		ClinicianID *int64  `json:"clinician_id"`
+		StartsAt    *string `json:"starts_at"`
+		EndsAt      *string `json:"ends_at"`
+		Location    *string `json:"location"`
+		VisitType   *string `json:"visit_type" binding:"omitempty,oneof=initial follow_up dressing_change telehealth"`
+		Status      *string `json:"status" binding:"omitempty,oneof=scheduled completed cancelled no_show"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("update appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update appointment"})
+		return
+	}
+	defer tx.Rollback()
+
+	query, args := withAccess(c, `SELECT `+appointmentColumns+` FROM appointments WHERE id=$1`, "appointments.patient_id", c.Param("id"))
+	cur, err := scanAppointment(tx.QueryRow(query+` FOR UPDATE`, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, appointmentRestricted, c.Param("id"), "appointment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("update appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update appointment"})
+		return
+	}
+	audit.SetPatient(c, cur.PatientID)
+
+	next := cur
+	if in.ClinicianID != nil {
+		next.ClinicianID = *in.ClinicianID
+	}
+	if in.StartsAt != nil {
+		if next.StartsAt, err = time.Parse(time.RFC3339, *in.StartsAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+	}
+	if in.EndsAt != nil {
+		if next.EndsAt, err = time.Parse(time.RFC3339, *in.EndsAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+	}
+	if in.Location != nil {
+		next.Location = *in.Location
+	}
+	if in.VisitType != nil {
+		next.VisitType = *in.VisitType
+	}
+	if in.Status != nil {
+		next.Status = *in.Status
+	}
+	if !next.EndsAt.After(next.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+
+	moved := next.ClinicianID != cur.ClinicianID || !next.StartsAt.Equal(cur.StartsAt) || !next.EndsAt.Equal(cur.EndsAt)
+	reactivated := !blocksCalendar(cur.Status) && blocksCalendar(next.Status)
+	if blocksCalendar(next.Status) && (moved || reactivated) {
+		if !h.checkClinicianAvailable(c, tx, next.ClinicianID, next.StartsAt, next.EndsAt, cur.ID) {
+			return
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE appointments SET clinician_id = $1, starts_at = $2, ends_at = $3, location = $4,
+                              visit_type = $5, status = $6, updated_at = now()
+                      WHERE id = $7`,
+		next.ClinicianID, next.StartsAt, next.EndsAt, next.Location, next.VisitType, next.Status, cur.ID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("update appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update appointment"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteAppointment DELETE /v1/appointments/:id
+func (h *Handlers) DeleteAppointment(c *gin.Context) {
+	query, args := withAccess(c, `DELETE FROM appointments WHERE id = $1`, "appointments.patient_id", c.Param("id"))
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, appointmentRestricted, c.Param("id"), "appointment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete appointment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete appointment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAppointmentAssessment POST /v1/appointments/:id/assessment
+// Turns a completed appointment into an assessment pre-filled with the
+// appointment's patient, clinician and wound. The body accepts the optional
+// CreateAssessment fields (notes, exudate_amount, tissue_type, measurement).
+func (h *Handlers) CreateAppointmentAssessment(c *gin.Context) {
+	var in struct {
+		WoundID       *int64            `json:"wound_id"`
+		Notes         string            `json:"notes"`
+		ExudateAmount *string           `json:"exudate_amount" binding:"omitempty,oneof=none light moderate heavy"`
+		TissueType    *string           `json:"tissue_type" binding:"omitempty,oneof=closed epithelial granulation slough necrotic"`
+		Measurement   *measurementInput `json:"measurement"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("appointment assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	defer tx.Rollback()
+
+	query, args := withAccess(c, `SELECT `+appointmentColumns+` FROM appointments WHERE id=$1`, "appointments.patient_id", c.Param("id"))
+	appt, err := scanAppointment(tx.QueryRow(query+` FOR UPDATE`, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, appointmentRestricted, c.Param("id"), "appointment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("appointment assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	audit.SetPatient(c, appt.PatientID)
+	if appt.Status != models.AppointmentStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "only completed appointments can be turned into assessments"})
+		return
+	}
+	if appt.AssessmentID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "appointment already has an assessment", "assessment_id": *appt.AssessmentID})
+		return
+	}
+
+	woundID := appt.WoundID
+	if in.WoundID != nil {
+		woundID = in.WoundID
+	}
+	newID, ok := h.createAssessment(c, tx, assessmentInput{
+		PatientID:     appt.PatientID,
+		ClinicianID:   appt.ClinicianID,
+		WoundID:       woundID,
+		Notes:         in.Notes,
+		ExudateAmount: in.ExudateAmount,
+		TissueType:    in.TissueType,
+		Measurement:   in.Measurement,
+	})
+	if !ok {
+		return
+	}
+	_, err = tx.Exec(`UPDATE appointments SET assessment_id = $1, updated_at = now() WHERE id = $2`, newID, appt.ID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("appointment assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": newID, "appointment_id": appt.ID})
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
 )
 
@@ -21,35 +25,40 @@ func (h *Handlers) ListAssessments(c *gin.Context) {
 	idx := 1
 
 	if v := c.Query("patient_id"); v != "" {
-		where = append(where, "patient_id = $"+strconv.Itoa(idx))
+		where = append(where, "a.patient_id = $"+strconv.Itoa(idx))
 		args = append(args, v)
 		idx++
 	}
 	if v := c.Query("clinician_id"); v != "" {
-		where = append(where, "clinician_id = $"+strconv.Itoa(idx))
+		where = append(where, "a.clinician_id = $"+strconv.Itoa(idx))
 		args = append(args, v)
 		idx++
 	}
 	if v := c.Query("date_from"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			where = append(where, "created_at >= $"+strconv.Itoa(idx))
+			where = append(where, "a.created_at >= $"+strconv.Itoa(idx))
 			args = append(args, t)
 			idx++
 		}
 	}
 	if v := c.Query("date_to"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			where = append(where, "created_at <= $"+strconv.Itoa(idx))
+			where = append(where, "a.created_at <= $"+strconv.Itoa(idx))
 			args = append(args, t)
 			idx++
 		}
 	}
 
-	base := `SELECT id, patient_id, clinician_id, wound_id, notes, created_at, updated_at FROM assessments`
+	where, args, idx = scoped(c, "a.patient_id", where, args, idx)
+
+	base := `SELECT a.id, a.patient_id, a.clinician_id, a.wound_id, a.notes, a.notes_encrypted,
+                    COALESCE(a.exudate_amount, ''), COALESCE(a.tissue_type, ''),
+                    a.created_at, a.updated_at, m.length_cm, m.width_cm
+             FROM assessments a LEFT JOIN wound_measurements m ON m.assessment_id = a.id`
 	if len(where) > 0 {
 		base += " WHERE " + strings.Join(where, " AND ")
 	}
-	base += " ORDER BY id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	base += " ORDER BY a.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
 	args = append(args, pageSize, offset)
 
 	rows, err := h.DB.Query(base, args...)
@@ -64,7 +73,13 @@ func (h *Handlers) ListAssessments(c *gin.Context) {
 	for rows.Next() {
 		var a models.Assessment
 		var woundID sql.NullInt64
-		if err := rows.Scan(&a.ID, &a.PatientID, &a.ClinicianID, &woundID, &a.Notes, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		var notes, notesSealed sql.NullString
+		var length, width sql.NullFloat64
+		err := rows.Scan(&a.ID, &a.PatientID, &a.ClinicianID, &woundID, &notes, &notesSealed, &a.ExudateAmount, &a.TissueType, &a.CreatedAt, &a.UpdatedAt, &length, &width)
+		if err == nil {
+			a.Notes, err = h.decryptField(fieldcrypt.AssessmentNotes, a.ID, notes, notesSealed)
+		}
+		if err != nil {
 			h.Log.Sugar().Errorf("scan assessment: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read assessments"})
 			return
@@ -73,6 +88,9 @@ func (h *Handlers) ListAssessments(c *gin.Context) {
 			v := woundID.Int64
 			a.WoundID = &v
 		}
+		if length.Valid && width.Valid {
+			a.PushScore = pushScore(length.Float64, width.Float64, a.ExudateAmount, a.TissueType)
+		}
 		out = append(out, a)
 	}
 	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
@@ -83,72 +101,236 @@ func (h *Handlers) GetAssessment(c *gin.Context) {
 	id := c.Param("id")
 	var a models.Assessment
 	var woundID sql.NullInt64
-	row := h.DB.QueryRow(`SELECT id, patient_id, clinician_id, wound_id, notes, created_at, updated_at FROM assessments WHERE id=$1`, id)
-	if err := row.Scan(&a.ID, &a.PatientID, &a.ClinicianID, &woundID, &a.Notes, &a.CreatedAt, &a.UpdatedAt); err != nil {
+	var notes, notesSealed sql.NullString
+	query := `SELECT id, patient_id, clinician_id, wound_id, notes, notes_encrypted, COALESCE(exudate_amount, ''), COALESCE(tissue_type, ''), created_at, updated_at
+              FROM assessments WHERE id=$1`
+	args := []interface{}{id}
+	if cond, extra := accessFilter(c, "assessments.patient_id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	row := h.DB.QueryRow(query, args...)
+	err := row.Scan(&a.ID, &a.PatientID, &a.ClinicianID, &woundID, &notes, &notesSealed, &a.ExudateAmount, &a.TissueType, &a.CreatedAt, &a.UpdatedAt)
+	if err == nil {
+		a.Notes, err = h.decryptField(fieldcrypt.AssessmentNotes, a.ID, notes, notesSealed)
+	}
+	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "assessment not found"})
+			h.refuseAccess(c, assessmentRestricted, id, "assessment not found")
 			return
 		}
 		h.Log.Sugar().Errorf("get assessment: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get assessment"})
 		return
 	}
+	audit.SetPatient(c, a.PatientID)
 	if woundID.Valid {
 		v := woundID.Int64
 		a.WoundID = &v
 	}
+	m, err := h.loadMeasurement(a.ID)
+	if err != nil {
+		h.Log.Sugar().Errorf("get assessment measurement: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get assessment"})
+		return
+	}
+	a.Measurement = m
+	if m != nil {
+		a.PushScore = pushScore(m.LengthCm, m.WidthCm, a.ExudateAmount, a.TissueType)
+	}
 	c.JSON(http.StatusOK, a)
 }
 
+// assessmentInput is the body of CreateAssessment.
+type assessmentInput struct {
+	PatientID     int64             `json:"patient_id" binding:"required"`
+	ClinicianID   int64             `json:"clinician_id" binding:"required"`
+	WoundID       *int64            `json:"wound_id"`
+	Notes         string            `json:"notes"`
+	ExudateAmount *string           `json:"exudate_amount" binding:"omitempty,oneof=none light moderate heavy"`
+	TissueType    *string           `json:"tissue_type" binding:"omitempty,oneof=closed epithelial granulation slough necrotic"`
+	Measurement   *measurementInput `json:"measurement"`
+}
+
 // CreateAssessment POST /v1/assessments
-// Optionally calls add_full_assessment if available
+// See CreateFullAssessment for the nested variant.
 func (h *Handlers) CreateAssessment(c *gin.Context) {
-	var in struct {
-		PatientID   int64  `json:"patient_id" binding:"required"`
-		ClinicianID int64  `json:"clinician_id" binding:"required"`
-		WoundID     *int64 `json:"wound_id"`
-		Notes       string `json:"notes"`
-	}
+	var in assessmentInput
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// If DB has add_full_assessment function that accepts JSON or fields, adapt accordingly.
-	var newID int64
-	err := h.DB.QueryRow(`INSERT INTO assessments (patient_id, clinician_id, wound_id, notes, created_at, updated_at)
-                          VALUES ($1, $2, $3, $4, now(), now()) RETURNING id`,
-		in.PatientID, in.ClinicianID, in.WoundID, in.Notes).Scan(&newID)
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
 	if err != nil {
 		h.Log.Sugar().Errorf("create assessment: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
 		return
 	}
+	defer tx.Rollback()
+
+	newID, ok := h.createAssessment(c, tx, in)
+	if !ok {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("create assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	audit.SetResource(c, newID)
+	audit.SetPatient(c, in.PatientID)
 	c.JSON(http.StatusCreated, gin.H{"id": newID})
 }
 
+// createAssessment validates in and inserts the assessment and its
+// measurement inside tx. On failure it writes the error response and
+// returns false; the caller owns the transaction.
+func (h *Handlers) createAssessment(c *gin.Context, tx *sql.Tx, in assessmentInput) (int64, bool) {
+	if !h.checkAssessmentInput(c, in) {
+		return 0, false
+	}
+	return h.insertAssessment(c, tx, in)
+}
+
+// insertAssessment is createAssessment for input checkAssessmentInput has
+// already accepted.
+func (h *Handlers) insertAssessment(c *gin.Context, tx *sql.Tx, in assessmentInput) (int64, bool) {
+	// Sealed notes are bound to the row, so with field encryption on they
+	// are set once the insert has assigned the ID.
+	var notes interface{} = in.Notes
+	if h.Crypt != nil {
+		notes = nil
+	}
+	var newID int64
+	err := tx.QueryRow(`INSERT INTO assessments (patient_id, clinician_id, wound_id, notes, notes_encrypted, exudate_amount, tissue_type, created_at, updated_at)
+                          VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now()) RETURNING id`,
+		in.PatientID, in.ClinicianID, in.WoundID, notes, nil, in.ExudateAmount, in.TissueType).Scan(&newID)
+	if err != nil {
+		h.Log.Sugar().Errorf("create assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return 0, false
+	}
+	if h.Crypt != nil {
+		_, sealed, err := h.sealNotes(newID, &in.Notes)
+		if err != nil {
+			h.Log.Sugar().Errorf("encrypt assessment notes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+			return 0, false
+		}
+		if _, err := tx.Exec(`UPDATE assessments SET notes_encrypted = $1 WHERE id = $2`, sealed, newID); err != nil {
+			h.Log.Sugar().Errorf("create assessment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+			return 0, false
+		}
+	}
+	if in.Measurement != nil {
+		m := in.Measurement.toModel()
+		if err := insertMeasurement(tx, newID, &m); err != nil {
+			h.Log.Sugar().Errorf("create measurement: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+			return 0, false
+		}
+	}
+	return newID, true
+}
+
+// checkAssessmentInput writes the error response and returns false when in
+// cannot be inserted.
+func (h *Handlers) checkAssessmentInput(c *gin.Context, in assessmentInput) bool {
+	// New assessments of enrolled patients reach researchers.
+	if !h.checkPatientAccess(c, in.PatientID) || !h.requireEnrolmentConsent(c, in.PatientID) {
+		return false
+	}
+	if in.Measurement != nil && in.WoundID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wound_id is required when measurement is provided"})
+		return false
+	}
+	if in.Measurement != nil && !in.Measurement.fits() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "measurement area or volume is too large"})
+		return false
+	}
+	if in.WoundID != nil {
+		return h.checkWoundPatient(c, *in.WoundID, in.PatientID)
+	}
+	return true
+}
+
 // UpdateAssessment PUT /v1/assessments/:id
 func (h *Handlers) UpdateAssessment(c *gin.Context) {
 	id := c.Param("id")
 	var in struct {
-		PatientID   *int64  `json:"patient_id"`
-		ClinicianID *int64  `json:"clinician_id"`
-		WoundID     *int64  `json:"wound_id"`
-		Notes       *string `json:"notes"`
+		PatientID     *int64  `json:"patient_id"`
+		ClinicianID   *int64  `json:"clinician_id"`
+		WoundID       *int64  `json:"wound_id"`
+		Notes         *string `json:"notes"`
+		ExudateAmount *string `json:"exudate_amount" binding:"omitempty,oneof=none light moderate heavy"`
+		TissueType    *string `json:"tissue_type" binding:"omitempty,oneof=closed epithelial granulation slough necrotic"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !h.checkAssessmentAccess(c, id) {
+		return
+	}
+	if in.PatientID != nil && (!h.checkPatientAccess(c, *in.PatientID) || !h.requireEnrolmentConsent(c, *in.PatientID)) {
+		return
+	}
+
+	// Re-check wound ownership whenever either side of the link changes.
+	if in.WoundID != nil || in.PatientID != nil {
+		var curPatient int64
+		var curWound sql.NullInt64
+		err := h.DB.QueryRow(`SELECT patient_id, wound_id FROM assessments WHERE id = $1`, id).Scan(&curPatient, &curWound)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "assessment not found"})
+				return
+			}
+			h.Log.Sugar().Errorf("update assessment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update assessment"})
+			return
+		}
+		patientID := curPatient
+		if in.PatientID != nil {
+			patientID = *in.PatientID
+		}
+		if in.WoundID != nil {
+			curWound = sql.NullInt64{Int64: *in.WoundID, Valid: true}
+		}
+		if curWound.Valid && !h.checkWoundPatient(c, curWound.Int64, patientID) {
+			return
+		}
+	}
+
+	var notes, notesSealed interface{}
+	if in.Notes != nil {
+		assessmentID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid assessment id"})
+			return
+		}
+		if notes, notesSealed, err = h.sealNotes(assessmentID, in.Notes); err != nil {
+			h.Log.Sugar().Errorf("encrypt assessment notes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update assessment"})
+			return
+		}
+	}
+
+	// Newly sealed notes clear the plaintext column.
 	_, err := h.DB.Exec(`UPDATE assessments SET
                        patient_id = COALESCE($1, patient_id),
                        clinician_id = COALESCE($2, clinician_id),
                        wound_id = COALESCE($3, wound_id),
-                       notes = COALESCE($4, notes),
+                       notes = CASE WHEN $8::text IS NULL THEN COALESCE($4, notes) END,
+                       notes_encrypted = COALESCE($8, notes_encrypted),
+                       exudate_amount = COALESCE($5, exudate_amount),
+                       tissue_type = COALESCE($6, tissue_type),
                        updated_at = now()
-                       WHERE id = $5`,
-		in.PatientID, in.ClinicianID, in.WoundID, in.Notes, id)
+                       WHERE id = $7`,
+		in.PatientID, in.ClinicianID, in.WoundID, notes, in.ExudateAmount, in.TissueType, id, notesSealed)
 	if err != nil {
 		h.Log.Sugar().Errorf("update assessment: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update assessment"})
@@ -160,25 +342,32 @@ func (h *Handlers) UpdateAssessment(c *gin.Context) {
 // DeleteAssessment DELETE /v1/assessments/:id
 func (h *Handlers) DeleteAssessment(c *gin.Context) {
 	id := c.Param("id")
-	res, err := h.DB.Exec(`DELETE FROM assessments WHERE id = $1`, id)
-	if err != nil {
+	query := `DELETE FROM assessments WHERE id = $1`
+	args := []interface{}{id}
+	if cond, extra := accessFilter(c, "assessments.patient_id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, assessmentRestricted, id, "assessment not found")
+			return
+		}
 		h.Log.Sugar().Errorf("delete assessment: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete assessment"})
 		return
 	}
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "assessment not found"})
-		return
-	}
+	audit.SetPatient(c, patientID)
 	c.Status(http.StatusNoContent)
 }
 
 // GetAssessmentFull uses DB function get_assessment_full(assessment_id)
 func (h *Handlers) GetAssessmentFull(c *gin.Context) {
-	id := c.Param("id")
-	var fullJSON sql.NullString
-	err := h.DB.QueryRow(`SELECT get_assessment_full($1)`, id).Scan(&fullJSON)
+	if !h.checkAssessmentAccess(c, c.Param("id")) {
+		return
+	}
+	out, err := h.assessmentFull(c.Param("id"))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "assessment not found"})
@@ -188,9 +377,76 @@ func (h *Handlers) GetAssessmentFull(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch full assessment"})
 		return
 	}
-	if !fullJSON.Valid || fullJSON.String == "" {
+	if out == nil {
 		c.JSON(http.StatusOK, gin.H{"data": nil})
 		return
 	}
-	c.Data(http.StatusOK, "application/json", []byte(fullJSON.String))
+	c.Data(http.StatusOK, "application/json", out)
+}
+
+// assessmentFull returns the get_assessment_full document augmented with the
+// PUSH score, treatments, tissue composition and image references. The
+// document is nil when the DB function returns NULL; one that is not an
+// object is wrapped under "assessment".
+func (h *Handlers) assessmentFull(id string) ([]byte, error) {
+	var fullJSON sql.NullString
+	if err := h.DB.QueryRow(`SELECT get_assessment_full($1)`, id).Scan(&fullJSON); err != nil {
+		return nil, err
+	}
+	if !fullJSON.Valid || fullJSON.String == "" {
+		return nil, nil
+	}
+	score, err := h.assessmentPushScore(id)
+	if err != nil {
+		return nil, fmt.Errorf("push score: %w", err)
+	}
+	treatments, err := h.assessmentTreatments(id)
+	if err != nil {
+		return nil, fmt.Errorf("treatments: %w", err)
+	}
+	tissues, err := h.assessmentTissueTypes(id)
+	if err != nil {
+		return nil, fmt.Errorf("tissue types: %w", err)
+	}
+	refs, err := h.assessmentImageReferences(id)
+	if err != nil {
+		return nil, fmt.Errorf("image references: %w", err)
+	}
+	extra := map[string]interface{}{
+		"push_score":       score,
+		"treatments":       treatments,
+		"tissue_types":     tissues,
+		"image_references": refs,
+	}
+	// get_assessment_full only sees the plaintext column.
+	var assessmentID int64
+	var sealed sql.NullString
+	err = h.DB.QueryRow(`SELECT id, notes_encrypted FROM assessments WHERE id = $1`, id).Scan(&assessmentID, &sealed)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("notes: %w", err)
+	}
+	if sealed.Valid {
+		notes, err := h.decryptField(fieldcrypt.AssessmentNotes, assessmentID, sql.NullString{}, sealed)
+		if err != nil {
+			return nil, fmt.Errorf("notes: %w", err)
+		}
+		extra["notes"] = notes
+	}
+	return mergeJSONObject([]byte(fullJSON.String), "assessment", extra)
+}
+
+// checkWoundPatient writes the error response and returns false when the
+// wound cannot be linked to an assessment for patientID.
+func (h *Handlers) checkWoundPatient(c *gin.Context, woundID, patientID int64) bool {
+	err := h.validateWoundPatient(woundID, patientID)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, errWoundNotFound) || errors.Is(err, errWoundPatientMismatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	h.Log.Sugar().Errorf("validate wound: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate wound"})
+	return false
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const auditColumns = `id, occurred_at, actor, actor_clinician_id, actor_api_key_id, action, resource_type,
+                      COALESCE(resource_id, ''), patient_id, route, status, COALESCE(request_id, ''), COALESCE(client_ip, '')`
+
+func scanAuditEntry(s rowScanner) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var clinicianID, apiKeyID, patientID sql.NullInt64
+	err := s.Scan(&e.ID, &e.OccurredAt, &e.Actor, &clinicianID, &apiKeyID, &e.Action, &e.ResourceType,
+		&e.ResourceID, &patientID, &e.Route, &e.Status, &e.RequestID, &e.ClientIP)
+	if err != nil {
+		return e, err
+	}
+	for _, f := range []struct {
+		src sql.NullInt64
+		dst **int64
+	}{{clinicianID, &e.ActorClinicianID}, {apiKeyID, &e.ActorAPIKeyID}, {patientID, &e.PatientID}} {
+		if f.src.Valid {
+			v := f.src.Int64
+			*f.dst = &v
+		}
+	}
+	return e, nil
+}
+
+// SearchAuditLog GET /v1/audit-log?patient_id=&actor=&clinician_id=&action=&resource_type=&from=&to=&page=&page_size=
+// actor is the token subject (or "api-key:<id>"); clinician_id matches the
+// clinician behind it.
+func (h *Handlers) SearchAuditLog(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+
+	var args []interface{}
+	where := []string{}
+	idx := 1
+
+	for _, f := range []struct{ param, col string }{
+		{"patient_id", "patient_id"},
+		{"actor", "actor"},
+		{"clinician_id", "actor_clinician_id"},
+		{"action", "action"},
+		{"resource_type", "resource_type"},
+	} {
+		if v := c.Query(f.param); v != "" {
+			where = append(where, f.col+" = $"+strconv.Itoa(idx))
+			args = append(args, v)
+			idx++
+		}
+	}
+	for _, f := range []struct{ param, op string }{{"from", ">="}, {"to", "<"}} {
+		v := c.Query(f.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.param + " must be ISO-8601 (RFC3339)"})
+			return
+		}
+		where = append(where, "occurred_at "+f.op+" $"+strconv.Itoa(idx))
+		args = append(args, t)
+		idx++
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY occurred_at DESC, id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	args = append(args, pageSize, offset)
+
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("search audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search audit log"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan audit entry: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audit log"})
+			return
+		}
+		out = append(out, e)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
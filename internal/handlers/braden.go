@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/braden"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const bradenColumns = `id, patient_id, clinician_id, sensory_perception, moisture, activity, mobility, nutrition, friction_shear, total, risk_band, assessed_at, created_at`
+
+func scanBraden(s rowScanner) (models.BradenAssessment, error) {
+	var b models.BradenAssessment
+	err := s.Scan(&b.ID, &b.PatientID, &b.ClinicianID, &b.SensoryPerception, &b.Moisture, &b.Activity, &b.Mobility,
+		&b.Nutrition, &b.FrictionShear, &b.Total, &b.RiskBand, &b.AssessedAt, &b.CreatedAt)
+	return b, err
+}
+
+// patientBraden returns a patient's Braden assessments, newest first.
+func (h *Handlers) patientBraden(patientID string) ([]models.BradenAssessment, error) {
+	rows, err := h.DB.Query(`SELECT `+bradenColumns+` FROM braden_assessments
+                             WHERE patient_id = $1 ORDER BY assessed_at DESC, id DESC`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.BradenAssessment{}
+	for rows.Next() {
+		b, err := scanBraden(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// ListPatientBraden GET /v1/patients/:id/braden
+func (h *Handlers) ListPatientBraden(c *gin.Context) {
+	if !h.checkPatientAccess(c, c.Param("id")) {
+		return
+	}
+	out, err := h.patientBraden(c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("list braden assessments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch braden assessments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// CreatePatientBraden POST /v1/patients/:id/braden
+func (h *Handlers) CreatePatientBraden(c *gin.Context) {
+	patientID := c.Param("id")
+	var in struct {
+		ClinicianID int64  `json:"clinician_id" binding:"required"`
+		AssessedAt  string `json:"assessed_at"` // ISO-8601, defaults to now
+		braden.Subscales
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	total, band, err := braden.Score(in.Subscales)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assessedAt := time.Now()
+	if in.AssessedAt != "" {
+		t, err := time.Parse(time.RFC3339, in.AssessedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assessed_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+		assessedAt = t
+	}
+	if !h.checkPatientAccess(c, patientID) {
+		return
+	}
+	var exists bool
+	if err := h.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM clinicians WHERE id = $1)`, in.ClinicianID).Scan(&exists); err != nil {
+		h.Log.Sugar().Errorf("create braden assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create braden assessment"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clinician not found"})
+		return
+	}
+
+	var newID int64
+	err = h.DB.QueryRow(`INSERT INTO braden_assessments (patient_id, clinician_id, sensory_perception, moisture, activity, mobility,
+                                                         nutrition, friction_shear, total, risk_band, assessed_at, created_at)
+                          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, now()) RETURNING id`,
+		patientID, in.ClinicianID, in.SensoryPerception, in.Moisture, in.Activity, in.Mobility,
+		in.Nutrition, in.FrictionShear, total, band, assessedAt).Scan(&newID)
+	if err != nil {
+		h.Log.Sugar().Errorf("create braden assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create braden assessment"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": newID, "total": total, "risk_band": band})
+}
+
+// GetBraden GET /v1/braden/:id
+func (h *Handlers) GetBraden(c *gin.Context) {
+	id := c.Param("id")
+	query, args := withAccess(c, `SELECT `+bradenColumns+` FROM braden_assessments WHERE id=$1`, "braden_assessments.patient_id", id)
+	b, err := scanBraden(h.DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, bradenRestricted, id, "braden assessment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("get braden assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get braden assessment"})
+		return
+	}
+	audit.SetPatient(c, b.PatientID)
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBraden DELETE /v1/braden/:id
+func (h *Handlers) DeleteBraden(c *gin.Context) {
+	id := c.Param("id")
+	query, args := withAccess(c, `DELETE FROM braden_assessments WHERE id = $1`, "braden_assessments.patient_id", id)
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, bradenRestricted, id, "braden assessment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete braden assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete braden assessment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
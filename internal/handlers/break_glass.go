@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"go.uber.org/zap"
+)
+
+// Queries for refuseAccess: whether the record belongs to a restricted
+// patient.
+const (
+	patientRestricted     = `SELECT restricted FROM patients WHERE id = $1`
+	assessmentRestricted  = `SELECT p.restricted FROM assessments a JOIN patients p ON p.id = a.patient_id WHERE a.id = $1`
+	woundRestricted       = `SELECT p.restricted FROM wounds w JOIN patients p ON p.id = w.patient_id WHERE w.id = $1`
+	carePlanRestricted    = `SELECT p.restricted FROM care_plans cp JOIN patients p ON p.id = cp.patient_id WHERE cp.id = $1`
+	bradenRestricted      = `SELECT p.restricted FROM braden_assessments b JOIN patients p ON p.id = b.patient_id WHERE b.id = $1`
+	appointmentRestricted = `SELECT p.restricted FROM appointments ap JOIN patients p ON p.id = ap.patient_id WHERE ap.id = $1`
+	imageRestricted       = `SELECT p.restricted FROM assessment_images i JOIN assessments a ON a.id = i.assessment_id
+                             JOIN patients p ON p.id = a.patient_id WHERE i.id = $1`
+	treatmentRestricted = `SELECT p.restricted FROM treatments t JOIN wounds w ON w.id = t.wound_id
+                           JOIN patients p ON p.id = w.patient_id WHERE t.id = $1`
+)
+
+// Patient of the row being read in queries over assessment_images and
+// treatments, which have no patient_id column.
+const (
+	imagePatient     = `(SELECT ia.patient_id FROM assessments ia WHERE ia.id = assessment_images.assessment_id)`
+	treatmentPatient = `(SELECT tw.patient_id FROM wounds tw WHERE tw.id = treatments.wound_id)`
+)
+
+// refuseAccess answers a failed access check. Restricted patients get 403
+// so clinicians know they can break the glass; anything else is 404, as
+// before. Their existence is not a secret: break-the-glass needs the
+// patient ID.
+func (h *Handlers) refuseAccess(c *gin.Context, restrictedQuery string, id interface{}, notFound string) {
+	if seesRestricted(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	var restricted bool
+	err := h.DB.QueryRow(restrictedQuery, id).Scan(&restricted)
+	if err != nil && err != sql.ErrNoRows {
+		h.Log.Sugar().Errorf("check restricted: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+		return
+	}
+	if restricted {
+		c.JSON(http.StatusForbidden, gin.H{"error": "patient record is restricted; request break-the-glass access", "restricted": true})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+}
+
+const breakGlassColumns = `id, patient_id, clinician_id, reason, granted_at, expires_at, expires_at > now(),
+                           reviewed_by, reviewed_at, COALESCE(review_note, '')`
+
+func scanBreakGlassGrant(s rowScanner) (models.BreakGlassGrant, error) {
+	var g models.BreakGlassGrant
+	var reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	if err := s.Scan(&g.ID, &g.PatientID, &g.ClinicianID, &g.Reason, &g.GrantedAt, &g.ExpiresAt, &g.Active,
+		&reviewedBy, &reviewedAt, &g.ReviewNote); err != nil {
+		return g, err
+	}
+	if reviewedBy.Valid {
+		v := reviewedBy.Int64
+		g.ReviewedBy = &v
+	}
+	if reviewedAt.Valid {
+		v := reviewedAt.Time
+		g.ReviewedAt = &v
+	}
+	return g, nil
+}
+
+// BreakGlass POST /v1/patients/:id/break-glass
+// Grants the calling clinician access to a restricted patient, or one
+// outside their care team, for BREAK_GLASS_DURATION. The reason is kept
+// for the privacy office's review.
+func (h *Handlers) BreakGlass(c *gin.Context) {
+	var in struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(strings.TrimSpace(in.Reason)) < 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must explain the emergency (at least 10 characters)"})
+		return
+	}
+	p, ok := auth.PrincipalFrom(c)
+	if !ok || p.ClinicianID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "break-the-glass access requires a clinician account"})
+		return
+	}
+	patientID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+
+	duration := h.Cfg.BreakGlassDuration
+	if duration <= 0 {
+		duration = time.Hour
+	}
+	var g models.BreakGlassGrant
+	err = h.DB.QueryRow(`INSERT INTO break_glass_grants (patient_id, clinician_id, reason, granted_at, expires_at)
+                         SELECT id, $2, $3, now(), now() + $4 * interval '1 second' FROM patients WHERE id = $1
+                         RETURNING id, expires_at`,
+		patientID, p.ClinicianID, strings.TrimSpace(in.Reason), int64(duration/time.Second)).Scan(&g.ID, &g.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			return
+		}
+		h.Log.Sugar().Errorf("break glass: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant access"})
+		return
+	}
+	h.Log.Warn("break-the-glass access granted",
+		zap.Int64("grant_id", g.ID), zap.Int64("patient_id", patientID), zap.Int64("clinician_id", p.ClinicianID))
+	c.JSON(http.StatusCreated, gin.H{"id": g.ID, "expires_at": g.ExpiresAt})
+}
+
+// ListBreakGlassGrants GET /v1/break-glass?patient_id=&clinician_id=&reviewed=&active=&page=&page_size=
+// The privacy office's review queue; reviewed=false lists what still
+// needs review.
+func (h *Handlers) ListBreakGlassGrants(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+
+	var args []interface{}
+	where := []string{}
+	idx := 1
+	for _, f := range []struct{ param, col string }{
+		{"patient_id", "patient_id"},
+		{"clinician_id", "clinician_id"},
+	} {
+		if v := c.Query(f.param); v != "" {
+			where = append(where, f.col+" = $"+strconv.Itoa(idx))
+			args = append(args, v)
+			idx++
+		}
+	}
+	for _, f := range []struct{ param, yes, no string }{
+		{"reviewed", "reviewed_at IS NOT NULL", "reviewed_at IS NULL"},
+		{"active", "expires_at > now()", "expires_at <= now()"},
+	} {
+		switch c.Query(f.param) {
+		case "":
+		case "true":
+			where = append(where, f.yes)
+		case "false":
+			where = append(where, f.no)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.param + " must be true or false"})
+			return
+		}
+	}
+
+	query := `SELECT ` + breakGlassColumns + ` FROM break_glass_grants`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY granted_at DESC, id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	args = append(args, pageSize, offset)
+
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("list break glass grants: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch grants"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.BreakGlassGrant{}
+	for rows.Next() {
+		g, err := scanBreakGlassGrant(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan break glass grant: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read grants"})
+			return
+		}
+		out = append(out, g)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
+
+// ReviewBreakGlassGrant POST /v1/break-glass/:id/review
+// Records an admin's review. revoke ends a grant that is still active.
+func (h *Handlers) ReviewBreakGlassGrant(c *gin.Context) {
+	var in struct {
+		Note   string `json:"note"`
+		Revoke bool   `json:"revoke"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var reviewer interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		reviewer = p.ClinicianID
+	}
+	var patientID int64
+	err := h.DB.QueryRow(`UPDATE break_glass_grants SET reviewed_by = $2, reviewed_at = now(), review_note = $3,
+                                 expires_at = CASE WHEN $4 THEN LEAST(expires_at, now()) ELSE expires_at END
+                          WHERE id = $1 RETURNING patient_id`,
+		c.Param("id"), reviewer, in.Note, in.Revoke).Scan(&patientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "grant not found"})
+			return
+		}
+		h.Log.Sugar().Errorf("review break glass grant: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review grant"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// SetPatientRestricted PUT /v1/patients/:id/restricted
+func (h *Handlers) SetPatientRestricted(c *gin.Context) {
+	var in struct {
+		Restricted *bool `json:"restricted" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.DB.Exec(`UPDATE patients SET restricted = $1, updated_at = now() WHERE id = $2`, *in.Restricted, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("set patient restricted: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient"})
+		return
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+var errCarePlanLinks = errors.New("wound_ids and assessment_ids must belong to the plan's patient")
+
+const carePlanSelect = `SELECT p.id, p.patient_id, p.responsible_clinician_id, p.title, p.goals, p.interventions,
+                               p.review_date, p.status, p.version, p.created_at, p.updated_at,
+                               COALESCE((SELECT json_agg(wound_id ORDER BY wound_id) FROM care_plan_wounds WHERE care_plan_id = p.id), '[]'),
+                               COALESCE((SELECT json_agg(assessment_id ORDER BY assessment_id) FROM care_plan_assessments WHERE care_plan_id = p.id), '[]')
+                        FROM care_plans p`
+
+func scanCarePlan(s rowScanner) (models.CarePlan, error) {
+	var p models.CarePlan
+	var goals, interventions, woundIDs, assessmentIDs []byte
+	if err := s.Scan(&p.ID, &p.PatientID, &p.ResponsibleClinicianID, &p.Title, &goals, &interventions,
+		&p.ReviewDate, &p.Status, &p.Version, &p.CreatedAt, &p.UpdatedAt, &woundIDs, &assessmentIDs); err != nil {
+		return p, err
+	}
+	for _, f := range []struct {
+		raw []byte
+		dst interface{}
+	}{{goals, &p.Goals}, {interventions, &p.Interventions}, {woundIDs, &p.WoundIDs}, {assessmentIDs, &p.AssessmentIDs}} {
+		if err := json.Unmarshal(f.raw, f.dst); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func (h *Handlers) queryCarePlans(query string, args ...interface{}) ([]models.CarePlan, error) {
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.CarePlan{}
+	for rows.Next() {
+		p, err := scanCarePlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	out := []int64{}
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// sortedIDs returns ids in ascending order, as carePlanSelect lists links.
+func sortedIDs(ids []int64) []int64 {
+	out := append([]int64{}, ids...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func validateCarePlanLinks(q queryRower, patientID int64, woundIDs, assessmentIDs []int64) error {
+	var ok bool
+	err := q.QueryRow(`SELECT (SELECT count(*) FROM wounds WHERE patient_id = $1 AND id = ANY($2)) = cardinality($2::bigint[])
+                          AND (SELECT count(*) FROM assessments WHERE patient_id = $1 AND id = ANY($3)) = cardinality($3::bigint[])`,
+		patientID, woundIDs, assessmentIDs).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errCarePlanLinks
+	}
+	return nil
+}
+
+func replaceCarePlanLinks(tx *sql.Tx, planID int64, woundIDs, assessmentIDs []int64) error {
+	if woundIDs != nil {
+		if _, err := tx.Exec(`DELETE FROM care_plan_wounds WHERE care_plan_id = $1`, planID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO care_plan_wounds (care_plan_id, wound_id) SELECT $1, unnest($2::bigint[])`, planID, woundIDs); err != nil {
+			return err
+		}
+	}
+	if assessmentIDs != nil {
+		if _, err := tx.Exec(`DELETE FROM care_plan_assessments WHERE care_plan_id = $1`, planID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO care_plan_assessments (care_plan_id, assessment_id) SELECT $1, unnest($2::bigint[])`, planID, assessmentIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPatientCarePlans GET /v1/patients/:id/care-plans?status=
+func (h *Handlers) ListPatientCarePlans(c *gin.Context) {
+	if !h.checkPatientAccess(c, c.Param("id")) {
+		return
+	}
+	query := carePlanSelect + ` WHERE p.patient_id = $1`
+	args := []interface{}{c.Param("id")}
+	if v := c.Query("status"); v != "" {
+		query += ` AND p.status = $2`
+		args = append(args, v)
+	}
+	out, err := h.queryCarePlans(query+` ORDER BY p.id DESC`, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("list care plans: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch care plans"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// ListOverdueCarePlans GET /v1/care-plans/overdue?page=&page_size=
+// Active plans whose review date has passed, most overdue first.
+func (h *Handlers) ListOverdueCarePlans(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+	where, args, idx := scoped(c, "p.patient_id", []string{`p.status = 'active'`, `p.review_date < CURRENT_DATE`}, nil, 1)
+	out, err := h.queryCarePlans(carePlanSelect+` WHERE `+strings.Join(where, " AND ")+`
+                                 ORDER BY p.review_date ASC, p.id ASC LIMIT $`+strconv.Itoa(idx)+` OFFSET $`+strconv.Itoa(idx+1),
+		append(args, pageSize, offset)...)
+	if err != nil {
+		h.Log.Sugar().Errorf("list overdue care plans: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch care plans"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
+
+// GetCarePlan GET /v1/care-plans/:id
+func (h *Handlers) GetCarePlan(c *gin.Context) {
+	query, args := withAccess(c, carePlanSelect+` WHERE p.id = $1`, "p.patient_id", c.Param("id"))
+	p, err := scanCarePlan(h.DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, carePlanRestricted, c.Param("id"), "care plan not found")
+			return
+		}
+		h.Log.Sugar().Errorf("get care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get care plan"})
+		return
+	}
+	audit.SetPatient(c, p.PatientID)
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePatientCarePlan POST /v1/patients/:id/care-plans
+func (h *Handlers) CreatePatientCarePlan(c *gin.Context) {
+	patientID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+		return
+	}
+	var in struct {
+		ResponsibleClinicianID int64                 `json:"responsible_clinician_id" binding:"required"`
+		Title                  string                `json:"title" binding:"required"`
+		Goals                  []models.CarePlanGoal `json:"goals" binding:"dive"`
+		Interventions          []string              `json:"interventions"`
+		ReviewDate             string                `json:"review_date" binding:"required"` // ISO-8601
+		WoundIDs               []int64               `json:"wound_ids"`
+		AssessmentIDs          []int64               `json:"assessment_ids"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reviewDate, err := time.Parse(time.RFC3339, in.ReviewDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "review_date must be ISO-8601 (RFC3339)"})
+		return
+	}
+	if !h.checkPatientAccess(c, patientID) {
+		return
+	}
+	var exists bool
+	if err := h.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM clinicians WHERE id = $1)`, in.ResponsibleClinicianID).Scan(&exists); err != nil {
+		h.Log.Sugar().Errorf("create care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create care plan"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "responsible clinician not found"})
+		return
+	}
+	if in.Goals == nil {
+		in.Goals = []models.CarePlanGoal{}
+	}
+	if in.Interventions == nil {
+		in.Interventions = []string{}
+	}
+	woundIDs, assessmentIDs := uniqueIDs(in.WoundIDs), uniqueIDs(in.AssessmentIDs)
+	goals, _ := json.Marshal(in.Goals)
+	interventions, _ := json.Marshal(in.Interventions)
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("create care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create care plan"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := validateCarePlanLinks(tx, patientID, woundIDs, assessmentIDs); err != nil {
+		if errors.Is(err, errCarePlanLinks) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.Log.Sugar().Errorf("create care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create care plan"})
+		return
+	}
+
+	var newID int64
+	err = tx.QueryRow(`INSERT INTO care_plans (patient_id, responsible_clinician_id, title, goals, interventions, review_date, status, version, created_at, updated_at)
+                       VALUES ($1, $2, $3, $4, $5, $6, 'active', 1, now(), now()) RETURNING id`,
+		patientID, in.ResponsibleClinicianID, in.Title, string(goals), string(interventions), reviewDate).Scan(&newID)
+	if err == nil {
+		err = replaceCarePlanLinks(tx, newID, woundIDs, assessmentIDs)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("create care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create care plan"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": newID, "version": 1})
+}
+
+// UpdateCarePlan PUT /v1/care-plans/:id
+// The plan as it stood before the edit is kept in care_plan_versions and the
+// version number is incremented. An edit that changes nothing writes nothing.
+func (h *Handlers) UpdateCarePlan(c *gin.Context) {
+	id := c.Param("id")
+	var in struct {
+		ResponsibleClinicianID *int64                `json:"responsible_clinician_id"`
+		Title                  *string               `json:"title"`
+		Goals                  []models.CarePlanGoal `json:"goals" binding:"dive"`
+		Interventions          []string              `json:"interventions"`
+		ReviewDate             *string               `json:"review_date"`
+		Status                 *string               `json:"status" binding:"omitempty,oneof=active completed discontinued"`
+		WoundIDs               []int64               `json:"wound_ids"`
+		AssessmentIDs          []int64               `json:"assessment_ids"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("update care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+		return
+	}
+	defer tx.Rollback()
+
+	var locked int64
+	query, args := withAccess(c, `SELECT id FROM care_plans WHERE id = $1`, "care_plans.patient_id", id)
+	if err := tx.QueryRow(query+` FOR UPDATE`, args...).Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, carePlanRestricted, id, "care plan not found")
+			return
+		}
+		h.Log.Sugar().Errorf("update care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+		return
+	}
+	cur, err := scanCarePlan(tx.QueryRow(carePlanSelect+` WHERE p.id = $1`, locked))
+	if err != nil {
+		h.Log.Sugar().Errorf("update care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+		return
+	}
+	audit.SetPatient(c, cur.PatientID)
+	snapshot, err := json.Marshal(cur)
+	if err != nil {
+		h.Log.Sugar().Errorf("update care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+		return
+	}
+
+	next := cur
+	if in.ResponsibleClinicianID != nil {
+		next.ResponsibleClinicianID = *in.ResponsibleClinicianID
+	}
+	if in.Title != nil {
+		next.Title = *in.Title
+	}
+	if in.Goals != nil {
+		next.Goals = in.Goals
+	}
+	if in.Interventions != nil {
+		next.Interventions = in.Interventions
+	}
+	if in.ReviewDate != nil {
+		t, err := time.Parse(time.RFC3339, *in.ReviewDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "review_date must be ISO-8601 (RFC3339)"})
+			return
+		}
+		if !t.Equal(cur.ReviewDate) {
+			next.ReviewDate = t
+		}
+	}
+	if in.Status != nil {
+		next.Status = *in.Status
+	}
+	var woundIDs, assessmentIDs []int64
+	if in.WoundIDs != nil || in.AssessmentIDs != nil {
+		woundIDs, assessmentIDs = uniqueIDs(in.WoundIDs), uniqueIDs(in.AssessmentIDs)
+		if err := validateCarePlanLinks(tx, cur.PatientID, woundIDs, assessmentIDs); err != nil {
+			if errors.Is(err, errCarePlanLinks) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			h.Log.Sugar().Errorf("update care plan: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+			return
+		}
+		// Only replace the link sets the client actually sent.
+		if in.WoundIDs == nil {
+			woundIDs = nil
+		} else {
+			next.WoundIDs = sortedIDs(woundIDs)
+		}
+		if in.AssessmentIDs == nil {
+			assessmentIDs = nil
+		} else {
+			next.AssessmentIDs = sortedIDs(assessmentIDs)
+		}
+	}
+	if next.ResponsibleClinicianID != cur.ResponsibleClinicianID {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM clinicians WHERE id = $1)`, next.ResponsibleClinicianID).Scan(&exists); err != nil {
+			h.Log.Sugar().Errorf("update care plan: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "responsible clinician not found"})
+			return
+		}
+	}
+	if b, err := json.Marshal(next); err == nil && bytes.Equal(b, snapshot) {
+		c.JSON(http.StatusOK, gin.H{"id": cur.ID, "version": cur.Version})
+		return
+	}
+	goals, _ := json.Marshal(next.Goals)
+	interventions, _ := json.Marshal(next.Interventions)
+
+	_, err = tx.Exec(`INSERT INTO care_plan_versions (care_plan_id, version, snapshot, created_at) VALUES ($1, $2, $3, now())`,
+		cur.ID, cur.Version, string(snapshot))
+	if err == nil {
+		_, err = tx.Exec(`UPDATE care_plans SET responsible_clinician_id = $1, title = $2, goals = $3, interventions = $4,
+                                  review_date = $5, status = $6, version = version + 1, updated_at = now()
+                          WHERE id = $7`,
+			next.ResponsibleClinicianID, next.Title, string(goals), string(interventions), next.ReviewDate, next.Status, cur.ID)
+	}
+	if err == nil {
+		err = replaceCarePlanLinks(tx, cur.ID, woundIDs, assessmentIDs)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("update care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update care plan"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": cur.ID, "version": cur.Version + 1})
+}
+
+// ListCarePlanVersions GET /v1/care-plans/:id/versions
+// Superseded versions, newest first. The current version is GetCarePlan.
+func (h *Handlers) ListCarePlanVersions(c *gin.Context) {
+	if !h.checkRecordAccess(c, "care_plans", "care_plans.patient_id", carePlanRestricted, c.Param("id"), "care plan not found") {
+		return
+	}
+	rows, err := h.DB.Query(`SELECT care_plan_id, version, snapshot, created_at FROM care_plan_versions
+                             WHERE care_plan_id = $1 ORDER BY version DESC`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("list care plan versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch care plan versions"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.CarePlanVersion{}
+	for rows.Next() {
+		var v models.CarePlanVersion
+		var snapshot []byte
+		err := rows.Scan(&v.CarePlanID, &v.Version, &snapshot, &v.CreatedAt)
+		if err == nil {
+			err = json.Unmarshal(snapshot, &v.Snapshot)
+		}
+		if err != nil {
+			h.Log.Sugar().Errorf("scan care plan version: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read care plan versions"})
+			return
+		}
+		out = append(out, v)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// DeleteCarePlan DELETE /v1/care-plans/:id
+func (h *Handlers) DeleteCarePlan(c *gin.Context) {
+	query, args := withAccess(c, `DELETE FROM care_plans WHERE id = $1`, "care_plans.patient_id", c.Param("id"))
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, carePlanRestricted, c.Param("id"), "care plan not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete care plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete care plan"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
+)
+
+// careTeamFilter returns a SQL condition limiting patientCol to patients on
+// the caller's care team, using placeholder $idx, together with its
+// argument. It returns "" for callers who see every patient: admins, API
+// keys (integrations are scoped by resource instead), and every request when
+// authentication is disabled.
+func careTeamFilter(c *gin.Context, patientCol string, idx int) (string, []interface{}) {
+	p, ok := auth.PrincipalFrom(c)
+	if !ok || p.APIKeyID != 0 {
+		return "", nil
+	}
+	if role, _ := rbac.ParseRole(p.Role); role == rbac.Admin {
+		return "", nil
+	}
+	return `EXISTS (SELECT 1 FROM care_team_assignments ct
+                    WHERE ct.patient_id = ` + patientCol + ` AND ct.clinician_id = $` + strconv.Itoa(idx) + `)`,
+		[]interface{}{p.ClinicianID}
+}
+
+// accessFilter is careTeamFilter for patient records rather than patient
+// lists. Restricted patients are also hidden, from API keys as well, and
+// an unexpired break-the-glass grant opens a patient to its holder whether
+// or not they are on the care team.
+func accessFilter(c *gin.Context, patientCol string, idx int) (string, []interface{}) {
+	if seesRestricted(c) {
+		return "", nil
+	}
+	p, _ := auth.PrincipalFrom(c)
+	restricted := `EXISTS (SELECT 1 FROM patients rp WHERE rp.id = ` + patientCol + ` AND rp.restricted)`
+	if p.APIKeyID != 0 {
+		return "NOT " + restricted, nil
+	}
+	n := strconv.Itoa(idx)
+	return `((EXISTS (SELECT 1 FROM care_team_assignments ct
+                      WHERE ct.patient_id = ` + patientCol + ` AND ct.clinician_id = $` + n + `) AND NOT ` + restricted + `)
+             OR EXISTS (SELECT 1 FROM break_glass_grants g
+                        WHERE g.patient_id = ` + patientCol + ` AND g.clinician_id = $` + n + ` AND g.expires_at > now()))`,
+		[]interface{}{p.ClinicianID}
+}
+
+// seesRestricted reports whether the caller sees every patient record,
+// restricted ones included: admins, and everyone when authentication is
+// disabled.
+func seesRestricted(c *gin.Context) bool {
+	p, ok := auth.PrincipalFrom(c)
+	if !ok {
+		return true
+	}
+	role, _ := rbac.ParseRole(p.Role)
+	return role == rbac.Admin && p.APIKeyID == 0
+}
+
+// scoped appends the access condition for patientCol to where/args and
+// returns the next placeholder index.
+func scoped(c *gin.Context, patientCol string, where []string, args []interface{}, idx int) ([]string, []interface{}, int) {
+	cond, extra := accessFilter(c, patientCol, idx)
+	if cond == "" {
+		return where, args, idx
+	}
+	return append(where, cond), append(args, extra...), idx + len(extra)
+}
+
+// withAccess appends the accessFilter condition for patientCol to query,
+// which must end in a WHERE clause over args.
+func withAccess(c *gin.Context, query, patientCol string, args ...interface{}) (string, []interface{}) {
+	cond, extra := accessFilter(c, patientCol, len(args)+1)
+	if cond == "" {
+		return query, args
+	}
+	return query + " AND " + cond, append(args, extra...)
+}
+
+// checkPatientAccess writes 404 and returns false unless the patient exists
+// and is visible to the caller. Patients outside the care team are
+// indistinguishable from missing ones; restricted patients get 403 (see
+// refuseAccess).
+func (h *Handlers) checkPatientAccess(c *gin.Context, patientID interface{}) bool {
+	query := `SELECT EXISTS (SELECT 1 FROM patients p WHERE p.id = $1`
+	args := []interface{}{patientID}
+	if cond, extra := accessFilter(c, "p.id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	var ok bool
+	if err := h.DB.QueryRow(query+`)`, args...).Scan(&ok); err != nil {
+		h.Log.Sugar().Errorf("check patient access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient access"})
+		return false
+	}
+	if !ok {
+		h.refuseAccess(c, patientRestricted, patientID, "patient not found")
+	}
+	return ok
+}
+
+// checkAssessmentAccess is checkPatientAccess for the assessment's patient,
+// whom it also names in the audit entry.
+func (h *Handlers) checkAssessmentAccess(c *gin.Context, assessmentID string) bool {
+	query := `SELECT a.patient_id FROM assessments a WHERE a.id = $1`
+	args := []interface{}{assessmentID}
+	if cond, extra := accessFilter(c, "a.patient_id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	var patientID int64
+	if err := h.DB.QueryRow(query, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, assessmentRestricted, assessmentID, "assessment not found")
+			return false
+		}
+		h.Log.Sugar().Errorf("check assessment access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check assessment access"})
+		return false
+	}
+	audit.SetPatient(c, patientID)
+	return true
+}
+
+// checkRecordAccess is checkAssessmentAccess for a row of table, which
+// belongs to the patient patientCol. restrictedQuery and notFound are passed
+// to refuseAccess.
+func (h *Handlers) checkRecordAccess(c *gin.Context, table, patientCol, restrictedQuery string, id interface{}, notFound string) bool {
+	query, args := withAccess(c, `SELECT `+patientCol+` FROM `+table+` WHERE `+table+`.id = $1`, patientCol, id)
+	var patientID int64
+	if err := h.DB.QueryRow(query, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, restrictedQuery, id, notFound)
+			return false
+		}
+		h.Log.Sugar().Errorf("check %s access: %v", table, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check access"})
+		return false
+	}
+	audit.SetPatient(c, patientID)
+	return true
+}
+
+// checkWoundAccess is checkPatientAccess for the wound's patient.
+func (h *Handlers) checkWoundAccess(c *gin.Context, woundID string) bool {
+	return h.checkRecordAccess(c, "wounds", "wounds.patient_id", woundRestricted, woundID, "wound not found")
+}
+
+// ListPatientCareTeam GET /v1/patients/:id/care-team
+func (h *Handlers) ListPatientCareTeam(c *gin.Context) {
+	if !h.checkPatientAccess(c, c.Param("id")) {
+		return
+	}
+	rows, err := h.DB.Query(`SELECT ct.patient_id, ct.clinician_id, cl.full_name, COALESCE(cl.role, ''), ct.assigned_by, ct.assigned_at
+                             FROM care_team_assignments ct JOIN clinicians cl ON cl.id = ct.clinician_id
+                             WHERE ct.patient_id = $1 ORDER BY ct.assigned_at, ct.clinician_id`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("list care team: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch care team"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.CareTeamMember{}
+	for rows.Next() {
+		var m models.CareTeamMember
+		var assignedBy sql.NullInt64
+		if err := rows.Scan(&m.PatientID, &m.ClinicianID, &m.FullName, &m.Role, &assignedBy, &m.AssignedAt); err != nil {
+			h.Log.Sugar().Errorf("scan care team member: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read care team"})
+			return
+		}
+		if assignedBy.Valid {
+			v := assignedBy.Int64
+			m.AssignedBy = &v
+		}
+		out = append(out, m)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// checkCareTeamChange is checkPatientAccess for changes to the patient's
+// care team, which also need the caller to be on that team. A
+// break-the-glass grant opens the record but not the team; otherwise its
+// holder could add themselves and outlive the grant.
+func (h *Handlers) checkCareTeamChange(c *gin.Context, patientID interface{}) bool {
+	if !h.checkPatientAccess(c, patientID) {
+		return false
+	}
+	cond, args := careTeamFilter(c, "$1", 2)
+	if cond == "" {
+		return true
+	}
+	var member bool
+	if err := h.DB.QueryRow(`SELECT `+cond, append([]interface{}{patientID}, args...)...).Scan(&member); err != nil {
+		h.Log.Sugar().Errorf("check care team membership: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check patient access"})
+		return false
+	}
+	if !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the patient's care team can change it"})
+	}
+	return member
+}
+
+// AddPatientCareTeamMember POST /v1/patients/:id/care-team
+// Callers other than admins can only extend care teams they belong to.
+func (h *Handlers) AddPatientCareTeamMember(c *gin.Context) {
+	var in struct {
+		ClinicianID int64 `json:"clinician_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkCareTeamChange(c, c.Param("id")) {
+		return
+	}
+	var exists bool
+	if err := h.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM clinicians WHERE id = $1)`, in.ClinicianID).Scan(&exists); err != nil {
+		h.Log.Sugar().Errorf("add care team member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add care team member"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clinician not found"})
+		return
+	}
+
+	if err := h.assignCareTeam(c, c.Param("id"), in.ClinicianID); err != nil {
+		h.Log.Sugar().Errorf("add care team member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add care team member"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// assignCareTeam adds clinicianID to the patient's care team, recording the
+// caller as the assigner. Re-assigning is a no-op.
+func (h *Handlers) assignCareTeam(c *gin.Context, patientID interface{}, clinicianID int64) error {
+	var assignedBy interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		assignedBy = p.ClinicianID
+	}
+	_, err := h.DB.Exec(`INSERT INTO care_team_assignments (patient_id, clinician_id, assigned_by, assigned_at)
+                         VALUES ($1, $2, $3, now()) ON CONFLICT (patient_id, clinician_id) DO NOTHING`,
+		patientID, clinicianID, assignedBy)
+	return err
+}
+
+// RemovePatientCareTeamMember DELETE /v1/patients/:id/care-team/:clinician_id
+func (h *Handlers) RemovePatientCareTeamMember(c *gin.Context) {
+	if !h.checkCareTeamChange(c, c.Param("id")) {
+		return
+	}
+	res, err := h.DB.Exec(`DELETE FROM care_team_assignments WHERE patient_id = $1 AND clinician_id = $2`,
+		c.Param("id"), c.Param("clinician_id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("remove care team member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove care team member"})
+		return
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "clinician is not on the care team"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -5,9 +5,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
 )
 
+const errInvalidRole = "role must be one of admin, physician, nurse, wound_specialist, auditor"
+
 // ListClinicians GET /v1/clinicians
 func (h *Handlers) ListClinicians(c *gin.Context) {
 	page, pageSize := parsePagination(c)
@@ -63,6 +68,14 @@ func (h *Handlers) CreateClinician(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if in.Role != "" {
+		role, ok := rbac.ParseRole(in.Role)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidRole})
+			return
+		}
+		in.Role = string(role)
+	}
 
 	var newID int64
 	err := h.DB.QueryRow(`INSERT INTO clinicians (full_name, email, role, created_at, updated_at)
@@ -72,6 +85,7 @@ func (h *Handlers) CreateClinician(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create clinician"})
 		return
 	}
+	audit.SetResource(c, newID)
 	c.JSON(http.StatusCreated, gin.H{"id": newID})
 }
 
@@ -87,6 +101,14 @@ func (h *Handlers) UpdateClinician(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if in.Role != nil && *in.Role != "" {
+		role, ok := rbac.ParseRole(*in.Role)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidRole})
+			return
+		}
+		*in.Role = string(role)
+	}
 
 	_, err := h.DB.Exec(`UPDATE clinicians SET full_name = COALESCE($1, full_name),
                        email = COALESCE($2, email),
@@ -103,9 +125,42 @@ func (h *Handlers) UpdateClinician(c *gin.Context) {
 }
 
 // DeleteClinician DELETE /v1/clinicians/:id
+// Revokes the clinician's login sessions in the same transaction, so their
+// access ends within SESSION_REVOCATION_REFRESH on every instance, and
+// disables their email for identity provider logins.
 func (h *Handlers) DeleteClinician(c *gin.Context) {
 	id := c.Param("id")
-	res, err := h.DB.Exec(`DELETE FROM clinicians WHERE id = $1`, id)
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("delete clinician: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete clinician"})
+		return
+	}
+	defer tx.Rollback()
+	revoked, err := revokeSessions(c, tx, "clinician deleted",
+		`user_id IN (SELECT id FROM user_accounts WHERE clinician_id = $2)`, id)
+	if err != nil {
+		h.Log.Sugar().Errorf("delete clinician: revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete clinician"})
+		return
+	}
+	// The identity provider keeps vouching for the clinician; recording the
+	// email stops ResolveClinician falling back to the roles claim or
+	// provisioning them again.
+	var deletedBy interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		deletedBy = p.ClinicianID
+	}
+	if _, err := tx.Exec(`INSERT INTO disabled_identities (email, clinician_id, disabled_by, disabled_at)
+                          SELECT lower(email), id, $2, now() FROM clinicians WHERE id = $1 AND COALESCE(email, '') <> ''
+                          ON CONFLICT (email) DO UPDATE SET clinician_id = EXCLUDED.clinician_id,
+                                                            disabled_by = EXCLUDED.disabled_by, disabled_at = now()`,
+		id, deletedBy); err != nil {
+		h.Log.Sugar().Errorf("delete clinician: disable identity: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete clinician"})
+		return
+	}
+	res, err := tx.Exec(`DELETE FROM clinicians WHERE id = $1`, id)
 	if err != nil {
 		h.Log.Sugar().Errorf("delete clinician: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete clinician"})
@@ -116,5 +171,11 @@ func (h *Handlers) DeleteClinician(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "clinician not found"})
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("delete clinician: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete clinician"})
+		return
+	}
+	h.Sessions.Add(revoked...)
 	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+// consentStatus derives a record's status; it is also how requireConsent
+// reads the most recent record.
+const consentStatus = `CASE WHEN revoked_at IS NOT NULL THEN 'revoked'
+                            WHEN expires_at IS NOT NULL AND expires_at <= now() THEN 'expired'
+                            ELSE 'granted' END`
+
+const consentColumns = `id, patient_id, consent_type, ` + consentStatus + `, COALESCE(scope, ''), signed_by, signer_relationship,
+                        COALESCE(document_ref, ''), granted_at, expires_at, revoked_at, COALESCE(revoked_reason, ''),
+                        recorded_by, created_at`
+
+func scanConsent(s rowScanner) (models.PatientConsent, error) {
+	var pc models.PatientConsent
+	var expires, revoked sql.NullTime
+	var recordedBy sql.NullInt64
+	err := s.Scan(&pc.ID, &pc.PatientID, &pc.ConsentType, &pc.Status, &pc.Scope, &pc.SignedBy, &pc.SignerRelationship,
+		&pc.DocumentRef, &pc.GrantedAt, &expires, &revoked, &pc.RevokedReason, &recordedBy, &pc.CreatedAt)
+	if err != nil {
+		return pc, err
+	}
+	if expires.Valid {
+		pc.ExpiresAt = &expires.Time
+	}
+	if revoked.Valid {
+		pc.RevokedAt = &revoked.Time
+	}
+	if recordedBy.Valid {
+		pc.RecordedBy = &recordedBy.Int64
+	}
+	return pc, nil
+}
+
+func validConsentType(t string) bool {
+	for _, ct := range models.ConsentTypes {
+		if t == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// requireConsent writes 409 and returns false unless the patient's most
+// recent consent of consentType is granted, unexpired and not revoked.
+// Handlers call it before any write that needs the consent.
+func (h *Handlers) requireConsent(c *gin.Context, patientID interface{}, consentType string) bool {
+	return h.requireConsentIn(c, h.DB, patientID, consentType)
+}
+
+// requireConsentIn is requireConsent reading through q. Inside a transaction
+// the consent record stays locked until commit, so a concurrent revocation
+// waits for the write that relied on it.
+func (h *Handlers) requireConsentIn(c *gin.Context, q queryRower, patientID interface{}, consentType string) bool {
+	var status string
+	err := q.QueryRow(`SELECT `+consentStatus+` FROM patient_consents
+                          WHERE patient_id = $1 AND consent_type = $2 AND granted_at <= now()
+                          ORDER BY granted_at DESC, id DESC LIMIT 1 FOR SHARE`, patientID, consentType).Scan(&status)
+	if err == sql.ErrNoRows {
+		status = "missing"
+	} else if err != nil {
+		h.Log.Sugar().Errorf("check consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check consent"})
+		return false
+	}
+	var msg string
+	switch status {
+	case "granted":
+		return true
+	case "revoked":
+		msg = "patient has revoked " + consentType + " consent"
+	case "expired":
+		msg = "patient's " + consentType + " consent has expired"
+	default:
+		msg = "patient has not granted " + consentType + " consent"
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": msg, "consent_type": consentType, "consent_status": status})
+	return false
+}
+
+// requireEnrolmentConsent is requireConsent for research consent while the
+// patient is enrolled in research. Consent can lapse without the revocation
+// that would have ended the enrolment.
+func (h *Handlers) requireEnrolmentConsent(c *gin.Context, patientID interface{}) bool {
+	var enrolled bool
+	if err := h.DB.QueryRow(`SELECT research_enrolled FROM patients WHERE id = $1`, patientID).Scan(&enrolled); err != nil {
+		h.Log.Sugar().Errorf("check research enrolment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check consent"})
+		return false
+	}
+	return !enrolled || h.requireConsent(c, patientID, models.ConsentResearch)
+}
+
+// patientConsents returns a patient's consent history, newest first.
+func (h *Handlers) patientConsents(patientID, consentType string) ([]models.PatientConsent, error) {
+	query := `SELECT ` + consentColumns + ` FROM patient_consents WHERE patient_id = $1`
+	args := []interface{}{patientID}
+	if consentType != "" {
+		query += ` AND consent_type = $2`
+		args = append(args, consentType)
+	}
+	rows, err := h.DB.Query(query+` ORDER BY granted_at DESC, id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.PatientConsent{}
+	for rows.Next() {
+		pc, err := scanConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pc)
+	}
+	return out, rows.Err()
+}
+
+// ListPatientConsents GET /v1/patients/:id/consents?consent_type=
+func (h *Handlers) ListPatientConsents(c *gin.Context) {
+	if !h.checkPatientAccess(c, c.Param("id")) {
+		return
+	}
+	ct := c.Query("consent_type")
+	if ct != "" && !validConsentType(ct) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown consent_type " + ct})
+		return
+	}
+	out, err := h.patientConsents(c.Param("id"), ct)
+	if err != nil {
+		h.Log.Sugar().Errorf("list consents: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch consents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// CreatePatientConsent POST /v1/patients/:id/consents
+// Records a signed consent form. A new grant supersedes earlier records of
+// the same type, revoked ones included.
+func (h *Handlers) CreatePatientConsent(c *gin.Context) {
+	var in struct {
+		ConsentType        string `json:"consent_type" binding:"required"`
+		Scope              string `json:"scope"`
+		SignedBy           string `json:"signed_by" binding:"required"`
+		SignerRelationship string `json:"signer_relationship" binding:"omitempty,oneof=self guardian proxy"`
+		DocumentRef        string `json:"document_ref"`
+		GrantedAt          string `json:"granted_at"` // ISO-8601 expected
+		ExpiresAt          string `json:"expires_at"` // ISO-8601 expected
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validConsentType(in.ConsentType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown consent_type " + in.ConsentType})
+		return
+	}
+	if in.SignerRelationship == "" {
+		in.SignerRelationship = "self"
+	}
+	granted := time.Now()
+	if in.GrantedAt != "" {
+		t, err := time.Parse(time.RFC3339, in.GrantedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "granted_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+		if t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "granted_at cannot be in the future"})
+			return
+		}
+		granted = t
+	}
+	var expires interface{}
+	if in.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, in.ExpiresAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be ISO-8601 (RFC3339)"})
+			return
+		}
+		if !t.After(granted) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be after granted_at"})
+			return
+		}
+		expires = t
+	}
+
+	patientID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+		return
+	}
+	if !h.checkPatientAccess(c, patientID) {
+		return
+	}
+	audit.SetPatient(c, patientID)
+	var recordedBy interface{}
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		recordedBy = p.ClinicianID
+	}
+
+	var newID int64
+	err = h.DB.QueryRow(`INSERT INTO patient_consents (patient_id, consent_type, scope, signed_by, signer_relationship,
+                                                       document_ref, granted_at, expires_at, recorded_by, created_at)
+                         VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7, $8, $9, now()) RETURNING id`,
+		patientID, in.ConsentType, in.Scope, in.SignedBy, in.SignerRelationship, in.DocumentRef, granted, expires, recordedBy).Scan(&newID)
+	if err != nil {
+		h.Log.Sugar().Errorf("create consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record consent"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": newID})
+}
+
+// RevokePatientConsent POST /v1/consents/:id/revoke
+// Revoking research consent also ends the patient's research enrolment.
+func (h *Handlers) RevokePatientConsent(c *gin.Context) {
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var patientID int64
+	var consentType string
+	err := h.DB.QueryRow(`SELECT patient_id, consent_type FROM patient_consents WHERE id = $1`, c.Param("id")).
+		Scan(&patientID, &consentType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "consent not found"})
+			return
+		}
+		h.Log.Sugar().Errorf("revoke consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent"})
+		return
+	}
+	if !h.checkPatientAccess(c, patientID) {
+		return
+	}
+	audit.SetPatient(c, patientID)
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("revoke consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent"})
+		return
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE patient_consents SET revoked_at = now(), revoked_reason = NULLIF($2, '')
+                         WHERE id = $1 AND revoked_at IS NULL`, c.Param("id"), in.Reason)
+	if err != nil {
+		h.Log.Sugar().Errorf("revoke consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "consent is already revoked"})
+		return
+	}
+	if consentType == models.ConsentResearch {
+		// Unless a later grant still stands.
+		if _, err := tx.Exec(`UPDATE patients SET research_enrolled = false, updated_at = now()
+                              WHERE id = $1 AND research_enrolled
+                                AND (SELECT `+consentStatus+` FROM patient_consents
+                                     WHERE patient_id = $1 AND consent_type = $2
+                                     ORDER BY granted_at DESC, id DESC LIMIT 1) IS DISTINCT FROM 'granted'`,
+			patientID, models.ConsentResearch); err != nil {
+			h.Log.Sugar().Errorf("revoke consent: end research enrolment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("revoke consent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
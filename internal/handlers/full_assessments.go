@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/push"
+)
+
+type tissueTypeInput struct {
+	TissueType string  `json:"tissue_type" binding:"required,oneof=closed epithelial granulation slough necrotic"`
+	Percent    float64 `json:"percent" binding:"gt=0,lte=100"`
+}
+
+type imageReferenceInput struct {
+	URI        string `json:"uri" binding:"required,url"`
+	Caption    string `json:"caption"`
+	CapturedAt string `json:"captured_at"` // ISO-8601 expected
+}
+
+// fullAssessmentInput is the nested document accepted by
+// CreateFullAssessment. The measurement may be given either at the top level
+// or inside the assessment, not both.
+type fullAssessmentInput struct {
+	Assessment      assessmentInput       `json:"assessment"`
+	Measurement     *measurementInput     `json:"measurement"`
+	TissueTypes     []tissueTypeInput     `json:"tissue_types" binding:"dive"`
+	Treatments      []treatmentInput      `json:"treatments" binding:"dive"`
+	ImageReferences []imageReferenceInput `json:"image_references" binding:"dive"`
+}
+
+// fullAssessmentDoc is the validated document handed to add_full_assessment.
+// Derived measurement fields are already computed.
+type fullAssessmentDoc struct {
+	Assessment      assessmentInput               `json:"assessment"`
+	Measurement     *models.WoundMeasurement      `json:"measurement"`
+	TissueTypes     []models.AssessmentTissueType `json:"tissue_types"`
+	Treatments      []models.Treatment            `json:"treatments"`
+	ImageReferences []models.ImageReference       `json:"image_references"`
+}
+
+// CreateFullAssessment POST /v1/assessments/full
+// Writes the assessment, measurement, tissue composition, treatment orders and
+// image references in one transaction. When the database defines
+// add_full_assessment(jsonb) RETURNS bigint the validated document is handed
+// to it; otherwise the rows are inserted here. Migration 000022 creates the
+// function when the database does not already ship one, so the Go inserts
+// serve databases that have not applied it. Responds with the
+// GetAssessmentFull document.
+func (h *Handlers) CreateFullAssessment(c *gin.Context) {
+	var in fullAssessmentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	doc, ok := h.normalizeFullAssessment(c, &in)
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("create full assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	defer tx.Rollback()
+
+	var useFunc bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_proc
+                                      WHERE proname = 'add_full_assessment' AND pronargs = 1
+                                        AND proargtypes[0] = 'jsonb'::regtype AND pg_function_is_visible(oid))`).Scan(&useFunc)
+	if err != nil {
+		h.Log.Sugar().Errorf("create full assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+
+	var newID int64
+	if useFunc {
+		// add_full_assessment writes plaintext notes, so with field
+		// encryption on it gets none and the sealed notes are set after.
+		notes := doc.Assessment.Notes
+		if h.Crypt != nil {
+			doc.Assessment.Notes = ""
+		}
+		raw, err := json.Marshal(doc)
+		if err == nil {
+			err = tx.QueryRow(`SELECT add_full_assessment($1::jsonb)`, string(raw)).Scan(&newID)
+		}
+		if err == nil && h.Crypt != nil {
+			var sealed interface{}
+			if _, sealed, err = h.sealNotes(newID, &notes); err == nil {
+				_, err = tx.Exec(`UPDATE assessments SET notes = NULL, notes_encrypted = $1 WHERE id = $2`, sealed, newID)
+			}
+		}
+		if err != nil {
+			h.Log.Sugar().Errorf("add_full_assessment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+			return
+		}
+	} else {
+		// normalizeFullAssessment has run checkAssessmentInput.
+		if newID, ok = h.insertAssessment(c, tx, in.Assessment); !ok {
+			return
+		}
+		if err := insertAssessmentDetails(tx, newID, doc); err != nil {
+			h.Log.Sugar().Errorf("create full assessment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("create full assessment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create assessment"})
+		return
+	}
+	audit.SetResource(c, newID)
+	audit.SetPatient(c, in.Assessment.PatientID)
+
+	out, err := h.assessmentFull(strconv.FormatInt(newID, 10))
+	if err != nil || out == nil {
+		// The write succeeded; don't report it as failed.
+		if err != nil {
+			h.Log.Sugar().Errorf("create full assessment: load %d: %v", newID, err)
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": newID})
+		return
+	}
+	c.Data(http.StatusCreated, "application/json", out)
+}
+
+// normalizeFullAssessment validates in and normalises it into the document written
+// to the database. It writes the error response when it returns false.
+func (h *Handlers) normalizeFullAssessment(c *gin.Context, in *fullAssessmentInput) (fullAssessmentDoc, bool) {
+	doc := fullAssessmentDoc{
+		TissueTypes:     []models.AssessmentTissueType{},
+		Treatments:      []models.Treatment{},
+		ImageReferences: []models.ImageReference{},
+	}
+	if in.Measurement != nil {
+		if in.Assessment.Measurement != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "measurement given both at the top level and inside assessment"})
+			return doc, false
+		}
+		in.Assessment.Measurement = in.Measurement
+	}
+	if !h.checkAssessmentInput(c, in.Assessment) {
+		return doc, false
+	}
+	if len(in.ImageReferences) > 0 && !h.requireConsent(c, in.Assessment.PatientID, models.ConsentPhotography) {
+		return doc, false
+	}
+	if in.Assessment.Measurement != nil {
+		m := in.Assessment.Measurement.toModel()
+		m.WoundID = in.Assessment.WoundID
+		doc.Measurement = &m
+	}
+
+	// Composition must not exceed 100%; the worst tissue present becomes the
+	// scored tissue type unless the client set one.
+	seen := map[string]bool{}
+	var total float64
+	worst, worstScore := "", -1
+	for _, t := range in.TissueTypes {
+		if seen[t.TissueType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tissue type " + t.TissueType + " listed more than once"})
+			return doc, false
+		}
+		seen[t.TissueType] = true
+		total += t.Percent
+		if s, _ := push.TissueScore(t.TissueType); s > worstScore {
+			worst, worstScore = t.TissueType, s
+		}
+		doc.TissueTypes = append(doc.TissueTypes, models.AssessmentTissueType{TissueType: t.TissueType, Percent: t.Percent})
+	}
+	if total > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tissue type percentages exceed 100"})
+		return doc, false
+	}
+	if in.Assessment.TissueType == nil && worst != "" {
+		in.Assessment.TissueType = &worst
+	}
+
+	var assessmentWound sql.NullInt64
+	if in.Assessment.WoundID != nil {
+		assessmentWound = sql.NullInt64{Int64: *in.Assessment.WoundID, Valid: true}
+	}
+	for _, ti := range in.Treatments {
+		t, ok := h.treatmentFromInput(c, ti, 0, in.Assessment.PatientID, assessmentWound)
+		if !ok {
+			return doc, false
+		}
+		doc.Treatments = append(doc.Treatments, t)
+	}
+
+	for _, ri := range in.ImageReferences {
+		ref := models.ImageReference{URI: ri.URI, Caption: ri.Caption}
+		if ri.CapturedAt != "" {
+			t, err := time.Parse(time.RFC3339, ri.CapturedAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "captured_at must be ISO-8601 (RFC3339)"})
+				return doc, false
+			}
+			ref.CapturedAt = &t
+		}
+		doc.ImageReferences = append(doc.ImageReferences, ref)
+	}
+
+	doc.Assessment = in.Assessment
+	doc.Assessment.Measurement = nil
+	return doc, true
+}
+
+// insertAssessmentDetails writes the child rows of a full assessment. The
+// assessment and its measurement are inserted by insertAssessment. Migration
+// 000022's add_full_assessment writes the same rows; change both together.
+func insertAssessmentDetails(tx *sql.Tx, assessmentID int64, doc fullAssessmentDoc) error {
+	for _, t := range doc.TissueTypes {
+		if _, err := tx.Exec(`INSERT INTO assessment_tissue_types (assessment_id, tissue_type, percent) VALUES ($1, $2, $3)`,
+			assessmentID, t.TissueType, t.Percent); err != nil {
+			return err
+		}
+	}
+	for _, t := range doc.Treatments {
+		t.AssessmentID = assessmentID
+		if err := insertTreatment(tx, &t); err != nil {
+			return err
+		}
+	}
+	for _, ref := range doc.ImageReferences {
+		if _, err := tx.Exec(`INSERT INTO assessment_image_references (assessment_id, uri, caption, captured_at, created_at)
+                              VALUES ($1, $2, $3, $4, now())`,
+			assessmentID, ref.URI, ref.Caption, ref.CapturedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assessmentTissueTypes returns an assessment's wound-bed composition, largest share first.
+func (h *Handlers) assessmentTissueTypes(assessmentID string) ([]models.AssessmentTissueType, error) {
+	rows, err := h.DB.Query(`SELECT tissue_type, percent FROM assessment_tissue_types
+                             WHERE assessment_id = $1 ORDER BY percent DESC, tissue_type`, assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.AssessmentTissueType{}
+	for rows.Next() {
+		var t models.AssessmentTissueType
+		if err := rows.Scan(&t.TissueType, &t.Percent); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// assessmentImageReferences returns an assessment's external image references.
+func (h *Handlers) assessmentImageReferences(assessmentID string) ([]models.ImageReference, error) {
+	rows, err := h.DB.Query(`SELECT id, assessment_id, uri, caption, captured_at, created_at
+                             FROM assessment_image_references WHERE assessment_id = $1 ORDER BY id`, assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.ImageReference{}
+	for rows.Next() {
+		var ref models.ImageReference
+		var captured sql.NullTime
+		if err := rows.Scan(&ref.ID, &ref.AssessmentID, &ref.URI, &ref.Caption, &captured, &ref.CreatedAt); err != nil {
+			return nil, err
+		}
+		if captured.Valid {
+			v := captured.Time
+			ref.CapturedAt = &v
+		}
+		out = append(out, ref)
+	}
+	return out, rows.Err()
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/session"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
 	"go.uber.org/zap"
 )
 
@@ -11,12 +16,41 @@ type Handlers struct {
 	DB  *sql.DB
 	Log *zap.Logger
 	Cfg *config.Config
+	// Store holds wound photographs.
+	Store storage.Store
+	// Crypt encrypts PHI columns; nil when field encryption is not
+	// configured (development only).
+	Crypt *fieldcrypt.KeyRing
+	// Sessions caches revoked local-account sessions.
+	Sessions *session.Revocations
 }
 
-func NewHandlers(db *sql.DB, log *zap.Logger, cfg *config.Config) *Handlers {
-	return &Handlers{
-		DB:  db,
-		Log: log,
-		Cfg: cfg,
+func NewHandlers(db *sql.DB, log *zap.Logger, cfg *config.Config, store storage.Store) (*Handlers, error) {
+	crypt, err := fieldcrypt.FromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("field encryption: %w", err)
 	}
+	return &Handlers{
+		DB:       db,
+		Log:      log,
+		Cfg:      cfg,
+		Store:    store,
+		Crypt:    crypt,
+		Sessions: session.NewRevocations(db, log, cfg.AccessTokenTTL+cfg.JWTLeeway, cfg.SessionRevocationRefresh),
+	}, nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/imaging"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
+)
+
+const imageColumns = `id, assessment_id, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at`
+
+// scanImage scans imageColumns followed by any extra columns.
+func scanImage(s rowScanner, extra ...interface{}) (models.AssessmentImage, error) {
+	var img models.AssessmentImage
+	dest := []interface{}{&img.ID, &img.AssessmentID, &img.ContentType, &img.SizeBytes, &img.Width, &img.Height,
+		&img.StorageKey, &img.ThumbnailKey, &img.CreatedAt}
+	err := s.Scan(append(dest, extra...)...)
+	return img, err
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func imageExt(contentType string) string {
+	if contentType == imaging.TypePNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// UploadAssessmentImage POST /v1/assessments/:id/images (multipart field "image")
+// The upload is sniffed, stripped of metadata and re-encoded before storage;
+// the client-supplied filename and content type are ignored. The patient
+// must have granted photography consent.
+func (h *Handlers) UploadAssessmentImage(c *gin.Context) {
+	var assessmentID, patientID int64
+	query, args := withAccess(c, `SELECT id, patient_id FROM assessments WHERE id=$1`, "assessments.patient_id", c.Param("id"))
+	if err := h.DB.QueryRow(query, args...).Scan(&assessmentID, &patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, assessmentRestricted, c.Param("id"), "assessment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("upload image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	if !h.requireConsent(c, patientID, models.ConsentPhotography) {
+		return
+	}
+
+	limit := h.Cfg.MaxUploadBytes
+	tooLarge := gin.H{"error": "image exceeds " + strconv.FormatInt(limit, 10) + " bytes"}
+	// Leave headroom for the multipart envelope around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+64<<10)
+	fh, err := c.FormFile("image")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field \"image\" is required"})
+		return
+	}
+	if fh.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read upload"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read upload"})
+		return
+	}
+	if int64(len(data)) > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+
+	res, err := imaging.Process(data, imaging.DefaultOptions)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "only JPEG and PNG images are accepted"})
+		return
+	case errors.Is(err, imaging.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image dimensions are too large"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image"})
+		return
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		h.Log.Sugar().Errorf("upload image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+	img := models.AssessmentImage{
+		AssessmentID: assessmentID,
+		ContentType:  res.ContentType,
+		SizeBytes:    int64(len(res.Data)),
+		Width:        res.Width,
+		Height:       res.Height,
+		StorageKey:   fmt.Sprintf("assessments/%d/%s%s", assessmentID, token, imageExt(res.ContentType)),
+		ThumbnailKey: fmt.Sprintf("assessments/%d/%s_thumb%s", assessmentID, token, imageExt(res.ContentType)),
+	}
+
+	ctx := c.Request.Context()
+	if err := h.Store.Put(ctx, img.StorageKey, res.Data, img.ContentType); err != nil {
+		h.Log.Sugar().Errorf("store image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+	if err := h.Store.Put(ctx, img.ThumbnailKey, res.Thumbnail, img.ContentType); err != nil {
+		h.Log.Sugar().Errorf("store thumbnail: %v", err)
+		h.deleteImageObjects(img)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+
+	err = h.DB.QueryRow(`INSERT INTO assessment_images (assessment_id, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at)
+                          VALUES ($1, $2, $3, $4, $5, $6, $7, now()) RETURNING id, created_at`,
+		img.AssessmentID, img.ContentType, img.SizeBytes, img.Width, img.Height, img.StorageKey, img.ThumbnailKey).Scan(&img.ID, &img.CreatedAt)
+	if err != nil {
+		h.Log.Sugar().Errorf("create image: %v", err)
+		h.deleteImageObjects(img)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+	c.JSON(http.StatusCreated, img)
+}
+
+func (h *Handlers) deleteImageObjects(img models.AssessmentImage) {
+	ctx := context.Background()
+	for _, key := range []string{img.StorageKey, img.ThumbnailKey} {
+		if err := h.Store.Delete(ctx, key); err != nil {
+			h.Log.Sugar().Errorf("delete image object %s: %v", key, err)
+		}
+	}
+}
+
+// ListAssessmentImages GET /v1/assessments/:id/images
+func (h *Handlers) ListAssessmentImages(c *gin.Context) {
+	if !h.checkAssessmentAccess(c, c.Param("id")) {
+		return
+	}
+	rows, err := h.DB.Query(`SELECT `+imageColumns+` FROM assessment_images WHERE assessment_id = $1 ORDER BY id`, c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("list images: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch images"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.AssessmentImage{}
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan image: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read images"})
+			return
+		}
+		out = append(out, img)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// GetImageContent GET /v1/images/:id
+func (h *Handlers) GetImageContent(c *gin.Context) {
+	h.serveImage(c, false)
+}
+
+// GetImageThumbnail GET /v1/images/:id/thumbnail
+func (h *Handlers) GetImageThumbnail(c *gin.Context) {
+	h.serveImage(c, true)
+}
+
+func (h *Handlers) serveImage(c *gin.Context, thumb bool) {
+	query, args := withAccess(c, `SELECT `+imageColumns+`, `+imagePatient+` FROM assessment_images WHERE id=$1`, imagePatient, c.Param("id"))
+	var patientID int64
+	img, err := scanImage(h.DB.QueryRow(query, args...), &patientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, imageRestricted, c.Param("id"), "image not found")
+			return
+		}
+		h.Log.Sugar().Errorf("get image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get image"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	key := img.StorageKey
+	if thumb {
+		key = img.ThumbnailKey
+	}
+	rc, err := h.Store.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image content not found"})
+			return
+		}
+		h.Log.Sugar().Errorf("read image object: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get image"})
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"image-%d%s\"", img.ID, imageExt(img.ContentType)))
+	c.DataFromReader(http.StatusOK, -1, img.ContentType, rc, nil)
+}
+
+// DeleteImage DELETE /v1/images/:id
+func (h *Handlers) DeleteImage(c *gin.Context) {
+	query, args := withAccess(c, `DELETE FROM assessment_images WHERE id = $1`, imagePatient, c.Param("id"))
+	var patientID int64
+	img, err := scanImage(h.DB.QueryRow(query+` RETURNING `+imageColumns+`, `+imagePatient, args...), &patientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, imageRestricted, c.Param("id"), "image not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete image: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete image"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	h.deleteImageObjects(img)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/totp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when the username is unknown, so
+// the response takes as long as for a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	b, _ := bcrypt.GenerateFromPassword([]byte("wound-iq-unknown-user"), passwordCost)
+	return b
+})
+
+const errBadCredentials = "invalid username or password"
+
+// tokenResponse is the body of a successful login or refresh.
+func tokenResponse(access string, exp time.Time, refresh string, refreshExp time.Time) gin.H {
+	return gin.H{
+		"access_token":       access,
+		"token_type":         "Bearer",
+		"expires_in":         int64(time.Until(exp) / time.Second),
+		"refresh_token":      refresh,
+		"refresh_expires_at": refreshExp,
+	}
+}
+
+// signAccess signs an access token for the clinician behind userID.
+func (h *Handlers) signAccess(userID, sessionID int64, email, name string, methods []string) (string, time.Time, error) {
+	return auth.NewSigner(h.Cfg).Sign(auth.AccessToken{
+		Subject:   localSubjectPrefix + strconv.FormatInt(userID, 10),
+		Email:     email,
+		Name:      name,
+		SessionID: sessionID,
+		Methods:   methods,
+	})
+}
+
+// startSession records a new session for userID and writes the token
+// response: an access token bound to the session and its first refresh
+// token. The session, and the refresh token chain, last REFRESH_TOKEN_TTL;
+// refreshed access tokens carry the session's authentication methods.
+func (h *Handlers) startSession(c *gin.Context, userID int64, email, name string, methods []string) {
+	refresh, hash, err := newOpaqueToken()
+	if err != nil {
+		h.Log.Sugar().Errorf("start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+	var sessionID int64
+	var refreshExp time.Time
+	err = h.DB.QueryRow(`WITH s AS (
+                             INSERT INTO sessions (user_id, user_agent, ip_address, auth_methods, created_at, last_used_at, expires_at)
+                             VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $6, now(), now(), now() + $4 * interval '1 second')
+                             RETURNING id, expires_at)
+                         INSERT INTO refresh_tokens (user_id, session_id, token_hash, created_at, expires_at)
+                         SELECT $1, s.id, $5, now(), s.expires_at FROM s
+                         RETURNING session_id, expires_at`,
+		userID, c.Request.UserAgent(), c.ClientIP(), int64(h.Cfg.RefreshTokenTTL/time.Second), hash,
+		strings.Join(methods, " ")).Scan(&sessionID, &refreshExp)
+	if err != nil {
+		h.Log.Sugar().Errorf("start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+	access, exp, err := h.signAccess(userID, sessionID, email, name, methods)
+	if err != nil {
+		h.Log.Sugar().Errorf("start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(access, exp, refresh, refreshExp))
+}
+
+// recordLoginFailure counts a failed attempt and locks the account once
+// LOGIN_MAX_FAILURES are reached in a row.
+func (h *Handlers) recordLoginFailure(userID int64) {
+	var locked bool
+	err := h.DB.QueryRow(`UPDATE user_accounts
+                          SET locked_until = CASE WHEN failed_logins + 1 >= $2 THEN now() + $3 * interval '1 second' ELSE locked_until END,
+                              failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+                              updated_at = now()
+                          WHERE id = $1 RETURNING locked_until IS NOT NULL AND locked_until > now()`,
+		userID, h.Cfg.LoginMaxFailures, int64(h.Cfg.LoginLockout/time.Second)).Scan(&locked)
+	if err != nil {
+		h.Log.Sugar().Errorf("record login failure: %v", err)
+		return
+	}
+	if locked {
+		h.Log.Warn("user account locked after repeated login failures", zap.Int64("user_id", userID))
+	}
+}
+
+// Login POST /v1/auth/login
+// Accounts with MFA enabled also need totp_code; without it the response is
+// 401 with "mfa_required": true, so clients can prompt for the code and
+// retry. Locked accounts get 423 until the lockout ends, once the password
+// is right.
+func (h *Handlers) Login(c *gin.Context) {
+	var in struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		TOTPCode string `json:"totp_code"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID int64
+	var hash, email, name string
+	var secret sql.NullString
+	var mfa bool
+	var lastStep sql.NullInt64
+	var lockedUntil sql.NullTime
+	err := h.DB.QueryRow(`SELECT u.id, u.password_hash, u.totp_secret, u.totp_enabled, u.totp_last_step, u.locked_until,
+                                 COALESCE(cl.email, ''), cl.full_name
+                          FROM user_accounts u JOIN clinicians cl ON cl.id = u.clinician_id
+                          WHERE lower(u.username) = lower($1)`, in.Username).
+		Scan(&userID, &hash, &secret, &mfa, &lastStep, &lockedUntil, &email, &name)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(in.Password))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errBadCredentials})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log in"})
+		return
+	}
+	// The lockout is only revealed to someone who knows the password;
+	// anyone else cannot tell a locked account from a wrong password.
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(in.Password)) != nil {
+		h.recordLoginFailure(userID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errBadCredentials})
+		return
+	}
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		c.JSON(http.StatusLocked, gin.H{"error": "account is locked after repeated failed logins", "locked_until": lockedUntil.Time})
+		return
+	}
+
+	methods := []string{"pwd"}
+	var step interface{}
+	if mfa {
+		if in.TOTPCode == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "totp_code is required", "mfa_required": true})
+			return
+		}
+		plain, err := h.openTOTPSecret(userID, secret.String)
+		if err != nil {
+			h.Log.Sugar().Errorf("login: open totp secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log in"})
+			return
+		}
+		matched, ok := totp.Validate(plain, in.TOTPCode, time.Now())
+		// A code is good for one login only.
+		if !ok || (lastStep.Valid && matched <= lastStep.Int64) {
+			h.recordLoginFailure(userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid totp_code", "mfa_required": true})
+			return
+		}
+		step = matched
+		methods = append(methods, "otp")
+	}
+
+	// The step check is repeated here so two logins racing with the same
+	// code cannot both succeed.
+	res, err := h.DB.Exec(`UPDATE user_accounts SET failed_logins = 0, locked_until = NULL, last_login_at = now(),
+                                                  totp_last_step = COALESCE($2, totp_last_step)
+                           WHERE id = $1 AND ($2::bigint IS NULL OR totp_last_step IS NULL OR totp_last_step < $2)`, userID, step)
+	if err != nil {
+		h.Log.Sugar().Errorf("login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log in"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid totp_code", "mfa_required": true})
+		return
+	}
+	h.startSession(c, userID, email, name, methods)
+}
+
+// RefreshToken POST /v1/auth/refresh
+// Rotates the refresh token: the response carries a new one and the
+// presented one is spent. Presenting a spent token again means it was
+// copied, so the whole session is revoked and its holder, legitimate or
+// not, has to log in again.
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	var in struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	defer tx.Rollback()
+
+	var tokenID, sessionID, userID int64
+	var spent, live bool
+	var email, name, methods string
+	err = tx.QueryRow(`SELECT rt.id, rt.session_id, rt.used_at IS NOT NULL,
+                              rt.revoked_at IS NULL AND rt.expires_at > now() AND s.revoked_at IS NULL AND s.expires_at > now(),
+                              u.id, COALESCE(cl.email, ''), cl.full_name, s.auth_methods
+                       FROM refresh_tokens rt
+                       JOIN sessions s ON s.id = rt.session_id
+                       JOIN user_accounts u ON u.id = rt.user_id
+                       JOIN clinicians cl ON cl.id = u.clinician_id
+                       WHERE rt.token_hash = $1
+                       FOR UPDATE OF rt`, tokenHash(in.RefreshToken)).
+		Scan(&tokenID, &sessionID, &spent, &live, &userID, &email, &name, &methods)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	if spent && live {
+		ids, err := revokeSessions(c, tx, "refresh token reuse", `id = $2`, sessionID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			h.Log.Sugar().Errorf("refresh token: revoke session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+			return
+		}
+		h.Sessions.Add(ids...)
+		h.Log.Warn("refresh token reused; session revoked",
+			zap.Int64("user_id", userID), zap.Int64("session_id", sessionID), zap.String("ip", c.ClientIP()))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token was already used; the session has been revoked"})
+		return
+	}
+	if spent || !live {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		return
+	}
+
+	refresh, hash, err := newOpaqueToken()
+	if err != nil {
+		h.Log.Sugar().Errorf("refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	var refreshExp time.Time
+	if _, err = tx.Exec(`UPDATE refresh_tokens SET used_at = now() WHERE id = $1`, tokenID); err == nil {
+		err = tx.QueryRow(`INSERT INTO refresh_tokens (user_id, session_id, token_hash, created_at, expires_at)
+                           SELECT $1, id, $3, now(), expires_at FROM sessions WHERE id = $2
+                           RETURNING expires_at`, userID, sessionID, hash).Scan(&refreshExp)
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE sessions SET last_used_at = now() WHERE id = $1`, sessionID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	access, exp, err := h.signAccess(userID, sessionID, email, name, strings.Fields(methods))
+	if err != nil {
+		h.Log.Sugar().Errorf("refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(access, exp, refresh, refreshExp))
+}
+
+// ResetPassword POST /v1/auth/password-reset
+// Redeems a token from POST /v1/users/:id/password-reset. The account is
+// unlocked and signed out everywhere.
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	var in struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := checkPassword(in.NewPassword); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	hash, err := hashPassword(in.NewPassword)
+	if err != nil {
+		h.Log.Sugar().Errorf("reset password: hash password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	defer tx.Rollback()
+	// Claiming the token first makes it single-use even under concurrent
+	// redemption.
+	var userID int64
+	err = tx.QueryRow(`UPDATE password_reset_tokens SET used_at = now()
+                         WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+                         RETURNING user_id`, tokenHash(in.Token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		h.Log.Sugar().Errorf("reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	revoked, err := setPassword(c, tx, userID, hash, 0)
+	if err != nil {
+		h.Log.Sugar().Errorf("reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	h.Sessions.Add(revoked...)
+	c.Status(http.StatusNoContent)
+}
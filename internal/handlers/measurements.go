@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+// Largest values the wound_measurements NUMERIC columns hold.
+const (
+	maxAreaCm2   = 99999999.99
+	maxVolumeCm3 = 9999999999.99
+)
+
+// measurementInput is the client-supplied part of a measurement; area and
+// volume are never accepted from the client. Dimensions are bounded by
+// their NUMERIC(7,2) columns.
+type measurementInput struct {
+	LengthCm    float64              `json:"length_cm" binding:"gte=0,lte=99999.99"`
+	WidthCm     float64              `json:"width_cm" binding:"gte=0,lte=99999.99"`
+	DepthCm     float64              `json:"depth_cm" binding:"gte=0,lte=99999.99"`
+	Undermining []models.Undermining `json:"undermining" binding:"dive"`
+	Tunneling   []models.Tunnel      `json:"tunneling" binding:"dive"`
+}
+
+func (in measurementInput) toModel() models.WoundMeasurement {
+	m := models.WoundMeasurement{
+		LengthCm:    in.LengthCm,
+		WidthCm:     in.WidthCm,
+		DepthCm:     in.DepthCm,
+		Undermining: in.Undermining,
+		Tunneling:   in.Tunneling,
+	}
+	if m.Undermining == nil {
+		m.Undermining = []models.Undermining{}
+	}
+	if m.Tunneling == nil {
+		m.Tunneling = []models.Tunnel{}
+	}
+	m.ComputeDerived()
+	return m
+}
+
+// fits reports whether the computed area and volume fit their columns.
+func (in measurementInput) fits() bool {
+	m := in.toModel()
+	return m.AreaCm2 <= maxAreaCm2 && m.VolumeCm3 <= maxVolumeCm3
+}
+
+func insertMeasurement(tx *sql.Tx, assessmentID int64, m *models.WoundMeasurement) error {
+	undermining, err := json.Marshal(m.Undermining)
+	if err != nil {
+		return err
+	}
+	tunneling, err := json.Marshal(m.Tunneling)
+	if err != nil {
+		return err
+	}
+	return tx.QueryRow(`INSERT INTO wound_measurements (assessment_id, length_cm, width_cm, depth_cm, area_cm2, volume_cm3, undermining, tunneling, created_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now()) RETURNING id`,
+		assessmentID, m.LengthCm, m.WidthCm, m.DepthCm, m.AreaCm2, m.VolumeCm3, string(undermining), string(tunneling)).Scan(&m.ID)
+}
+
+const measurementColumns = `m.id, m.assessment_id, a.wound_id, m.length_cm, m.width_cm, m.depth_cm, m.area_cm2, m.volume_cm3, m.undermining, m.tunneling, a.created_at`
+
+func scanMeasurement(s rowScanner) (models.WoundMeasurement, error) {
+	var m models.WoundMeasurement
+	var woundID sql.NullInt64
+	var undermining, tunneling []byte
+	if err := s.Scan(&m.ID, &m.AssessmentID, &woundID, &m.LengthCm, &m.WidthCm, &m.DepthCm, &m.AreaCm2, &m.VolumeCm3, &undermining, &tunneling, &m.MeasuredAt); err != nil {
+		return m, err
+	}
+	if woundID.Valid {
+		v := woundID.Int64
+		m.WoundID = &v
+	}
+	if err := json.Unmarshal(undermining, &m.Undermining); err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(tunneling, &m.Tunneling); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// loadMeasurement returns nil without error when the assessment has no measurement.
+func (h *Handlers) loadMeasurement(assessmentID int64) (*models.WoundMeasurement, error) {
+	m, err := scanMeasurement(h.DB.QueryRow(`SELECT `+measurementColumns+`
+                                              FROM wound_measurements m JOIN assessments a ON a.id = m.assessment_id
+                                              WHERE m.assessment_id = $1`, assessmentID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListWoundMeasurements GET /v1/wounds/:id/measurements
+// Returns measurements oldest first so clients can chart healing directly.
+func (h *Handlers) ListWoundMeasurements(c *gin.Context) {
+	id := c.Param("id")
+	if !h.checkWoundAccess(c, id) {
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT `+measurementColumns+`
+                             FROM wound_measurements m JOIN assessments a ON a.id = m.assessment_id
+                             WHERE a.wound_id = $1 ORDER BY a.created_at ASC, m.id ASC`, id)
+	if err != nil {
+		h.Log.Sugar().Errorf("list wound measurements: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch measurements"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.WoundMeasurement{}
+	for rows.Next() {
+		m, err := scanMeasurement(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan measurement: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read measurements"})
+			return
+		}
+		out = append(out, m)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
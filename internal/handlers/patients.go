@@ -4,9 +4,13 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
 )
 
@@ -26,13 +30,56 @@ func parsePagination(c *gin.Context) (int, int) {
 	return page, pageSize
 }
 
+const patientColumns = `id, full_name, date_of_birth, gender, medical_record_number, created_at, updated_at, mrn_encrypted, dob_encrypted, restricted, research_enrolled`
+
+// scanPatient reads patientColumns, decrypting the MRN and date of birth.
+func (h *Handlers) scanPatient(row rowScanner) (models.Patient, error) {
+	var p models.Patient
+	var dob sql.NullTime
+	var mrn, mrnSealed, dobSealed sql.NullString
+	if err := row.Scan(&p.ID, &p.FullName, &dob, &p.Gender, &mrn, &p.CreatedAt, &p.UpdatedAt, &mrnSealed, &dobSealed, &p.Restricted, &p.ResearchEnrolled); err != nil {
+		return p, err
+	}
+	var err error
+	if p.MedicalRecordNumber, err = h.decryptField(fieldcrypt.PatientMRN, p.ID, mrn, mrnSealed); err != nil {
+		return p, err
+	}
+	if p.DateOfBirth, err = h.decryptDate(fieldcrypt.PatientDOB, p.ID, dob, dobSealed); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
 // ListPatients GET /v1/patients
 func (h *Handlers) ListPatients(c *gin.Context) {
 	page, pageSize := parsePagination(c)
 	offset := (page - 1) * pageSize
 
-	rows, err := h.DB.Query(`SELECT id, full_name, date_of_birth, gender, medical_record_number, created_at, updated_at
-                             FROM patients ORDER BY id DESC LIMIT $1 OFFSET $2`, pageSize, offset)
+	var where []string
+	args := []interface{}{pageSize, offset}
+	idx := 3
+	if mrn := c.Query("mrn"); mrn != "" {
+		// Encrypted rows are found through the blind index; rows not yet
+		// migrated by cmd/reencrypt still match on the plaintext column.
+		if h.Crypt != nil {
+			where = append(where, "(mrn_index = $"+strconv.Itoa(idx)+" OR medical_record_number = $"+strconv.Itoa(idx+1)+")")
+			args = append(args, h.Crypt.BlindIndex(fieldcrypt.PatientMRN, mrn), mrn)
+			idx += 2
+		} else {
+			where = append(where, "medical_record_number = $"+strconv.Itoa(idx))
+			args = append(args, mrn)
+			idx++
+		}
+	}
+	if cond, extra := careTeamFilter(c, "patients.id", idx); cond != "" {
+		where = append(where, cond)
+		args = append(args, extra...)
+	}
+	query := `SELECT ` + patientColumns + ` FROM patients`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := h.DB.Query(query+` ORDER BY id DESC LIMIT $1 OFFSET $2`, args...)
 	if err != nil {
 		h.Log.Sugar().Errorf("list patients: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch patients"})
@@ -41,17 +88,18 @@ func (h *Handlers) ListPatients(c *gin.Context) {
 	defer rows.Close()
 
 	patients := []models.Patient{}
+	all := seesRestricted(c)
 	for rows.Next() {
-		var p models.Patient
-		var dob sql.NullTime
-		if err := rows.Scan(&p.ID, &p.FullName, &dob, &p.Gender, &p.MedicalRecordNumber, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		p, err := h.scanPatient(rows)
+		if err != nil {
 			h.Log.Sugar().Errorf("scan patient: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read patients"})
 			return
 		}
-		if dob.Valid {
-			t := dob.Time
-			p.DateOfBirth = &t
+		// Restricted patients stay listed so they can be found for
+		// break-the-glass, but only by name.
+		if p.Restricted && !all {
+			p.DateOfBirth, p.Gender, p.MedicalRecordNumber = nil, "", ""
 		}
 		patients = append(patients, p)
 	}
@@ -66,23 +114,23 @@ func (h *Handlers) ListPatients(c *gin.Context) {
 // GetPatient GET /v1/patients/:id
 func (h *Handlers) GetPatient(c *gin.Context) {
 	id := c.Param("id")
-	var p models.Patient
-	var dob sql.NullTime
-	row := h.DB.QueryRow(`SELECT id, full_name, date_of_birth, gender, medical_record_number, created_at, updated_at 
-                          FROM patients WHERE id=$1`, id)
-	if err := row.Scan(&p.ID, &p.FullName, &dob, &p.Gender, &p.MedicalRecordNumber, &p.CreatedAt, &p.UpdatedAt); err != nil {
+	query := `SELECT ` + patientColumns + `
+              FROM patients WHERE id=$1`
+	args := []interface{}{id}
+	if cond, extra := accessFilter(c, "patients.id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	p, err := h.scanPatient(h.DB.QueryRow(query, args...))
+	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+			h.refuseAccess(c, patientRestricted, id, "patient not found")
 			return
 		}
 		h.Log.Sugar().Errorf("get patient: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get patient"})
 		return
 	}
-	if dob.Valid {
-		t := dob.Time
-		p.DateOfBirth = &t
-	}
 	c.JSON(http.StatusOK, p)
 }
 
@@ -100,6 +148,7 @@ func (h *Handlers) CreatePatient(c *gin.Context) {
 	}
 
 	var dobParam interface{}
+	var dob *time.Time
 	if in.DateOfBirth != "" {
 		t, err := time.Parse(time.RFC3339, in.DateOfBirth)
 		if err != nil {
@@ -107,17 +156,73 @@ func (h *Handlers) CreatePatient(c *gin.Context) {
 			return
 		}
 		dobParam = t
+		dob = &t
 	} else {
 		dobParam = nil
 	}
 
-	var newID int64
-	err := h.DB.QueryRow(`SELECT add_patient($1, $2, $3, $4)`, in.FullName, dobParam, in.Gender, in.MedicalRecordNumber).Scan(&newID)
+	if h.Crypt == nil {
+		var newID int64
+		err := h.DB.QueryRow(`SELECT add_patient($1, $2, $3, $4)`, in.FullName, dobParam, in.Gender, in.MedicalRecordNumber).Scan(&newID)
+		if err != nil {
+			h.Log.Sugar().Errorf("call add_patient: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
+			return
+		}
+		h.patientCreated(c, newID)
+		return
+	}
+
+	// add_patient only knows the plaintext columns, so the patient is
+	// created without MRN and date of birth and the sealed values, bound to
+	// the new row, are filled in within the same transaction.
+	tx, err := h.DB.Begin()
 	if err != nil {
+		h.Log.Sugar().Errorf("begin tx: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
+		return
+	}
+	defer tx.Rollback()
+	var newID int64
+	if err := tx.QueryRow(`SELECT add_patient($1, $2, $3, $4)`, in.FullName, nil, in.Gender, "").Scan(&newID); err != nil {
 		h.Log.Sugar().Errorf("call add_patient: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
 		return
 	}
+	var mrn *string
+	if in.MedicalRecordNumber != "" {
+		mrn = &in.MedicalRecordNumber
+	}
+	sealed, err := h.sealPatientPHI(newID, mrn, dob)
+	if err != nil {
+		h.Log.Sugar().Errorf("encrypt patient: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE patients SET medical_record_number = NULL, mrn_encrypted = $1, mrn_index = $2, dob_encrypted = $3 WHERE id = $4`,
+		sealed.mrn, sealed.mrnIndex, sealed.dob, newID); err != nil {
+		h.Log.Sugar().Errorf("store encrypted patient fields: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("commit patient: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create patient"})
+		return
+	}
+	h.patientCreated(c, newID)
+}
+
+// patientCreated finishes CreatePatient once the row exists.
+func (h *Handlers) patientCreated(c *gin.Context, newID int64) {
+	audit.SetResource(c, newID)
+	audit.SetPatient(c, newID)
+	// The registering clinician joins the care team so the patient stays visible to them.
+	if p, ok := auth.PrincipalFrom(c); ok && p.ClinicianID != 0 {
+		if err := h.assignCareTeam(c, newID, p.ClinicianID); err != nil {
+			h.Log.Sugar().Errorf("assign registering clinician: %v", err)
+		}
+	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": newID})
 }
@@ -130,24 +235,80 @@ func (h *Handlers) UpdatePatient(c *gin.Context) {
 		DateOfBirth         *string `json:"date_of_birth"`
 		Gender              *string `json:"gender"`
 		MedicalRecordNumber *string `json:"medical_record_number"`
+		ResearchEnrolled    *bool   `json:"research_enrolled"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	dob := nilIfEmptyPtr(in.DateOfBirth)
+	var sealed sealedPatient
+	if h.Crypt != nil {
+		patientID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid patient id"})
+			return
+		}
+		var t *time.Time
+		if dob != nil {
+			d := dob.(time.Time)
+			t = &d
+		}
+		if sealed, err = h.sealPatientPHI(patientID, in.MedicalRecordNumber, t); err != nil {
+			h.Log.Sugar().Errorf("encrypt patient: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient"})
+			return
+		}
+	}
 
-	_, err := h.DB.Exec(`UPDATE patients SET full_name = COALESCE($1, full_name),
-                       date_of_birth = COALESCE($2, date_of_birth),
+	// A newly sealed value clears its plaintext column.
+	query := `UPDATE patients SET full_name = COALESCE($1, full_name),
+                       date_of_birth = CASE WHEN $7::text IS NULL THEN COALESCE($2, date_of_birth) END,
+                       dob_encrypted = COALESCE($7, dob_encrypted),
                        gender = COALESCE($3, gender),
-                       medical_record_number = COALESCE($4, medical_record_number),
+                       medical_record_number = CASE WHEN $6::text IS NULL THEN COALESCE($4, medical_record_number) END,
+                       mrn_encrypted = COALESCE($6, mrn_encrypted),
+                       mrn_index = COALESCE($8, mrn_index),
+                       research_enrolled = COALESCE($9, research_enrolled),
                        updated_at = now()
-                       WHERE id = $5`,
-		in.FullName, nilIfEmptyPtr(in.DateOfBirth), in.Gender, in.MedicalRecordNumber, id)
+                       WHERE id = $5`
+	args := []interface{}{in.FullName, dob, in.Gender, in.MedicalRecordNumber, id, sealed.mrn, sealed.dob, sealed.mrnIndex, in.ResearchEnrolled}
+	if cond, extra := accessFilter(c, "patients.id", 10); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+
+	tx, err := h.DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		h.Log.Sugar().Errorf("update patient: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient"})
+		return
+	}
+	defer tx.Rollback()
+	// Enrolment needs research consent; withdrawing never does. The consent
+	// is checked in the transaction that enrols, so it cannot be revoked in
+	// between.
+	if in.ResearchEnrolled != nil && *in.ResearchEnrolled {
+		if !h.checkPatientAccess(c, id) || !h.requireConsentIn(c, tx, id, models.ConsentResearch) {
+			return
+		}
+	}
+	res, err := tx.Exec(query, args...)
 	if err != nil {
 		h.Log.Sugar().Errorf("update patient: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient"})
 		return
 	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		h.refuseAccess(c, patientRestricted, id, "patient not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		h.Log.Sugar().Errorf("update patient: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update patient"})
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
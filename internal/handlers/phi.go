@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
+)
+
+var errNoKeyRing = errors.New("encrypted value found but field encryption is not configured")
+
+// encryptField seals v for column of row id. Callers check h.Crypt first:
+// without a key ring, values are written to the plaintext columns.
+func (h *Handlers) encryptField(column string, id int64, v string) (string, error) {
+	return h.Crypt.Encrypt(column, id, v)
+}
+
+// decryptField returns a PHI value of row id from its encrypted column,
+// falling back to the plaintext column for rows not yet encrypted.
+func (h *Handlers) decryptField(column string, id int64, plain, sealed sql.NullString) (string, error) {
+	if !sealed.Valid {
+		return plain.String, nil
+	}
+	if h.Crypt == nil {
+		return "", errNoKeyRing
+	}
+	return h.Crypt.Decrypt(column, id, sealed.String)
+}
+
+// decryptDate is decryptField for dates.
+func (h *Handlers) decryptDate(column string, id int64, plain sql.NullTime, sealed sql.NullString) (*time.Time, error) {
+	if !sealed.Valid {
+		if !plain.Valid {
+			return nil, nil
+		}
+		t := plain.Time
+		return &t, nil
+	}
+	s, err := h.decryptField(column, id, sql.NullString{}, sealed)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(fieldcrypt.DateLayout, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// sealedPatient holds the encrypted column values for a patient write;
+// each is nil when the field is not being set.
+type sealedPatient struct {
+	mrn, mrnIndex, dob interface{}
+}
+
+// sealPatientPHI encrypts the MRN and date of birth of patient id that are
+// being set.
+func (h *Handlers) sealPatientPHI(id int64, mrn *string, dob *time.Time) (sealedPatient, error) {
+	var s sealedPatient
+	if mrn != nil {
+		v, err := h.encryptField(fieldcrypt.PatientMRN, id, *mrn)
+		if err != nil {
+			return s, err
+		}
+		s.mrn = v
+		s.mrnIndex = h.Crypt.BlindIndex(fieldcrypt.PatientMRN, *mrn)
+	}
+	if dob != nil {
+		v, err := h.encryptField(fieldcrypt.PatientDOB, id, dob.Format(fieldcrypt.DateLayout))
+		if err != nil {
+			return s, err
+		}
+		s.dob = v
+	}
+	return s, nil
+}
+
+// sealNotes returns the values for the notes and notes_encrypted columns of
+// assessment id: the plaintext when field encryption is off, otherwise NULL
+// and the ciphertext.
+func (h *Handlers) sealNotes(id int64, notes *string) (plain, sealed interface{}, err error) {
+	if h.Crypt == nil {
+		return *notes, nil, nil
+	}
+	v, err := h.encryptField(fieldcrypt.AssessmentNotes, id, *notes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, v, nil
+}
+
+// historyPHI returns the sealed values get_patient_wound_history cannot
+// see: the patient's MRN and date of birth by field name, and assessment
+// notes by assessment ID.
+func (h *Handlers) historyPHI(patientID string) (map[string]string, map[int64]string, error) {
+	patient := map[string]string{}
+	var id int64
+	var mrn, dob sql.NullString
+	err := h.DB.QueryRow(`SELECT id, mrn_encrypted, dob_encrypted FROM patients WHERE id = $1`, patientID).Scan(&id, &mrn, &dob)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+	if mrn.Valid {
+		if patient["medical_record_number"], err = h.decryptField(fieldcrypt.PatientMRN, id, sql.NullString{}, mrn); err != nil {
+			return nil, nil, err
+		}
+	}
+	if dob.Valid {
+		if patient["date_of_birth"], err = h.decryptField(fieldcrypt.PatientDOB, id, sql.NullString{}, dob); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rows, err := h.DB.Query(`SELECT id, notes_encrypted FROM assessments WHERE patient_id = $1 AND notes_encrypted IS NOT NULL`, patientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	notes := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var sealed sql.NullString
+		if err := rows.Scan(&id, &sealed); err != nil {
+			return nil, nil, err
+		}
+		if notes[id], err = h.decryptField(fieldcrypt.AssessmentNotes, id, sql.NullString{}, sealed); err != nil {
+			return nil, nil, err
+		}
+	}
+	return patient, notes, rows.Err()
+}
+
+// overlayHistoryPHI writes the values from historyPHI into a patient
+// history document: patient fields at the top level or under "patient",
+// notes into the matching entries of any "assessments" array. Documents
+// that are not objects are returned unchanged.
+func overlayHistoryPHI(raw []byte, patient map[string]string, notes map[int64]string) ([]byte, error) {
+	if len(patient) == 0 && len(notes) == 0 {
+		return raw, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return raw, nil
+	}
+	target := doc
+	if p, ok := doc["patient"].(map[string]interface{}); ok {
+		target = p
+	}
+	for k, v := range patient {
+		target[k] = v
+	}
+	overlayNotes(doc, notes)
+	return json.Marshal(doc)
+}
+
+func overlayNotes(v interface{}, notes map[int64]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if list, ok := v["assessments"].([]interface{}); ok {
+			for _, item := range list {
+				a, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if id, ok := a["id"].(json.Number); ok {
+					if n, err := id.Int64(); err == nil {
+						if text, ok := notes[n]; ok {
+							a["notes"] = text
+						}
+					}
+				}
+			}
+		}
+		for _, child := range v {
+			overlayNotes(child, notes)
+		}
+	case []interface{}:
+		for _, child := range v {
+			overlayNotes(child, notes)
+		}
+	}
+}
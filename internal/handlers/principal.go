@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
+	"go.uber.org/zap"
+)
+
+// ResolveClinician is middleware that links the authenticated principal to
+// the clinician with the same email and takes the caller's role from that
+// record. Callers without a clinician record (auditors, service accounts)
+// keep the first defined role from their token's roles claim, unless
+// OIDC_AUTO_PROVISION creates a record for them first. Callers whose record
+// was deleted get neither and are refused.
+func (h *Handlers) ResolveClinician(c *gin.Context) {
+	p, ok := auth.PrincipalFrom(c)
+	if !ok || p.APIKeyID != 0 {
+		c.Next()
+		return
+	}
+	var tokenRole string
+	for _, r := range p.Roles {
+		if role, ok := rbac.ParseRole(r); ok {
+			tokenRole = string(role)
+			break
+		}
+	}
+	if p.Email != "" {
+		// A disabled identity comes back as a row without an id, sorted
+		// after any clinician that has since been created for the email.
+		var id sql.NullInt64
+		var role sql.NullString
+		err := h.DB.QueryRowContext(c.Request.Context(), `SELECT id, role FROM clinicians WHERE lower(email) = lower($1)
+                                                          UNION ALL
+                                                          SELECT NULL, NULL FROM disabled_identities WHERE email = lower($1)
+                                                          ORDER BY id LIMIT 1`, p.Email).Scan(&id, &role)
+		if err == nil && !id.Valid {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "caller's clinician record has been deleted"})
+			return
+		}
+		if err == sql.ErrNoRows && h.Cfg.OIDCAutoProvision {
+			id.Int64, role.String, err = h.provisionClinician(c, p, tokenRole)
+		}
+		if err == nil {
+			p.ClinicianID = id.Int64
+			p.Role = role.String
+			c.Next()
+			return
+		}
+		if err != sql.ErrNoRows {
+			h.Log.Sugar().Errorf("resolve clinician: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve caller"})
+			return
+		}
+	}
+	p.Role = tokenRole
+	c.Next()
+}
+
+// provisionClinician creates the clinician record for a first login. The
+// role comes from the token, or OIDC_DEFAULT_ROLE; with neither the
+// clinician has no permissions until an admin assigns a role. A concurrent
+// first login may win the insert, in which case its record is used.
+func (h *Handlers) provisionClinician(c *gin.Context, p *auth.Principal, role string) (int64, string, error) {
+	if role == "" {
+		if r, ok := rbac.ParseRole(h.Cfg.OIDCDefaultRole); ok {
+			role = string(r)
+		}
+	}
+	name := p.Name
+	if name == "" {
+		name = p.Email
+	}
+	ctx := c.Request.Context()
+	var id int64
+	err := h.DB.QueryRowContext(ctx, `INSERT INTO clinicians (full_name, email, role, created_at, updated_at)
+                                      SELECT $1, $2, $3, now(), now()
+                                      WHERE NOT EXISTS (SELECT 1 FROM clinicians WHERE lower(email) = lower($2))
+                                      RETURNING id`, name, p.Email, role).Scan(&id)
+	if err == sql.ErrNoRows {
+		var existing sql.NullString
+		err = h.DB.QueryRowContext(ctx, `SELECT id, role FROM clinicians
+                                         WHERE lower(email) = lower($1) ORDER BY id LIMIT 1`, p.Email).Scan(&id, &existing)
+		return id, existing.String, err
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	h.Log.Info("provisioned clinician on first login",
+		zap.Int64("clinician_id", id), zap.String("subject", p.Subject), zap.String("role", role))
+	return id, role, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/push"
+)
+
+// pushScore returns nil when the assessment is missing the exudate amount or
+// tissue type needed to score it.
+func pushScore(lengthCm, widthCm float64, exudate, tissue string) *push.Score {
+	if exudate == "" || tissue == "" {
+		return nil
+	}
+	s, err := push.Compute(lengthCm, widthCm, exudate, tissue)
+	if err != nil {
+		return nil
+	}
+	return &s
+}
+
+// assessmentPushScore loads the inputs for one assessment and scores it.
+// Returns nil without error when the assessment cannot be scored.
+func (h *Handlers) assessmentPushScore(assessmentID string) (*push.Score, error) {
+	var exudate, tissue string
+	var length, width sql.NullFloat64
+	err := h.DB.QueryRow(`SELECT COALESCE(a.exudate_amount, ''), COALESCE(a.tissue_type, ''), m.length_cm, m.width_cm
+                          FROM assessments a LEFT JOIN wound_measurements m ON m.assessment_id = a.id
+                          WHERE a.id = $1`, assessmentID).Scan(&exudate, &tissue, &length, &width)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !length.Valid || !width.Valid {
+		return nil, nil
+	}
+	return pushScore(length.Float64, width.Float64, exudate, tissue), nil
+}
+
+type pushScoreEntry struct {
+	AssessmentID  int64     `json:"assessment_id"`
+	AssessedAt    time.Time `json:"assessed_at"`
+	LengthCm      float64   `json:"length_cm"`
+	WidthCm       float64   `json:"width_cm"`
+	ExudateAmount string    `json:"exudate_amount"`
+	TissueType    string    `json:"tissue_type"`
+	push.Score
+}
+
+// ListWoundPushScores GET /v1/wounds/:id/push-scores
+// Returns the PUSH score of every scorable assessment of the wound, oldest first.
+func (h *Handlers) ListWoundPushScores(c *gin.Context) {
+	id := c.Param("id")
+	if !h.checkWoundAccess(c, id) {
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT a.id, a.created_at, m.length_cm, m.width_cm, a.exudate_amount, a.tissue_type
+                             FROM assessments a JOIN wound_measurements m ON m.assessment_id = a.id
+                             WHERE a.wound_id = $1 AND a.exudate_amount IS NOT NULL AND a.tissue_type IS NOT NULL
+                             ORDER BY a.created_at ASC, a.id ASC`, id)
+	if err != nil {
+		h.Log.Sugar().Errorf("list push scores: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch push scores"})
+		return
+	}
+	defer rows.Close()
+
+	out := []pushScoreEntry{}
+	for rows.Next() {
+		var e pushScoreEntry
+		if err := rows.Scan(&e.AssessmentID, &e.AssessedAt, &e.LengthCm, &e.WidthCm, &e.ExudateAmount, &e.TissueType); err != nil {
+			h.Log.Sugar().Errorf("scan push score: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read push scores"})
+			return
+		}
+		s, err := push.Compute(e.LengthCm, e.WidthCm, e.ExudateAmount, e.TissueType)
+		if err != nil {
+			h.Log.Sugar().Errorf("compute push score for assessment %d: %v", e.AssessmentID, err)
+			continue
+		}
+		e.Score = s
+		out = append(out, e)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
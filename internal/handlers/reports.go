@@ -2,14 +2,21 @@ package handlers
 
 import (
     "database/sql"
+    "encoding/json"
+    "fmt"
     "net/http"
+    "time"
 
     "github.com/gin-gonic/gin"
+    "github.com/vellalasantosh/wound_iq_api_new/internal/analytics"
 )
 
 // GetPatientHistory GET /v1/patients/:id/history
 func (h *Handlers) GetPatientHistory(c *gin.Context) {
     id := c.Param("id")
+    if !h.checkPatientAccess(c, id) {
+        return
+    }
     var result sql.NullString
     err := h.DB.QueryRow(`SELECT get_patient_wound_history($1)`, id).Scan(&result)
     if err != nil {
@@ -21,9 +28,105 @@ func (h *Handlers) GetPatientHistory(c *gin.Context) {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
         return
     }
-    if !result.Valid {
-        c.JSON(http.StatusOK, gin.H{"data": nil})
+    raw := []byte("null")
+    if result.Valid {
+        raw = []byte(result.String)
+    }
+    // get_patient_wound_history only sees the plaintext columns.
+    if h.Crypt != nil {
+        patient, notes, err := h.historyPHI(id)
+        if err == nil {
+            raw, err = overlayHistoryPHI(raw, patient, notes)
+        }
+        if err != nil {
+            h.Log.Sugar().Errorf("patient history decrypt: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
+            return
+        }
+    }
+
+    bradens, err := h.patientBraden(id)
+    if err != nil {
+        h.Log.Sugar().Errorf("patient history braden: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
+        return
+    }
+    consents, err := h.patientConsents(id, "")
+    if err != nil {
+        h.Log.Sugar().Errorf("patient history consents: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
         return
     }
-    c.Data(http.StatusOK, "application/json", []byte(result.String))
+    out, err := mergeJSONObject(raw, "history", map[string]interface{}{"braden_assessments": bradens, "consents": consents})
+    if err != nil {
+        h.Log.Sugar().Errorf("patient history merge: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
+        return
+    }
+    c.Data(http.StatusOK, "application/json", out)
+}
+
+// GetWoundTrajectory GET /v1/wounds/:id/trajectory
+// Computes percent area reduction and healing rate from the wound's
+// measurement history; see the analytics package for the rules applied.
+func (h *Handlers) GetWoundTrajectory(c *gin.Context) {
+    id := c.Param("id")
+    if !h.checkWoundAccess(c, id) {
+        return
+    }
+
+    rows, err := h.DB.Query(`SELECT a.id, a.created_at, m.area_cm2
+                             FROM wound_measurements m JOIN assessments a ON a.id = m.assessment_id
+                             WHERE a.wound_id = $1 ORDER BY a.created_at ASC, m.id ASC`, id)
+    if err != nil {
+        h.Log.Sugar().Errorf("wound trajectory: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute trajectory"})
+        return
+    }
+    defer rows.Close()
+
+    points := []analytics.Point{}
+    for rows.Next() {
+        var p analytics.Point
+        if err := rows.Scan(&p.AssessmentID, &p.MeasuredAt, &p.AreaCm2); err != nil {
+            h.Log.Sugar().Errorf("scan trajectory point: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute trajectory"})
+            return
+        }
+        points = append(points, p)
+    }
+
+    t, err := analytics.Analyze(points, time.Now())
+    if err == analytics.ErrNoMeasurements {
+        c.JSON(http.StatusNotFound, gin.H{"error": "wound has no measurements"})
+        return
+    }
+    if err != nil {
+        h.Log.Sugar().Errorf("wound trajectory: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute trajectory"})
+        return
+    }
+    c.JSON(http.StatusOK, t)
+}
+
+// mergeJSONObject adds extra keys to a JSON document produced by a DB
+// function. A document that is not an object, null included, is always
+// wrapped as {wrapKey: <doc>} first rather than returned unchanged, so the
+// extra keys are never dropped and the response is always an object.
+func mergeJSONObject(raw []byte, wrapKey string, extra map[string]interface{}) ([]byte, error) {
+    var obj map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+        if !json.Valid(raw) {
+            return nil, fmt.Errorf("%s document is not valid JSON", wrapKey)
+        }
+        obj = map[string]json.RawMessage{wrapKey: json.RawMessage(raw)}
+    }
+    for k, v := range extra {
+        b, err := json.Marshal(v)
+        if err != nil {
+            return nil, err
+        }
+        obj[k] = b
+    }
+    return json.Marshal(obj)
 }
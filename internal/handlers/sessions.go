@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const sessionColumns = `id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_used_at, expires_at`
+
+// revokeSessions revokes the live sessions matched by cond, a WHERE
+// fragment over sessions whose placeholders start at $2, and returns their
+// IDs. Callers add the IDs to h.Sessions once the revocation is committed.
+func revokeSessions(c *gin.Context, q querier, reason, cond string, args ...interface{}) ([]int64, error) {
+	rows, err := q.QueryContext(c.Request.Context(), `UPDATE sessions SET revoked_at = now(), revoked_reason = $1
+                                                       WHERE revoked_at IS NULL AND `+cond+` RETURNING id`,
+		append([]interface{}{reason}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CheckSession is middleware that refuses access tokens whose session has
+// been revoked. It reads the cached revocation list, not the database, so
+// a revocation reaches other instances within SESSION_REVOCATION_REFRESH.
+// Tokens for a local account must name their session; without one no
+// revocation could ever cut them off.
+func (h *Handlers) CheckSession(c *gin.Context) {
+	p, ok := auth.PrincipalFrom(c)
+	if !ok || !strings.HasPrefix(p.Subject, localSubjectPrefix) {
+		c.Next()
+		return
+	}
+	var reason string
+	switch {
+	case p.SessionID == 0:
+		reason = "access token has no session"
+	case h.Sessions.Revoked(c.Request.Context(), p.SessionID):
+		reason = "session has been revoked"
+	default:
+		c.Next()
+		return
+	}
+	c.Header("WWW-Authenticate", `Bearer realm="wound_iq", error="invalid_token"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": reason})
+}
+
+// activeSessions lists userID's live sessions, most recently used first,
+// marking current.
+func (h *Handlers) activeSessions(c *gin.Context, userID, current int64) ([]models.Session, error) {
+	rows, err := h.DB.QueryContext(c.Request.Context(), `SELECT `+sessionColumns+` FROM sessions
+                                                         WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+                                                         ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		s.Current = s.ID == current
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// endSessions revokes sessions and answers 204, or 404 when none matched
+// and notFound is set.
+func (h *Handlers) endSessions(c *gin.Context, notFound, reason, cond string, args ...interface{}) {
+	ids, err := revokeSessions(c, h.DB, reason, cond, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+	h.Sessions.Add(ids...)
+	if len(ids) == 0 && notFound != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListSessions GET /v1/auth/sessions
+func (h *Handlers) ListSessions(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	p, _ := auth.PrincipalFrom(c)
+	out, err := h.activeSessions(c, userID, p.SessionID)
+	if err != nil {
+		h.Log.Sugar().Errorf("list sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// RevokeSession DELETE /v1/auth/sessions/:id
+// Signs one of the caller's devices out.
+func (h *Handlers) RevokeSession(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	h.endSessions(c, "session not found", "revoked by user", `id = $2 AND user_id = $3`, c.Param("id"), userID)
+}
+
+// Logout POST /v1/auth/logout
+// Ends the session of the access token making the request.
+func (h *Handlers) Logout(c *gin.Context) {
+	if _, ok := localAccount(c); !ok {
+		return
+	}
+	p, _ := auth.PrincipalFrom(c)
+	if p.SessionID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access token has no session"})
+		return
+	}
+	h.endSessions(c, "", "logout", `id = $2`, p.SessionID)
+}
+
+// LogoutEverywhere POST /v1/auth/logout-all
+func (h *Handlers) LogoutEverywhere(c *gin.Context) {
+	userID, ok := localAccount(c)
+	if !ok {
+		return
+	}
+	h.endSessions(c, "", "logout everywhere", `user_id = $2`, userID)
+}
+
+// ListUserSessions GET /v1/users/:id/sessions
+func (h *Handlers) ListUserSessions(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user account not found"})
+		return
+	}
+	out, err := h.activeSessions(c, userID, 0)
+	if err != nil {
+		h.Log.Sugar().Errorf("list user sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// RevokeUserSessions DELETE /v1/users/:id/sessions
+// Signs a user out everywhere, e.g. when a device is lost.
+func (h *Handlers) RevokeUserSessions(c *gin.Context) {
+	h.endSessions(c, "", "revoked by admin", `user_id = $2`, c.Param("id"))
+}
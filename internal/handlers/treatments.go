@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+const treatmentColumns = `id, assessment_id, wound_id, cleanser, primary_dressing, secondary_dressing, offloading,
+                          change_frequency, status, started_at, discontinued_at, created_at, updated_at`
+
+// scanTreatment scans treatmentColumns followed by any extra columns.
+func scanTreatment(s rowScanner, extra ...interface{}) (models.Treatment, error) {
+	var t models.Treatment
+	var discontinued sql.NullTime
+	dest := []interface{}{&t.ID, &t.AssessmentID, &t.WoundID, &t.Cleanser, &t.PrimaryDressing, &t.SecondaryDressing, &t.Offloading,
+		&t.ChangeFrequency, &t.Status, &t.StartedAt, &discontinued, &t.CreatedAt, &t.UpdatedAt}
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return t, err
+	}
+	if discontinued.Valid {
+		v := discontinued.Time
+		t.DiscontinuedAt = &v
+	}
+	return t, nil
+}
+
+// treatmentInput is the body of a new treatment order. WoundID defaults to
+// the assessment's wound.
+type treatmentInput struct {
+	WoundID           *int64 `json:"wound_id"`
+	Cleanser          string `json:"cleanser"`
+	PrimaryDressing   string `json:"primary_dressing"`
+	SecondaryDressing string `json:"secondary_dressing"`
+	Offloading        string `json:"offloading"`
+	ChangeFrequency   string `json:"change_frequency"`
+	StartedAt         string `json:"started_at"` // ISO-8601, defaults to now
+}
+
+func insertTreatment(q queryRower, t *models.Treatment) error {
+	return q.QueryRow(`INSERT INTO treatments (assessment_id, wound_id, cleanser, primary_dressing, secondary_dressing, offloading,
+                                               change_frequency, status, started_at, created_at, updated_at)
+                       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now()) RETURNING id, created_at, updated_at`,
+		t.AssessmentID, t.WoundID, t.Cleanser, t.PrimaryDressing, t.SecondaryDressing, t.Offloading,
+		t.ChangeFrequency, t.Status, t.StartedAt).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// assessmentTreatments returns an assessment's treatment orders, oldest first.
+func (h *Handlers) assessmentTreatments(assessmentID string) ([]models.Treatment, error) {
+	rows, err := h.DB.Query(`SELECT `+treatmentColumns+` FROM treatments WHERE assessment_id = $1 ORDER BY started_at, id`, assessmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Treatment{}
+	for rows.Next() {
+		t, err := scanTreatment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// ListAssessmentTreatments GET /v1/assessments/:id/treatments
+func (h *Handlers) ListAssessmentTreatments(c *gin.Context) {
+	if !h.checkAssessmentAccess(c, c.Param("id")) {
+		return
+	}
+	out, err := h.assessmentTreatments(c.Param("id"))
+	if err != nil {
+		h.Log.Sugar().Errorf("list treatments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch treatments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// CreateAssessmentTreatment POST /v1/assessments/:id/treatments
+func (h *Handlers) CreateAssessmentTreatment(c *gin.Context) {
+	var in treatmentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var assessmentID, patientID int64
+	var assessmentWound sql.NullInt64
+	query, args := withAccess(c, `SELECT id, patient_id, wound_id FROM assessments WHERE id = $1`, "assessments.patient_id", c.Param("id"))
+	err := h.DB.QueryRow(query, args...).Scan(&assessmentID, &patientID, &assessmentWound)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, assessmentRestricted, c.Param("id"), "assessment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("create treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create treatment"})
+		return
+	}
+
+	t, ok := h.treatmentFromInput(c, in, assessmentID, patientID, assessmentWound)
+	if !ok {
+		return
+	}
+	if err := insertTreatment(h.DB, &t); err != nil {
+		h.Log.Sugar().Errorf("create treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create treatment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.JSON(http.StatusCreated, t)
+}
+
+// treatmentFromInput validates in against its assessment and writes the
+// error response when it returns false.
+func (h *Handlers) treatmentFromInput(c *gin.Context, in treatmentInput, assessmentID, patientID int64, assessmentWound sql.NullInt64) (models.Treatment, bool) {
+	t := models.Treatment{
+		AssessmentID:      assessmentID,
+		Cleanser:          in.Cleanser,
+		PrimaryDressing:   in.PrimaryDressing,
+		SecondaryDressing: in.SecondaryDressing,
+		Offloading:        in.Offloading,
+		ChangeFrequency:   in.ChangeFrequency,
+		Status:            models.TreatmentStatusActive,
+		StartedAt:         time.Now(),
+	}
+	switch {
+	case in.WoundID != nil:
+		t.WoundID = *in.WoundID
+	case assessmentWound.Valid:
+		t.WoundID = assessmentWound.Int64
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wound_id is required when the assessment has no wound"})
+		return t, false
+	}
+	if assessmentWound.Valid && assessmentWound.Int64 != t.WoundID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wound_id does not match the assessment's wound"})
+		return t, false
+	}
+	if !h.checkWoundPatient(c, t.WoundID, patientID) {
+		return t, false
+	}
+	if in.StartedAt != "" {
+		v, err := time.Parse(time.RFC3339, in.StartedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "started_at must be ISO-8601 (RFC3339)"})
+			return t, false
+		}
+		t.StartedAt = v
+	}
+	return t, true
+}
+
+// GetTreatment GET /v1/treatments/:id
+func (h *Handlers) GetTreatment(c *gin.Context) {
+	query, args := withAccess(c, `SELECT `+treatmentColumns+`, `+treatmentPatient+` FROM treatments WHERE id=$1`, treatmentPatient, c.Param("id"))
+	var patientID int64
+	t, err := scanTreatment(h.DB.QueryRow(query, args...), &patientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, treatmentRestricted, c.Param("id"), "treatment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("get treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get treatment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.JSON(http.StatusOK, t)
+}
+
+// UpdateTreatment PUT /v1/treatments/:id
+// Setting status to "discontinued" stamps discontinued_at.
+func (h *Handlers) UpdateTreatment(c *gin.Context) {
+	id := c.Param("id")
+	var in struct {
+		Cleanser          *string `json:"cleanser"`
+		PrimaryDressing   *string `json:"primary_dressing"`
+		SecondaryDressing *string `json:"secondary_dressing"`
+		Offloading        *string `json:"offloading"`
+		ChangeFrequency   *string `json:"change_frequency"`
+		Status            *string `json:"status" binding:"omitempty,oneof=active discontinued"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, args := withAccess(c, `UPDATE treatments SET
+                       cleanser = COALESCE($1, cleanser),
+                       primary_dressing = COALESCE($2, primary_dressing),
+                       secondary_dressing = COALESCE($3, secondary_dressing),
+                       offloading = COALESCE($4, offloading),
+                       change_frequency = COALESCE($5, change_frequency),
+                       discontinued_at = CASE
+                           WHEN $6::text = 'discontinued' AND status <> 'discontinued' THEN now()
+                           WHEN $6::text = 'active' THEN NULL
+                           ELSE discontinued_at END,
+                       status = COALESCE($6, status),
+                       updated_at = now()
+                       WHERE id = $7`, treatmentPatient,
+		in.Cleanser, in.PrimaryDressing, in.SecondaryDressing, in.Offloading, in.ChangeFrequency, in.Status, id)
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING `+treatmentPatient, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, treatmentRestricted, id, "treatment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("update treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update treatment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTreatment DELETE /v1/treatments/:id
+func (h *Handlers) DeleteTreatment(c *gin.Context) {
+	query, args := withAccess(c, `DELETE FROM treatments WHERE id = $1`, treatmentPatient, c.Param("id"))
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING `+treatmentPatient, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, treatmentRestricted, c.Param("id"), "treatment not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete treatment"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// GetWoundCurrentTreatment GET /v1/wounds/:id/treatments/current
+// Returns the most recently started active order for the wound.
+func (h *Handlers) GetWoundCurrentTreatment(c *gin.Context) {
+	if !h.checkWoundAccess(c, c.Param("id")) {
+		return
+	}
+	t, err := scanTreatment(h.DB.QueryRow(`SELECT `+treatmentColumns+` FROM treatments
+                                           WHERE wound_id = $1 AND status = 'active'
+                                           ORDER BY started_at DESC, id DESC LIMIT 1`, c.Param("id")))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no active treatment for wound"})
+			return
+		}
+		h.Log.Sugar().Errorf("current treatment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get current treatment"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+var (
+	errWoundNotFound        = errors.New("wound not found")
+	errWoundPatientMismatch = errors.New("wound does not belong to patient")
+)
+
+const woundColumns = `id, patient_id, anatomical_location, etiology, onset_date, present_on_admission, status, created_at, updated_at`
+
+func scanWound(s rowScanner) (models.Wound, error) {
+	var w models.Wound
+	var onset sql.NullTime
+	if err := s.Scan(&w.ID, &w.PatientID, &w.AnatomicalLocation, &w.Etiology, &onset, &w.PresentOnAdmission, &w.Status, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return w, err
+	}
+	if onset.Valid {
+		t := onset.Time
+		w.OnsetDate = &t
+	}
+	return w, nil
+}
+
+// ListWounds GET /v1/wounds?patient_id=&status=&page=&page_size=
+func (h *Handlers) ListWounds(c *gin.Context) {
+	h.listWounds(c, c.Query("patient_id"))
+}
+
+// ListPatientWounds GET /v1/patients/:id/wounds?status=&page=&page_size=
+func (h *Handlers) ListPatientWounds(c *gin.Context) {
+	h.listWounds(c, c.Param("id"))
+}
+
+func (h *Handlers) listWounds(c *gin.Context, patientID string) {
+	page, pageSize := parsePagination(c)
+	offset := (page - 1) * pageSize
+
+	var args []interface{}
+	where := []string{}
+	idx := 1
+
+	if patientID != "" {
+		where = append(where, "patient_id = $"+strconv.Itoa(idx))
+		args = append(args, patientID)
+		idx++
+	}
+	if v := c.Query("status"); v != "" {
+		where = append(where, "status = $"+strconv.Itoa(idx))
+		args = append(args, v)
+		idx++
+	}
+
+	where, args, idx = scoped(c, "wounds.patient_id", where, args, idx)
+
+	base := `SELECT ` + woundColumns + ` FROM wounds`
+	if len(where) > 0 {
+		base += " WHERE " + strings.Join(where, " AND ")
+	}
+	base += " ORDER BY id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	args = append(args, pageSize, offset)
+
+	rows, err := h.DB.Query(base, args...)
+	if err != nil {
+		h.Log.Sugar().Errorf("list wounds: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch wounds"})
+		return
+	}
+	defer rows.Close()
+
+	out := []models.Wound{}
+	for rows.Next() {
+		w, err := scanWound(rows)
+		if err != nil {
+			h.Log.Sugar().Errorf("scan wound: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read wounds"})
+			return
+		}
+		out = append(out, w)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out, "page": page, "page_size": pageSize})
+}
+
+// GetWound GET /v1/wounds/:id
+func (h *Handlers) GetWound(c *gin.Context) {
+	id := c.Param("id")
+	query := `SELECT ` + woundColumns + ` FROM wounds WHERE id=$1`
+	args := []interface{}{id}
+	if cond, extra := accessFilter(c, "wounds.patient_id", 2); cond != "" {
+		query += " AND " + cond
+		args = append(args, extra...)
+	}
+	w, err := scanWound(h.DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wound not found"})
+			return
+		}
+		h.Log.Sugar().Errorf("get wound: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get wound"})
+		return
+	}
+	audit.SetPatient(c, w.PatientID)
+	c.JSON(http.StatusOK, w)
+}
+
+// CreateWound POST /v1/wounds
+func (h *Handlers) CreateWound(c *gin.Context) {
+	var in struct {
+		PatientID          int64  `json:"patient_id" binding:"required"`
+		AnatomicalLocation string `json:"anatomical_location" binding:"required"`
+		Etiology           string `json:"etiology"`
+		OnsetDate          string `json:"onset_date"` // ISO-8601 expected
+		PresentOnAdmission bool   `json:"present_on_admission"`
+		Status             string `json:"status" binding:"omitempty,oneof=open healed closed"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if in.Status == "" {
+		in.Status = models.WoundStatusOpen
+	}
+
+	var onsetParam interface{}
+	if in.OnsetDate != "" {
+		t, err := time.Parse(time.RFC3339, in.OnsetDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "onset_date must be ISO-8601 (RFC3339)"})
+			return
+		}
+		onsetParam = t
+	}
+	// An unknown patient_id gets 404 here rather than a foreign key error,
+	// the same answer as a patient outside the caller's care team.
+	if !h.checkPatientAccess(c, in.PatientID) {
+		return
+	}
+	audit.SetPatient(c, in.PatientID)
+
+	var newID int64
+	err := h.DB.QueryRow(`INSERT INTO wounds (patient_id, anatomical_location, etiology, onset_date, present_on_admission, status, created_at, updated_at)
+                          VALUES ($1, $2, $3, $4, $5, $6, now(), now()) RETURNING id`,
+		in.PatientID, in.AnatomicalLocation, in.Etiology, onsetParam, in.PresentOnAdmission, in.Status).Scan(&newID)
+	if err != nil {
+		h.Log.Sugar().Errorf("create wound: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create wound"})
+		return
+	}
+	audit.SetResource(c, newID)
+	c.JSON(http.StatusCreated, gin.H{"id": newID})
+}
+
+// UpdateWound PUT /v1/wounds/:id
+// patient_id is immutable: assessments already reference the wound.
+func (h *Handlers) UpdateWound(c *gin.Context) {
+	id := c.Param("id")
+	var in struct {
+		AnatomicalLocation *string `json:"anatomical_location"`
+		Etiology           *string `json:"etiology"`
+		OnsetDate          *string `json:"onset_date"`
+		PresentOnAdmission *bool   `json:"present_on_admission"`
+		Status             *string `json:"status" binding:"omitempty,oneof=open healed closed"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var onsetParam interface{}
+	if in.OnsetDate != nil && *in.OnsetDate != "" {
+		t, err := time.Parse(time.RFC3339, *in.OnsetDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "onset_date must be ISO-8601 (RFC3339)"})
+			return
+		}
+		onsetParam = t
+	}
+
+	query, args := withAccess(c, `UPDATE wounds SET
+                       anatomical_location = COALESCE($1, anatomical_location),
+                       etiology = COALESCE($2, etiology),
+                       onset_date = COALESCE($3, onset_date),
+                       present_on_admission = COALESCE($4, present_on_admission),
+                       status = COALESCE($5, status),
+                       updated_at = now()
+                       WHERE id = $6`, "wounds.patient_id",
+		in.AnatomicalLocation, in.Etiology, onsetParam, in.PresentOnAdmission, in.Status, id)
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, woundRestricted, id, "wound not found")
+			return
+		}
+		h.Log.Sugar().Errorf("update wound: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update wound"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteWound DELETE /v1/wounds/:id
+func (h *Handlers) DeleteWound(c *gin.Context) {
+	id := c.Param("id")
+	query, args := withAccess(c, `DELETE FROM wounds WHERE id = $1`, "wounds.patient_id", id)
+	var patientID int64
+	if err := h.DB.QueryRow(query+` RETURNING patient_id`, args...).Scan(&patientID); err != nil {
+		if err == sql.ErrNoRows {
+			h.refuseAccess(c, woundRestricted, id, "wound not found")
+			return
+		}
+		h.Log.Sugar().Errorf("delete wound: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete wound"})
+		return
+	}
+	audit.SetPatient(c, patientID)
+	c.Status(http.StatusNoContent)
+}
+
+// validateWoundPatient reports errWoundNotFound or errWoundPatientMismatch
+// when woundID cannot be attached to an assessment for patientID.
+func (h *Handlers) validateWoundPatient(woundID, patientID int64) error {
+	var owner int64
+	err := h.DB.QueryRow(`SELECT patient_id FROM wounds WHERE id = $1`, woundID).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return errWoundNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if owner != patientID {
+		return errWoundPatientMismatch
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import "encoding/binary"
+
+const exifOrientationTag = 0x0112
+
+// jpegOrientation returns the EXIF orientation stored in a JPEG's APP1
+// segment, or 1 (upright) when absent or unreadable.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if segLen < 2 || i+2+segLen > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+segLen]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + segLen
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < count; n++ {
+		e := ifd + 2 + n*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:e+2]) != exifOrientationTag {
+			continue
+		}
+		v := int(order.Uint16(tiff[e+8 : e+10]))
+		if v < 1 || v > 8 {
+			return 1
+		}
+		return v
+	}
+	return 1
+}
@@ -0,0 +1,224 @@
+// Package imaging sanitises uploaded wound photographs. Every image is
+// decoded and re-encoded, which drops EXIF (including GPS coordinates), XMP,
+// comments and any other embedded metadata; the EXIF orientation is applied
+// to the pixels first so photos still display upright.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("imaging: unsupported image type")
+	ErrTooLarge        = errors.New("imaging: image dimensions exceed limit")
+)
+
+// slots caps how many images are decoded at once, bounding the memory a
+// burst of uploads can take.
+var slots = make(chan struct{}, 4)
+
+// Supported content types.
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+)
+
+// Options controls processing limits.
+type Options struct {
+	MaxPixels     int // rejects decompression bombs before decoding
+	ThumbnailSize int // longest edge of the thumbnail, in pixels
+	JPEGQuality   int
+}
+
+// DefaultOptions suit phone camera photos. Processing holds up to two RGBA
+// copies of the image, 80 MB each at the pixel limit.
+var DefaultOptions = Options{
+	MaxPixels:     20_000_000,
+	ThumbnailSize: 256,
+	JPEGQuality:   90,
+}
+
+// Result is a sanitised image and its thumbnail, both encoded in the
+// original format.
+type Result struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	Thumbnail   []byte
+}
+
+// Sniff returns the content type detected from the data itself, ignoring
+// whatever the client claimed.
+func Sniff(data []byte) (string, error) {
+	ct := http.DetectContentType(data)
+	switch ct {
+	case TypeJPEG, TypePNG:
+		return ct, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, ct)
+	}
+}
+
+// Process validates, strips and re-encodes an uploaded image.
+func Process(data []byte, opts Options) (*Result, error) {
+	ct, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("imaging: decode config: %w", err)
+	}
+	if opts.MaxPixels > 0 && cfg.Width*cfg.Height > opts.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	var src image.Image
+	orientation := 1
+	switch ct {
+	case TypeJPEG:
+		src, err = jpeg.Decode(bytes.NewReader(data))
+		orientation = jpegOrientation(data)
+	case TypePNG:
+		src, err = png.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imaging: decode: %w", err)
+	}
+
+	img := toRGBA(src)
+	img = orient(img, orientation)
+
+	out, err := encode(img, ct, opts)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encode(thumbnail(img, opts.ThumbnailSize), ct, opts)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	return &Result{
+		ContentType: ct,
+		Data:        out,
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+		Thumbnail:   thumb,
+	}, nil
+}
+
+func encode(img image.Image, ct string, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ct {
+	case TypeJPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.JPEGQuality})
+	case TypePNG:
+		err = png.Encode(&buf, img)
+	default:
+		err = ErrUnsupportedType
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imaging: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) to img.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			si := img.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// thumbnail scales img to fit within size x size using box filtering.
+func thumbnail(img *image.RGBA, size int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if size <= 0 || (w <= size && h <= size) {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, (ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, (tx+1)*w/tw
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				i := img.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					a += int(img.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+			di := dst.PixOffset(tx, ty)
+			dst.Pix[di] = uint8(r / n)
+			dst.Pix[di+1] = uint8(g / n)
+			dst.Pix[di+2] = uint8(b / n)
+			dst.Pix[di+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
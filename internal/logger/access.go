@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/requestid"
+)
+
+// AccessLog logs one line per request through log. It records the matched
+// route template ("/v1/patients/:id") rather than the raw path, and query
+// strings with the values of r's fields masked, so identifiers and search
+// terms do not reach the log.
+func AccessLog(log *zap.Logger, r *Redactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "(unmatched)"
+		}
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("request_id", requestid.Get(c)),
+			zap.String("client_ip", c.ClientIP()),
+		}
+		if q := redactQuery(c.Request.URL.Query(), r); q != "" {
+			fields = append(fields, zap.String("query", q))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			log.Error("request", fields...)
+		case status >= http.StatusBadRequest:
+			log.Warn("request", fields...)
+		default:
+			log.Info("request", fields...)
+		}
+	}
+}
+
+// redactQuery re-encodes q with sensitive values masked, keys sorted.
+func redactQuery(q url.Values, r *Redactor) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range q[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k) + "=")
+			if r.Field(k) {
+				b.WriteString(Redacted)
+			} else {
+				b.WriteString(url.QueryEscape(v))
+			}
+		}
+	}
+	return b.String()
+}
+
+// Recovery answers 500 after a panic and logs it through log, replacing
+// gin.Recovery, whose request dump goes to stderr outside the redactor.
+func Recovery(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error("panic recovered",
+					zap.Any("panic", rec),
+					zap.String("route", c.FullPath()),
+					zap.String("request_id", requestid.Get(c)),
+					zap.ByteString("stack", debug.Stack()))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			}
+		}()
+		c.Next()
+	}
+}
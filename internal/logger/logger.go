@@ -7,6 +7,8 @@ import (
 	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
 )
 
+// New builds the application logger. Everything written through it passes
+// the redactor built from cfg.
 func New(cfg *config.Config) *zap.Logger {
 	var level zapcore.Level
 	switch cfg.LogLevel {
@@ -22,6 +24,17 @@ func New(cfg *config.Config) *zap.Logger {
 	cfgZap := zap.NewProductionConfig()
 	cfgZap.Encoding = "json"
 	cfgZap.Level = zap.NewAtomicLevelAt(level)
-	logger, _ := cfgZap.Build()
+	redactor := NewRedactor(RedactFields(cfg))
+	logger, _ := cfgZap.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return RedactCore(core, redactor)
+	}))
 	return logger
 }
+
+// RedactFields is the configured field list, or DefaultRedactFields.
+func RedactFields(cfg *config.Config) []string {
+	if len(cfg.LogRedactFields) > 0 {
+		return cfg.LogRedactFields
+	}
+	return DefaultRedactFields
+}
@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces masked values.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactFields are masked when LOG_REDACT_FIELDS is unset: patient
+// names, dates of birth, MRNs and free-text clinical notes.
+var DefaultRedactFields = []string{
+	"full_name", "name", "patient_name",
+	"date_of_birth", "dob",
+	"medical_record_number", "mrn",
+	"notes", "note", "caption", "reason",
+}
+
+// pgValue matches the "Key (col)=(value)" detail Postgres attaches to
+// constraint errors, and pgInput the offending input it quotes in
+// "invalid input syntax" errors.
+var (
+	pgValue = regexp.MustCompile(`\(([^()]*)\)=\(([^()]*)\)`)
+	pgInput = regexp.MustCompile(`(?i)(invalid input (?:syntax|value) for [^:]*:\s*)"[^"]*"`)
+)
+
+// Redactor masks sensitive values by field name, both in structured log
+// fields and in free text such as error messages.
+type Redactor struct {
+	fields map[string]bool
+	inText *regexp.Regexp
+}
+
+// NewRedactor masks the given field names, matched case-insensitively.
+func NewRedactor(fields []string) *Redactor {
+	r := &Redactor{fields: map[string]bool{}}
+	var names []string
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || r.fields[f] {
+			continue
+		}
+		r.fields[f] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+	if len(names) > 0 {
+		// Longest first so "date_of_birth" wins over a shorter prefix.
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		// name=value, name: value and "name":"value"; the value runs to the
+		// closing quote or the next separator. Already-masked values match
+		// whole so redacting twice is harmless.
+		r.inText = regexp.MustCompile(`(?i)\b(` + strings.Join(names, "|") + `)\b("?\s*[:=]\s*)(` + regexp.QuoteMeta(Redacted) + `|"(?:[^"\\]|\\.)*"|'[^']*'|[^\s,;&)}\]]+)`)
+	}
+	return r
+}
+
+// Field reports whether values under key are masked.
+func (r *Redactor) Field(key string) bool {
+	return r.fields[strings.ToLower(key)]
+}
+
+// String masks sensitive values embedded in s.
+func (r *Redactor) String(s string) string {
+	if r.inText != nil {
+		s = r.inText.ReplaceAllString(s, "${1}${2}"+Redacted)
+	}
+	s = pgValue.ReplaceAllString(s, "($1)=("+Redacted+")")
+	return pgInput.ReplaceAllString(s, `${1}"`+Redacted+`"`)
+}
+
+// redactField masks f when its key is sensitive and scrubs the text of
+// strings, errors and encoded objects otherwise.
+func (r *Redactor) redactField(f zapcore.Field) zapcore.Field {
+	if r.Field(f.Key) {
+		return zap.String(f.Key, Redacted)
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		return zap.String(f.Key, r.String(f.String))
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return zap.String(f.Key, r.String(err.Error()))
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ReflectType, zapcore.StringerType, zapcore.ArrayMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return zap.Any(f.Key, r.value(enc.Fields[f.Key]))
+	}
+	return f
+}
+
+// value masks sensitive keys in decoded maps and slices.
+func (r *Redactor) value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			if r.Field(k) {
+				out[k] = Redacted
+			} else {
+				out[k] = r.value(val)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = r.value(val)
+		}
+		return out
+	case string:
+		return r.String(t)
+	}
+	return v
+}
+
+// redactCore applies a Redactor to everything written through it,
+// including messages formatted by the sugared logger.
+type redactCore struct {
+	zapcore.Core
+	r *Redactor
+}
+
+// RedactCore wraps core so that every entry and field passes through r.
+func RedactCore(core zapcore.Core, r *Redactor) zapcore.Core {
+	return &redactCore{Core: core, r: r}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactFields(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	e.Message = c.r.String(e.Message)
+	return c.Core.Write(e, c.redactFields(fields))
+}
+
+func (c *redactCore) redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = c.r.redactField(f)
+	}
+	return out
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey describes an issued key. The key itself is only returned once, on
+// creation, and the stored hash is never serialised.
+type APIKey struct {
+    ID         int64      `json:"id"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes"`
+    CreatedBy  *int64     `json:"created_by,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+    Key        string     `json:"key,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Appointment status values.
+const (
+    AppointmentStatusScheduled = "scheduled"
+    AppointmentStatusCompleted = "completed"
+    AppointmentStatusCancelled = "cancelled"
+    AppointmentStatusNoShow    = "no_show"
+)
+
+// Appointment is a planned visit. AssessmentID is set once the completed
+// visit has been turned into an assessment.
+type Appointment struct {
+    ID           int64     `json:"id"`
+    PatientID    int64     `json:"patient_id"`
+    ClinicianID  int64     `json:"clinician_id"`
+    WoundID      *int64    `json:"wound_id,omitempty"`
+    StartsAt     time.Time `json:"starts_at"`
+    EndsAt       time.Time `json:"ends_at"`
+    Location     string    `json:"location,omitempty"`
+    VisitType    string    `json:"visit_type"`
+    Status       string    `json:"status"`
+    AssessmentID *int64    `json:"assessment_id,omitempty"`
+    CreatedAt    time.Time `json:"created_at"`
+    UpdatedAt    time.Time `json:"updated_at"`
+}
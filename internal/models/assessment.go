@@ -1,13 +1,22 @@
 package models
 
-import "time"
+import (
+    "time"
+
+    "github.com/vellalasantosh/wound_iq_api_new/internal/push"
+)
 
 type Assessment struct {
-    ID          int64     `json:"id"`
-    PatientID   int64     `json:"patient_id"`
-    ClinicianID int64     `json:"clinician_id"`
-    WoundID     *int64    `json:"wound_id,omitempty"`
-    Notes       string    `json:"notes,omitempty"`
-    CreatedAt   time.Time `json:"created_at"`
-    UpdatedAt   time.Time `json:"updated_at"`
+    ID            int64     `json:"id"`
+    PatientID     int64     `json:"patient_id"`
+    ClinicianID   int64     `json:"clinician_id"`
+    WoundID       *int64    `json:"wound_id,omitempty"`
+    Notes         string    `json:"notes,omitempty"`
+    ExudateAmount string    `json:"exudate_amount,omitempty"` // push.Exudate* value
+    TissueType    string    `json:"tissue_type,omitempty"`    // push.Tissue* value
+    CreatedAt     time.Time `json:"created_at"`
+    UpdatedAt     time.Time `json:"updated_at"`
+
+    Measurement *WoundMeasurement `json:"measurement,omitempty"`
+    PushScore   *push.Score       `json:"push_score,omitempty"`
 }
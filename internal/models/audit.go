@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AuditEntry records one access to PHI. Entries are never updated or
+// deleted.
+type AuditEntry struct {
+    ID               int64     `json:"id"`
+    OccurredAt       time.Time `json:"occurred_at"`
+    Actor            string    `json:"actor"`
+    ActorClinicianID *int64    `json:"actor_clinician_id,omitempty"`
+    ActorAPIKeyID    *int64    `json:"actor_api_key_id,omitempty"`
+    Action           string    `json:"action"`
+    ResourceType     string    `json:"resource_type"`
+    ResourceID       string    `json:"resource_id,omitempty"`
+    PatientID        *int64    `json:"patient_id,omitempty"`
+    Route            string    `json:"route"`
+    Status           int       `json:"status"`
+    RequestID        string    `json:"request_id,omitempty"`
+    ClientIP         string    `json:"client_ip,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+    "time"
+
+    "github.com/vellalasantosh/wound_iq_api_new/internal/braden"
+)
+
+// BradenAssessment is a pressure-injury risk assessment. Total and RiskBand
+// are computed by the server from the subscales.
+type BradenAssessment struct {
+    ID          int64 `json:"id"`
+    PatientID   int64 `json:"patient_id"`
+    ClinicianID int64 `json:"clinician_id"`
+    braden.Subscales
+    Total      int       `json:"total"`
+    RiskBand   string    `json:"risk_band"`
+    AssessedAt time.Time `json:"assessed_at"`
+    CreatedAt  time.Time `json:"created_at"`
+}
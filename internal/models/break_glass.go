@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BreakGlassGrant is a clinician's time-limited emergency access to a
+// patient they could not otherwise see.
+type BreakGlassGrant struct {
+    ID          int64      `json:"id"`
+    PatientID   int64      `json:"patient_id"`
+    ClinicianID int64      `json:"clinician_id"`
+    Reason      string     `json:"reason"`
+    GrantedAt   time.Time  `json:"granted_at"`
+    ExpiresAt   time.Time  `json:"expires_at"`
+    Active      bool       `json:"active"`
+    ReviewedBy  *int64     `json:"reviewed_by,omitempty"`
+    ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+    ReviewNote  string     `json:"review_note,omitempty"`
+}
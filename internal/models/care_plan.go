@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Care plan status values.
+const (
+    CarePlanStatusActive       = "active"
+    CarePlanStatusCompleted    = "completed"
+    CarePlanStatusDiscontinued = "discontinued"
+)
+
+// CarePlanGoal is a measurable target such as "50% area reduction in 4 weeks".
+type CarePlanGoal struct {
+    Description string     `json:"description" binding:"required"`
+    TargetDate  *time.Time `json:"target_date,omitempty"`
+    Achieved    bool       `json:"achieved"`
+}
+
+// CarePlan is the current version of a patient's plan. Every edit bumps
+// Version and keeps the previous content in CarePlanVersion.
+type CarePlan struct {
+    ID                     int64          `json:"id"`
+    PatientID              int64          `json:"patient_id"`
+    ResponsibleClinicianID int64          `json:"responsible_clinician_id"`
+    Title                  string         `json:"title"`
+    Goals                  []CarePlanGoal `json:"goals"`
+    Interventions          []string       `json:"interventions"`
+    ReviewDate             time.Time      `json:"review_date"`
+    Status                 string         `json:"status"`
+    WoundIDs               []int64        `json:"wound_ids"`
+    AssessmentIDs          []int64        `json:"assessment_ids"`
+    Version                int            `json:"version"`
+    CreatedAt              time.Time      `json:"created_at"`
+    UpdatedAt              time.Time      `json:"updated_at"`
+}
+
+// CarePlanVersion is a snapshot of a care plan as it was before an edit.
+type CarePlanVersion struct {
+    CarePlanID int64     `json:"care_plan_id"`
+    Version    int       `json:"version"`
+    Snapshot   CarePlan  `json:"snapshot"`
+    CreatedAt  time.Time `json:"created_at"`
+}
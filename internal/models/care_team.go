@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// CareTeamMember is a clinician assigned to a patient's care team.
+type CareTeamMember struct {
+    PatientID   int64     `json:"patient_id"`
+    ClinicianID int64     `json:"clinician_id"`
+    FullName    string    `json:"full_name"`
+    Role        string    `json:"role,omitempty"`
+    AssignedBy  *int64    `json:"assigned_by,omitempty"`
+    AssignedAt  time.Time `json:"assigned_at"`
+}
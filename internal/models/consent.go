@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Consent types.
+const (
+    ConsentPhotography = "photography"
+    ConsentResearch    = "research"
+)
+
+// ConsentTypes lists every consent type.
+var ConsentTypes = []string{ConsentPhotography, ConsentResearch}
+
+// PatientConsent is one signed consent form. Status is "granted",
+// "expired" or "revoked".
+type PatientConsent struct {
+    ID                 int64      `json:"id"`
+    PatientID          int64      `json:"patient_id"`
+    ConsentType        string     `json:"consent_type"`
+    Status             string     `json:"status"`
+    Scope              string     `json:"scope,omitempty"`
+    SignedBy           string     `json:"signed_by"`
+    SignerRelationship string     `json:"signer_relationship"`
+    DocumentRef        string     `json:"document_ref,omitempty"`
+    GrantedAt          time.Time  `json:"granted_at"`
+    ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+    RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+    RevokedReason      string     `json:"revoked_reason,omitempty"`
+    RecordedBy         *int64     `json:"recorded_by,omitempty"`
+    CreatedAt          time.Time  `json:"created_at"`
+}
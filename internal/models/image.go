@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// AssessmentImage describes a stored wound photograph. Storage keys are
+// internal and never serialised.
+type AssessmentImage struct {
+    ID           int64     `json:"id"`
+    AssessmentID int64     `json:"assessment_id"`
+    ContentType  string    `json:"content_type"`
+    SizeBytes    int64     `json:"size_bytes"`
+    Width        int       `json:"width"`
+    Height       int       `json:"height"`
+    StorageKey   string    `json:"-"`
+    ThumbnailKey string    `json:"-"`
+    CreatedAt    time.Time `json:"created_at"`
+}
+
+// ImageReference points at a wound photograph held by another system.
+type ImageReference struct {
+    ID           int64      `json:"id"`
+    AssessmentID int64      `json:"assessment_id"`
+    URI          string     `json:"uri"`
+    Caption      string     `json:"caption,omitempty"`
+    CapturedAt   *time.Time `json:"captured_at,omitempty"`
+    CreatedAt    time.Time  `json:"created_at"`
+}
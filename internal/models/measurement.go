@@ -0,0 +1,47 @@
+package models
+
+import (
+    "math"
+    "time"
+)
+
+// Undermining is a region of tissue destruction under intact skin, recorded
+// as a clock-face span (12 o'clock towards the patient's head).
+type Undermining struct {
+    FromClock int     `json:"from_clock" binding:"min=1,max=12"`
+    ToClock   int     `json:"to_clock" binding:"min=1,max=12"`
+    DepthCm   float64 `json:"depth_cm" binding:"gte=0"`
+}
+
+// Tunnel is a channel extending from the wound bed at a single clock position.
+type Tunnel struct {
+    ClockPosition int     `json:"clock_position" binding:"min=1,max=12"`
+    DepthCm       float64 `json:"depth_cm" binding:"gte=0"`
+}
+
+// WoundMeasurement holds the dimensions recorded with an assessment. AreaCm2
+// and VolumeCm3 are always computed by the server, see ComputeDerived.
+type WoundMeasurement struct {
+    ID           int64         `json:"id"`
+    AssessmentID int64         `json:"assessment_id"`
+    WoundID      *int64        `json:"wound_id,omitempty"`
+    LengthCm     float64       `json:"length_cm"`
+    WidthCm      float64       `json:"width_cm"`
+    DepthCm      float64       `json:"depth_cm"`
+    AreaCm2      float64       `json:"area_cm2"`
+    VolumeCm3    float64       `json:"volume_cm3"`
+    Undermining  []Undermining `json:"undermining"`
+    Tunneling    []Tunnel      `json:"tunneling"`
+    MeasuredAt   time.Time     `json:"measured_at"`
+}
+
+// ComputeDerived sets AreaCm2 (length x width) and VolumeCm3
+// (length x width x depth), rounded to two decimals.
+func (m *WoundMeasurement) ComputeDerived() {
+    m.AreaCm2 = round2(m.LengthCm * m.WidthCm)
+    m.VolumeCm3 = round2(m.LengthCm * m.WidthCm * m.DepthCm)
+}
+
+func round2(v float64) float64 {
+    return math.Round(v*100) / 100
+}
@@ -8,6 +8,8 @@ type Patient struct {
     DateOfBirth         *time.Time `json:"date_of_birth,omitempty"`
     Gender              string     `json:"gender,omitempty"`
     MedicalRecordNumber string     `json:"medical_record_number,omitempty"`
+    Restricted          bool       `json:"restricted"`
+    ResearchEnrolled    bool       `json:"research_enrolled"`
     CreatedAt           time.Time  `json:"created_at"`
     UpdatedAt           time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is one login of a local account. Current marks the session of
+// the access token making the request.
+type Session struct {
+    ID         int64     `json:"id"`
+    UserAgent  string    `json:"user_agent,omitempty"`
+    IPAddress  string    `json:"ip_address,omitempty"`
+    CreatedAt  time.Time `json:"created_at"`
+    LastUsedAt time.Time `json:"last_used_at"`
+    ExpiresAt  time.Time `json:"expires_at"`
+    Current    bool      `json:"current"`
+}
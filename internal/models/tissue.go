@@ -0,0 +1,8 @@
+package models
+
+// AssessmentTissueType is one component of the wound-bed composition
+// recorded on an assessment.
+type AssessmentTissueType struct {
+    TissueType string  `json:"tissue_type"`
+    Percent    float64 `json:"percent"`
+}
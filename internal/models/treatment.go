@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Treatment order status values.
+const (
+    TreatmentStatusActive       = "active"
+    TreatmentStatusDiscontinued = "discontinued"
+)
+
+// Treatment is a dressing/treatment order recorded after an assessment.
+type Treatment struct {
+    ID                int64      `json:"id"`
+    AssessmentID      int64      `json:"assessment_id"`
+    WoundID           int64      `json:"wound_id"`
+    Cleanser          string     `json:"cleanser,omitempty"`
+    PrimaryDressing   string     `json:"primary_dressing,omitempty"`
+    SecondaryDressing string     `json:"secondary_dressing,omitempty"`
+    Offloading        string     `json:"offloading,omitempty"`
+    ChangeFrequency   string     `json:"change_frequency,omitempty"` // e.g. "daily", "every 3 days"
+    Status            string     `json:"status"`
+    StartedAt         time.Time  `json:"started_at"`
+    DiscontinuedAt    *time.Time `json:"discontinued_at,omitempty"`
+    CreatedAt         time.Time  `json:"created_at"`
+    UpdatedAt         time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UserAccount is a local login for a clinician. The password hash and TOTP
+// secret are never serialised.
+type UserAccount struct {
+    ID                int64      `json:"id"`
+    ClinicianID       int64      `json:"clinician_id"`
+    Username          string     `json:"username"`
+    MFAEnabled        bool       `json:"mfa_enabled"`
+    Locked            bool       `json:"locked"`
+    LockedUntil       *time.Time `json:"locked_until,omitempty"`
+    PasswordChangedAt time.Time  `json:"password_changed_at"`
+    LastLoginAt       *time.Time `json:"last_login_at,omitempty"`
+    CreatedAt         time.Time  `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Wound status values accepted by the API.
+const (
+    WoundStatusOpen   = "open"
+    WoundStatusHealed = "healed"
+    WoundStatusClosed = "closed"
+)
+
+type Wound struct {
+    ID                 int64      `json:"id"`
+    PatientID          int64      `json:"patient_id"`
+    AnatomicalLocation string     `json:"anatomical_location"`
+    Etiology           string     `json:"etiology,omitempty"`
+    OnsetDate          *time.Time `json:"onset_date,omitempty"`
+    PresentOnAdmission bool       `json:"present_on_admission"`
+    Status             string     `json:"status"`
+    CreatedAt          time.Time  `json:"created_at"`
+    UpdatedAt          time.Time  `json:"updated_at"`
+}
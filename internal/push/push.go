@@ -0,0 +1,125 @@
+// Package push implements the NPIAP Pressure Ulcer Scale for Healing (PUSH
+// Tool 3.0). A PUSH score is the sum of three sub-scores: surface area
+// (length x width, 0-10), exudate amount (0-3) and tissue type (0-4). Lower
+// totals indicate healing; 0 is a closed wound.
+package push
+
+import (
+	"fmt"
+	"math"
+)
+
+// Exudate amounts, as recorded on assessments.
+const (
+	ExudateNone     = "none"
+	ExudateLight    = "light"
+	ExudateModerate = "moderate"
+	ExudateHeavy    = "heavy"
+)
+
+// Tissue types, as recorded on assessments. The worst tissue type present in
+// the wound bed is the one that is scored.
+const (
+	TissueClosed      = "closed"
+	TissueEpithelial  = "epithelial"
+	TissueGranulation = "granulation"
+	TissueSlough      = "slough"
+	TissueNecrotic    = "necrotic"
+)
+
+var exudateScores = map[string]int{
+	ExudateNone:     0,
+	ExudateLight:    1,
+	ExudateModerate: 2,
+	ExudateHeavy:    3,
+}
+
+var tissueScores = map[string]int{
+	TissueClosed:      0,
+	TissueEpithelial:  1,
+	TissueGranulation: 2,
+	TissueSlough:      3,
+	TissueNecrotic:    4,
+}
+
+// Score is a PUSH total with its sub-scores.
+type Score struct {
+	AreaCm2      float64 `json:"area_cm2"`
+	AreaScore    int     `json:"area_score"`
+	ExudateScore int     `json:"exudate_score"`
+	TissueScore  int     `json:"tissue_score"`
+	Total        int     `json:"total"`
+}
+
+// AreaScore scores a surface area given in cm². The published table lists
+// one-decimal ranges (0.3-0.6, 0.7-1.0, ...); areas falling between two
+// ranges, such as 0.65, are placed in the higher band.
+func AreaScore(areaCm2 float64) int {
+	area := math.Round(areaCm2*100) / 100
+	switch {
+	case area <= 0:
+		return 0
+	case area < 0.3:
+		return 1
+	case area <= 0.6:
+		return 2
+	case area <= 1.0:
+		return 3
+	case area <= 2.0:
+		return 4
+	case area <= 3.0:
+		return 5
+	case area <= 4.0:
+		return 6
+	case area <= 8.0:
+		return 7
+	case area <= 12.0:
+		return 8
+	case area <= 24.0:
+		return 9
+	default:
+		return 10
+	}
+}
+
+// ExudateScore scores an exudate amount.
+func ExudateScore(amount string) (int, error) {
+	s, ok := exudateScores[amount]
+	if !ok {
+		return 0, fmt.Errorf("push: unknown exudate amount %q", amount)
+	}
+	return s, nil
+}
+
+// TissueScore scores a tissue type.
+func TissueScore(tissue string) (int, error) {
+	s, ok := tissueScores[tissue]
+	if !ok {
+		return 0, fmt.Errorf("push: unknown tissue type %q", tissue)
+	}
+	return s, nil
+}
+
+// Compute returns the PUSH score for a wound measuring lengthCm x widthCm.
+func Compute(lengthCm, widthCm float64, exudate, tissue string) (Score, error) {
+	if lengthCm < 0 || widthCm < 0 {
+		return Score{}, fmt.Errorf("push: negative dimensions %.2f x %.2f", lengthCm, widthCm)
+	}
+	exudateScore, err := ExudateScore(exudate)
+	if err != nil {
+		return Score{}, err
+	}
+	tissueScore, err := TissueScore(tissue)
+	if err != nil {
+		return Score{}, err
+	}
+	area := math.Round(lengthCm*widthCm*100) / 100
+	s := Score{
+		AreaCm2:      area,
+		AreaScore:    AreaScore(area),
+		ExudateScore: exudateScore,
+		TissueScore:  tissueScore,
+	}
+	s.Total = s.AreaScore + s.ExudateScore + s.TissueScore
+	return s, nil
+}
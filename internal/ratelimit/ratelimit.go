@@ -0,0 +1,150 @@
+// Package ratelimit throttles clients with token buckets. Each client (API
+// key, user or, failing both, IP address) gets one bucket for ordinary
+// reads, one for writes, one for exports and one per overridden route, so
+// a burst of exports does not lock a client out of everything else.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+// keyPrefix namespaces bucket keys in a shared Redis.
+const keyPrefix = "wound_iq:ratelimit:"
+
+// Limiter picks a limit for each request and enforces it against Store.
+type Limiter struct {
+	Store   Store
+	Log     *zap.Logger
+	Default config.RateLimit
+	Write   config.RateLimit
+	Export  config.RateLimit
+	// IP limits each client IP before authentication.
+	IP config.RateLimit
+	// Routes overrides the limit for "METHOD /v1/route" keys.
+	Routes map[string]config.RateLimit
+	// Exports lists the routes that use the Export limit.
+	Exports map[string]bool
+}
+
+// New builds the limiter configured in cfg. exports are the routes that
+// get the export limit.
+func New(cfg *config.Config, log *zap.Logger, exports []string) (*Limiter, error) {
+	var store Store = NewMemoryStore()
+	if cfg.RateLimitStore == "redis" {
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = NewRedisStore(redis.NewClient(opts), keyPrefix)
+	}
+	l := &Limiter{
+		Store:   store,
+		Log:     log,
+		Default: cfg.RateLimitDefault,
+		Write:   cfg.RateLimitWrite,
+		Export:  cfg.RateLimitExport,
+		IP:      cfg.RateLimitIP,
+		Routes:  cfg.RateLimitRoutes,
+		Exports: map[string]bool{},
+	}
+	for _, r := range exports {
+		l.Exports[r] = true
+	}
+	return l, nil
+}
+
+// limitFor returns the bucket name and limit for a route key.
+func (l *Limiter) limitFor(method, route string) (string, config.RateLimit) {
+	if lim, ok := l.Routes[route]; ok {
+		return route, lim
+	}
+	if l.Exports[route] {
+		return "export", l.Export
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "default", l.Default
+	}
+	return "write", l.Write
+}
+
+// IPMiddleware enforces the IP limit. It runs before authentication, so
+// that requests with invalid keys or tokens, which never reach
+// Middleware, are throttled as well.
+func (l *Limiter) IPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.IP.Requests == 0 {
+			c.Next()
+			return
+		}
+		l.take(c, "ip:"+c.ClientIP()+"|ip", "ip", l.IP)
+	}
+}
+
+// Middleware enforces the limits. It must run after authentication so
+// that API keys and users are told apart from anonymous IPs. When the
+// store fails, requests are let through and the error is logged.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		name, lim := l.limitFor(c.Request.Method, route)
+		if lim.Requests == 0 {
+			c.Next()
+			return
+		}
+		l.take(c, clientKey(c)+"|"+name, name, lim)
+	}
+}
+
+// take takes a token from the bucket under key and answers 429 when there
+// is none.
+func (l *Limiter) take(c *gin.Context, key, name string, lim config.RateLimit) {
+	res, err := l.Store.Take(c.Request.Context(), key, lim, time.Now())
+	if err != nil {
+		l.Log.Error("rate limit store", zap.Error(err))
+		c.Next()
+		return
+	}
+
+	c.Header("RateLimit-Policy", strconv.Itoa(lim.Requests)+";w="+seconds(lim.Per))
+	c.Header("RateLimit-Limit", strconv.Itoa(lim.Requests))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", seconds(res.Reset))
+	if !res.Allowed {
+		c.Header("Retry-After", seconds(res.RetryAfter))
+		l.Log.Warn("rate limited", zap.String("client", clientKey(c)), zap.String("bucket", name),
+			zap.String("route", c.Request.Method+" "+c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return
+	}
+	c.Next()
+}
+
+// clientKey identifies the caller: the API key, else the token subject,
+// else the client IP.
+func clientKey(c *gin.Context) string {
+	if p, ok := auth.PrincipalFrom(c); ok {
+		if p.APIKeyID != 0 {
+			return "key:" + strconv.FormatInt(p.APIKeyID, 10)
+		}
+		if p.Subject != "" {
+			return "user:" + p.Subject
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
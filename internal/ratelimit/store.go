@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+// Result is the state of a bucket after a Take.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store holds token buckets. Take refills the bucket under key for the
+// time elapsed up to now and takes one token if there is one.
+type Store interface {
+	Take(ctx context.Context, key string, l config.RateLimit, now time.Time) (Result, error)
+}
+
+// perSecond is the refill rate of l.
+func perSecond(l config.RateLimit) float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func result(l config.RateLimit, tokens float64, allowed bool) Result {
+	rate := perSecond(l)
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(l.Requests) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return r
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time
+}
+
+// MemoryStore keeps buckets in process memory, so each API instance
+// enforces its own limits.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, l config.RateLimit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Requests), at: now}
+		s.buckets[key] = b
+	}
+	if now.After(b.at) {
+		b.tokens = math.Min(float64(l.Requests), b.tokens+now.Sub(b.at).Seconds()*perSecond(l))
+		b.at = now
+	}
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	r := result(l, b.tokens, allowed)
+	b.full = now.Add(r.Reset)
+	return r, nil
+}
+
+// sweep drops buckets that have refilled completely, which are
+// indistinguishable from new ones, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for k, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+// takeScript refills and takes from a bucket stored as a hash of tokens
+// and the last update in milliseconds. Tokens are returned as a string
+// because Lua numbers are truncated to integers in replies. The caller's
+// clock is used so that the store stays a plain key-value server.
+var takeScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(b[1])
+local at = tonumber(b[2])
+if tokens == nil then
+  tokens = burst
+  at = now
+end
+if now > at then
+  tokens = math.min(burst, tokens + (now - at) * rate)
+  at = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(at))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps buckets in Redis (or anything speaking its protocol
+// with Lua scripting), so limits hold across API instances.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore stores buckets under keys starting with prefix.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, l config.RateLimit, now time.Time) (Result, error) {
+	perMilli := perSecond(l) / 1000
+	reply, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		l.Requests, strconv.FormatFloat(perMilli, 'f', -1, 64), now.UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := reply[0].(int64)
+	str, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(l, tokens, allowed == 1), nil
+}
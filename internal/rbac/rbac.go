@@ -0,0 +1,96 @@
+// Package rbac enforces the route permission matrix. A caller's role comes
+// from their clinician record (clinicians.role), or from the token's roles
+// claim for callers who are not clinicians, such as auditors.
+package rbac
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+)
+
+type Role string
+
+const (
+	Admin           Role = "admin"
+	Physician       Role = "physician"
+	Nurse           Role = "nurse"
+	WoundSpecialist Role = "wound_specialist"
+	Auditor         Role = "auditor"
+
+	// APIKey is the matrix entry for API keys. It is not a role anyone can
+	// hold, so ParseRole never returns it.
+	APIKey Role = "api_key"
+)
+
+// Roles lists every role a caller can hold.
+var Roles = []Role{Admin, Physician, Nurse, WoundSpecialist, Auditor}
+
+// ParseRole normalises a stored or claimed role name ("Wound Specialist",
+// "wound-specialist") and reports whether it is a defined role.
+func ParseRole(s string) (Role, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(" ", "_", "-", "_").Replace(s)
+	for _, r := range Roles {
+		if string(r) == s {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// Permissions maps "METHOD /full/route/path" (as reported by
+// gin.Context.FullPath) to the roles allowed to call it.
+type Permissions map[string][]Role
+
+// Key builds the Permissions key for a route.
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+// Allows reports whether role may call the route. Routes missing from the
+// matrix are denied.
+func (p Permissions) Allows(method, path string, role Role) bool {
+	for _, r := range p[Key(method, path)] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers 403 unless the authenticated caller's role is allowed
+// on the matched route. It must run after auth.Middleware and the clinician
+// resolver that fills Principal.Role.
+func Middleware(perms Permissions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := auth.PrincipalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		// API keys carry scopes, not roles. apikey.RequireScope checks the
+		// scope; the matrix still decides which routes keys may call at all.
+		if p.APIKeyID != 0 {
+			if !perms.Allows(c.Request.Method, c.FullPath(), APIKey) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api keys may not perform this action"})
+				return
+			}
+			c.Next()
+			return
+		}
+		role, ok := ParseRole(p.Role)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no role assigned"})
+			return
+		}
+		if !perms.Allows(c.Request.Method, c.FullPath(), role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role " + string(role) + " may not perform this action"})
+			return
+		}
+		c.Next()
+	}
+}
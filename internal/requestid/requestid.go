@@ -0,0 +1,58 @@
+// Package requestid tags every request with an ID that is echoed in the
+// X-Request-ID response header and recorded in logs and the audit trail.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the request ID in both directions.
+const Header = "X-Request-ID"
+
+const contextKey = "request_id"
+
+// Middleware reuses a well-formed incoming X-Request-ID, such as one set by
+// a load balancer, and generates one otherwise.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !valid(id) {
+			id = generate()
+		}
+		c.Set(contextKey, id)
+		c.Header(Header, id)
+		c.Next()
+	}
+}
+
+// Get returns the request's ID, or "" outside Middleware.
+func Get(c *gin.Context) string {
+	return c.GetString(contextKey)
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// valid accepts up to 64 characters of [A-Za-z0-9._-], keeping
+// client-supplied IDs safe to log.
+func valid(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package router
+
+import "github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
+
+// Role groups used by the permission matrix.
+var (
+	// everyone may read; auditors may only read.
+	everyone = []rbac.Role{rbac.Admin, rbac.Physician, rbac.Nurse, rbac.WoundSpecialist, rbac.Auditor}
+	// clinical roles record and change patient care.
+	clinical = []rbac.Role{rbac.Physician, rbac.Nurse, rbac.WoundSpecialist}
+	// prescribers may order, change and stop treatments.
+	prescribers = []rbac.Role{rbac.Physician, rbac.WoundSpecialist}
+	// staff covers registration and scheduling, which admins also handle.
+	staff     = []rbac.Role{rbac.Admin, rbac.Physician, rbac.Nurse, rbac.WoundSpecialist}
+	adminOnly = []rbac.Role{rbac.Admin}
+)
+
+// withKeys adds API keys to roles. Keys are otherwise refused, whatever
+// their scopes: a scope opens a route group, and the matrix picks the routes
+// in it an integration may call. Administrative routes (care team changes,
+// consent revocation, restricting patients) never get it.
+func withKeys(roles []rbac.Role) []rbac.Role {
+	return append(roles[:len(roles):len(roles)], rbac.APIKey)
+}
+
+// Permissions is the per-route permission matrix. Every route registered
+// under /v1 must have an entry; routes without one are denied.
+var Permissions = rbac.Permissions{
+	// Patients
+	"GET /v1/patients":        withKeys(everyone),
+	"GET /v1/patients/:id":    withKeys(everyone),
+	"POST /v1/patients":       withKeys(staff),
+	"PUT /v1/patients/:id":    withKeys(staff),
+	"DELETE /v1/patients/:id": adminOnly,
+
+	// Care teams. Non-admins can only change teams they are on.
+	"GET /v1/patients/:id/care-team":                  withKeys(everyone),
+	"POST /v1/patients/:id/care-team":                 staff,
+	"DELETE /v1/patients/:id/care-team/:clinician_id": staff,
+
+	// Consent
+	"GET /v1/patients/:id/consents":  withKeys(everyone),
+	"POST /v1/patients/:id/consents": withKeys(staff),
+	"POST /v1/consents/:id/revoke":   staff,
+
+	// Restricted patients and break-the-glass access. API keys reach the
+	// break-glass handler only to be told it needs a clinician account.
+	"PUT /v1/patients/:id/restricted":   adminOnly,
+	"POST /v1/patients/:id/break-glass": withKeys(clinical),
+	"GET /v1/break-glass":               adminOnly,
+	"POST /v1/break-glass/:id/review":   adminOnly,
+
+	// Clinicians
+	"GET /v1/clinicians":        withKeys(everyone),
+	"GET /v1/clinicians/:id":    withKeys(everyone),
+	"POST /v1/clinicians":       adminOnly,
+	"PUT /v1/clinicians/:id":    adminOnly,
+	"DELETE /v1/clinicians/:id": adminOnly,
+
+	// Assessments
+	"GET /v1/assessments":           withKeys(everyone),
+	"GET /v1/assessments/:id":       withKeys(everyone),
+	"POST /v1/assessments":          withKeys(clinical),
+	"POST /v1/assessments/full":     withKeys(clinical),
+	"PUT /v1/assessments/:id":       withKeys(clinical),
+	"DELETE /v1/assessments/:id":    withKeys(clinical),
+	"GET /v1/assessments/:id/full":  withKeys(everyone),
+	"GET /v1/patients/:id/history":  withKeys(everyone),
+	"GET /v1/wounds/:id/trajectory": withKeys(everyone),
+
+	// Wound images
+	"POST /v1/assessments/:id/images": withKeys(clinical),
+	"GET /v1/assessments/:id/images":  withKeys(everyone),
+	"GET /v1/images/:id":              withKeys(everyone),
+	"GET /v1/images/:id/thumbnail":    withKeys(everyone),
+	"DELETE /v1/images/:id":           withKeys(clinical),
+
+	// Treatments
+	"GET /v1/assessments/:id/treatments":    withKeys(everyone),
+	"POST /v1/assessments/:id/treatments":   withKeys(prescribers),
+	"GET /v1/treatments/:id":                withKeys(everyone),
+	"PUT /v1/treatments/:id":                withKeys(prescribers),
+	"DELETE /v1/treatments/:id":             withKeys(prescribers),
+	"GET /v1/wounds/:id/treatments/current": withKeys(everyone),
+
+	// Care plans
+	"GET /v1/patients/:id/care-plans":  withKeys(everyone),
+	"POST /v1/patients/:id/care-plans": withKeys(clinical),
+	"GET /v1/care-plans/overdue":       withKeys(everyone),
+	"GET /v1/care-plans/:id":           withKeys(everyone),
+	"PUT /v1/care-plans/:id":           withKeys(clinical),
+	"DELETE /v1/care-plans/:id":        withKeys(clinical),
+	"GET /v1/care-plans/:id/versions":  withKeys(everyone),
+
+	// Appointments
+	"GET /v1/appointments":                 withKeys(everyone),
+	"GET /v1/appointments/:id":             withKeys(everyone),
+	"POST /v1/appointments":                withKeys(staff),
+	"PUT /v1/appointments/:id":             withKeys(staff),
+	"DELETE /v1/appointments/:id":          withKeys(staff),
+	"POST /v1/appointments/:id/assessment": withKeys(clinical),
+	"GET /v1/clinicians/:id/schedule":      withKeys(everyone),
+
+	// Wounds
+	"GET /v1/wounds":                  withKeys(everyone),
+	"GET /v1/wounds/:id":              withKeys(everyone),
+	"POST /v1/wounds":                 withKeys(clinical),
+	"PUT /v1/wounds/:id":              withKeys(clinical),
+	"DELETE /v1/wounds/:id":           withKeys(clinical),
+	"GET /v1/patients/:id/wounds":     withKeys(everyone),
+	"GET /v1/wounds/:id/measurements": withKeys(everyone),
+	"GET /v1/wounds/:id/push-scores":  withKeys(everyone),
+
+	// Braden risk assessments
+	"GET /v1/patients/:id/braden":  withKeys(everyone),
+	"POST /v1/patients/:id/braden": withKeys(clinical),
+	"GET /v1/braden/:id":           withKeys(everyone),
+	"DELETE /v1/braden/:id":        withKeys(clinical),
+
+	// Audit trail
+	"GET /v1/audit-log": adminOnly,
+
+	// API keys
+	"GET /v1/api-keys":        adminOnly,
+	"POST /v1/api-keys":       adminOnly,
+	"DELETE /v1/api-keys/:id": adminOnly,
+
+	// Local user accounts. Every account holder manages their own
+	// password and MFA.
+	"GET /v1/users":                     adminOnly,
+	"POST /v1/users":                    adminOnly,
+	"DELETE /v1/users/:id":              adminOnly,
+	"POST /v1/users/:id/unlock":         adminOnly,
+	"DELETE /v1/users/:id/mfa":          adminOnly,
+	"POST /v1/users/:id/password-reset": adminOnly,
+	"POST /v1/auth/password":            everyone,
+	"POST /v1/auth/mfa/enroll":          everyone,
+	"POST /v1/auth/mfa/verify":          everyone,
+
+	// Sessions of local accounts
+	"GET /v1/auth/sessions":         everyone,
+	"DELETE /v1/auth/sessions/:id":  everyone,
+	"POST /v1/auth/logout":          everyone,
+	"POST /v1/auth/logout-all":      everyone,
+	"GET /v1/users/:id/sessions":    adminOnly,
+	"DELETE /v1/users/:id/sessions": adminOnly,
+}
@@ -0,0 +1,10 @@
+package router
+
+// ExportRoutes use the stricter RATE_LIMIT_EXPORT bucket: each assembles a
+// whole record or history, and they are what bulk integrations pull.
+var ExportRoutes = []string{
+	"GET /v1/patients/:id/history",
+	"GET /v1/assessments/:id/full",
+	"GET /v1/wounds/:id/trajectory",
+	"GET /v1/audit-log",
+}
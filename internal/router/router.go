@@ -2,62 +2,228 @@ package router
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/vellalasantosh/wound_iq_api_new/internal/apikey"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/cors"
 	"github.com/vellalasantosh/wound_iq_api_new/internal/handlers"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/logger"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/ratelimit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/requestid"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
 )
 
-func New(db *sql.DB, log *zap.Logger, cfg *config.Config) *gin.Engine {
+// New builds the HTTP engine. authn may be nil only when cfg.AuthDisabled is
+// set, in which case /v1 is served without authentication or role checks.
+// trail records the PHI routes; when nil, entries are written before each
+// request finishes.
+func New(db *sql.DB, log *zap.Logger, cfg *config.Config, store storage.Store, authn *auth.Verifier, trail *audit.Recorder) (*gin.Engine, error) {
 	r := gin.New()
-	r.Use(gin.Recovery())
-	r.Use(gin.LoggerWithWriter(gin.DefaultWriter))
-	r.Use(corsMiddleware())
+	// Without trusted proxies X-Forwarded-For is ignored: anyone could set
+	// it to dodge per-IP limits or forge the audit trail's client IP.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	// Access logs and panics go through zap, whose core redacts PHI; gin's
+	// own logger and recovery would print raw paths and requests to stdout.
+	r.Use(requestid.Middleware())
+	r.Use(logger.AccessLog(log, logger.NewRedactor(logger.RedactFields(cfg))))
+	r.Use(logger.Recovery(log))
+	r.Use(cors.Middleware(cors.FromConfig(cfg), log))
 
-	h := handlers.NewHandlers(db, log, cfg)
+	h, err := handlers.NewHandlers(db, log, cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	if trail == nil {
+		trail = audit.NewRecorder(db, log, 0)
+	}
+	limiter, err := ratelimit.New(cfg, log, ExportRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("rate limiting: %w", err)
+	}
 
+	// Clients are throttled by IP before authentication, so that guessing
+	// keys and tokens is too, and again once identified, before any
+	// database work.
 	v1 := r.Group("/v1")
-	{
-		// Patients
-		v1.GET("/patients", h.ListPatients)
-		v1.GET("/patients/:id", h.GetPatient)
-		v1.POST("/patients", h.CreatePatient)
-		v1.PUT("/patients/:id", h.UpdatePatient)
-		v1.DELETE("/patients/:id", h.DeletePatient)
-
-		// Clinicians
-		v1.GET("/clinicians", h.ListClinicians)
-		v1.GET("/clinicians/:id", h.GetClinician)
-		v1.POST("/clinicians", h.CreateClinician)
-		v1.PUT("/clinicians/:id", h.UpdateClinician)
-		v1.DELETE("/clinicians/:id", h.DeleteClinician)
-
-		// Assessments
-		v1.GET("/assessments", h.ListAssessments)
-		v1.GET("/assessments/:id", h.GetAssessment)
-		v1.POST("/assessments", h.CreateAssessment)
-		v1.PUT("/assessments/:id", h.UpdateAssessment)
-		v1.DELETE("/assessments/:id", h.DeleteAssessment)
-
-		// Reports
-		v1.GET("/patients/:id/history", h.GetPatientHistory)
-		v1.GET("/assessments/:id/full", h.GetAssessmentFull)
-	}
-
-	return r
-}
+	if authn != nil {
+		v1.Use(limiter.IPMiddleware(), h.AuthenticateAPIKey, auth.Middleware(authn, log), h.CheckSession, limiter.Middleware(),
+			h.ResolveClinician, rbac.Middleware(Permissions))
+	} else {
+		v1.Use(limiter.Middleware())
+	}
 
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	// Local account logins are the only unauthenticated /v1 routes. They
+	// exist only with LOCAL_AUTH=true and are rate limited by client IP.
+	if cfg.LocalAuth {
+		login := r.Group("/v1/auth", limiter.Middleware())
+		{
+			login.POST("/login", h.Login)
+			login.POST("/refresh", h.RefreshToken)
+			login.POST("/password-reset", h.ResetPassword)
 		}
-		c.Next()
 	}
+
+	// Routes are grouped by the API key scope resource that guards them.
+	// Groups share the /v1 prefix, so FullPath and the permission matrix are
+	// unaffected. Groups serving PHI also write the audit trail.
+	patients := v1.Group("", apikey.RequireScope("patients"), trail.Middleware())
+	{
+		patients.GET("/patients", h.ListPatients)
+		patients.GET("/patients/:id", h.GetPatient)
+		patients.POST("/patients", h.CreatePatient)
+		patients.PUT("/patients/:id", h.UpdatePatient)
+		patients.DELETE("/patients/:id", h.DeletePatient)
+		patients.GET("/patients/:id/care-team", h.ListPatientCareTeam)
+		patients.POST("/patients/:id/care-team", h.AddPatientCareTeamMember)
+		patients.DELETE("/patients/:id/care-team/:clinician_id", h.RemovePatientCareTeamMember)
+		patients.POST("/patients/:id/break-glass", h.BreakGlass)
+		patients.GET("/patients/:id/consents", h.ListPatientConsents)
+		patients.POST("/patients/:id/consents", h.CreatePatientConsent)
+		patients.POST("/consents/:id/revoke", h.RevokePatientConsent)
+	}
+
+	clinicians := v1.Group("", apikey.RequireScope("clinicians"), trail.Middleware())
+	{
+		clinicians.GET("/clinicians", h.ListClinicians)
+		clinicians.GET("/clinicians/:id", h.GetClinician)
+		clinicians.POST("/clinicians", h.CreateClinician)
+		clinicians.PUT("/clinicians/:id", h.UpdateClinician)
+		clinicians.DELETE("/clinicians/:id", h.DeleteClinician)
+	}
+
+	assessments := v1.Group("", apikey.RequireScope("assessments"), trail.Middleware())
+	{
+		assessments.GET("/assessments", h.ListAssessments)
+		assessments.GET("/assessments/:id", h.GetAssessment)
+		assessments.POST("/assessments", h.CreateAssessment)
+		assessments.POST("/assessments/full", h.CreateFullAssessment)
+		assessments.PUT("/assessments/:id", h.UpdateAssessment)
+		assessments.DELETE("/assessments/:id", h.DeleteAssessment)
+	}
+
+	// Wound images
+	images := v1.Group("", apikey.RequireScope("images"), trail.Middleware())
+	{
+		images.POST("/assessments/:id/images", h.UploadAssessmentImage)
+		images.GET("/assessments/:id/images", h.ListAssessmentImages)
+		images.GET("/images/:id", h.GetImageContent)
+		images.GET("/images/:id/thumbnail", h.GetImageThumbnail)
+		images.DELETE("/images/:id", h.DeleteImage)
+	}
+
+	treatments := v1.Group("", apikey.RequireScope("treatments"), trail.Middleware())
+	{
+		treatments.GET("/assessments/:id/treatments", h.ListAssessmentTreatments)
+		treatments.POST("/assessments/:id/treatments", h.CreateAssessmentTreatment)
+		treatments.GET("/treatments/:id", h.GetTreatment)
+		treatments.PUT("/treatments/:id", h.UpdateTreatment)
+		treatments.DELETE("/treatments/:id", h.DeleteTreatment)
+		treatments.GET("/wounds/:id/treatments/current", h.GetWoundCurrentTreatment)
+	}
+
+	carePlans := v1.Group("", apikey.RequireScope("care_plans"), trail.Middleware())
+	{
+		carePlans.GET("/patients/:id/care-plans", h.ListPatientCarePlans)
+		carePlans.POST("/patients/:id/care-plans", h.CreatePatientCarePlan)
+		carePlans.GET("/care-plans/overdue", h.ListOverdueCarePlans)
+		carePlans.GET("/care-plans/:id", h.GetCarePlan)
+		carePlans.PUT("/care-plans/:id", h.UpdateCarePlan)
+		carePlans.DELETE("/care-plans/:id", h.DeleteCarePlan)
+		carePlans.GET("/care-plans/:id/versions", h.ListCarePlanVersions)
+	}
+
+	appointments := v1.Group("", apikey.RequireScope("appointments"), trail.Middleware())
+	{
+		appointments.GET("/appointments", h.ListAppointments)
+		appointments.GET("/appointments/:id", h.GetAppointment)
+		appointments.POST("/appointments", h.CreateAppointment)
+		appointments.PUT("/appointments/:id", h.UpdateAppointment)
+		appointments.DELETE("/appointments/:id", h.DeleteAppointment)
+		appointments.POST("/appointments/:id/assessment", h.CreateAppointmentAssessment)
+		appointments.GET("/clinicians/:id/schedule", h.GetClinicianSchedule)
+	}
+
+	wounds := v1.Group("", apikey.RequireScope("wounds"), trail.Middleware())
+	{
+		wounds.GET("/wounds", h.ListWounds)
+		wounds.GET("/wounds/:id", h.GetWound)
+		wounds.POST("/wounds", h.CreateWound)
+		wounds.PUT("/wounds/:id", h.UpdateWound)
+		wounds.DELETE("/wounds/:id", h.DeleteWound)
+		wounds.GET("/patients/:id/wounds", h.ListPatientWounds)
+		wounds.GET("/wounds/:id/measurements", h.ListWoundMeasurements)
+		wounds.GET("/wounds/:id/push-scores", h.ListWoundPushScores)
+	}
+
+	// Braden risk assessments
+	braden := v1.Group("", apikey.RequireScope("braden"), trail.Middleware())
+	{
+		braden.GET("/patients/:id/braden", h.ListPatientBraden)
+		braden.POST("/patients/:id/braden", h.CreatePatientBraden)
+		braden.GET("/braden/:id", h.GetBraden)
+		braden.DELETE("/braden/:id", h.DeleteBraden)
+	}
+
+	reports := v1.Group("", apikey.RequireScope("reports"), trail.Middleware())
+	{
+		reports.GET("/patients/:id/history", h.GetPatientHistory)
+		reports.GET("/assessments/:id/full", h.GetAssessmentFull)
+		reports.GET("/wounds/:id/trajectory", h.GetWoundTrajectory)
+	}
+
+	// The audit trail is for administrators only; "audit" is not an
+	// issuable scope.
+	auditLog := v1.Group("", apikey.RequireScope("audit"))
+	{
+		auditLog.GET("/audit-log", h.SearchAuditLog)
+	}
+
+	// Restricting patients and reviewing break-the-glass grants are likewise
+	// admin-only. An API key that could lift the flag could read the record.
+	breakGlass := v1.Group("", apikey.RequireScope("audit"), trail.Middleware())
+	{
+		breakGlass.PUT("/patients/:id/restricted", h.SetPatientRestricted)
+		breakGlass.GET("/break-glass", h.ListBreakGlassGrants)
+		breakGlass.POST("/break-glass/:id/review", h.ReviewBreakGlassGrant)
+	}
+
+	// API keys cannot manage API keys: "api_keys" is not an issuable scope.
+	apiKeys := v1.Group("", apikey.RequireScope("api_keys"))
+	{
+		apiKeys.GET("/api-keys", h.ListAPIKeys)
+		apiKeys.POST("/api-keys", h.CreateAPIKey)
+		apiKeys.DELETE("/api-keys/:id", h.RevokeAPIKey)
+	}
+
+	// Local user accounts. "users" is not an issuable scope: API keys
+	// cannot manage accounts or credentials.
+	users := v1.Group("", apikey.RequireScope("users"))
+	{
+		users.GET("/users", h.ListUserAccounts)
+		users.POST("/users", h.CreateUserAccount)
+		users.DELETE("/users/:id", h.DeleteUserAccount)
+		users.POST("/users/:id/unlock", h.UnlockUserAccount)
+		users.DELETE("/users/:id/mfa", h.ResetUserMFA)
+		users.POST("/users/:id/password-reset", h.CreatePasswordReset)
+		users.POST("/auth/password", h.ChangePassword)
+		users.POST("/auth/mfa/enroll", h.EnrollMFA)
+		users.POST("/auth/mfa/verify", h.VerifyMFA)
+		users.GET("/auth/sessions", h.ListSessions)
+		users.DELETE("/auth/sessions/:id", h.RevokeSession)
+		users.POST("/auth/logout", h.Logout)
+		users.POST("/auth/logout-all", h.LogoutEverywhere)
+		users.GET("/users/:id/sessions", h.ListUserSessions)
+		users.DELETE("/users/:id/sessions", h.RevokeUserSessions)
+	}
+
+	return r, nil
 }
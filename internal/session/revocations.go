@@ -0,0 +1,107 @@
+// Package session keeps the set of recently revoked login sessions in
+// memory, so access tokens can be checked against it on every request
+// without a database round trip.
+package session
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Revocations is a cache of revoked session IDs. It is reloaded from the
+// sessions table at most once per Refresh, so a revocation made by another
+// instance takes effect within Refresh; revocations made through Add take
+// effect immediately on this instance.
+type Revocations struct {
+	DB  *sql.DB
+	Log *zap.Logger
+	// Window is how long a revoked session's access tokens can still be
+	// presented: the access token lifetime plus clock leeway. Older
+	// revocations are dropped from the cache.
+	Window  time.Duration
+	Refresh time.Duration
+
+	mu       sync.Mutex
+	revoked  map[int64]time.Time
+	loadedAt time.Time // last load attempt, successful or not
+	loading  bool
+}
+
+// NewRevocations returns an empty cache that loads on first use.
+func NewRevocations(db *sql.DB, log *zap.Logger, window, refresh time.Duration) *Revocations {
+	return &Revocations{DB: db, Log: log, Window: window, Refresh: refresh, revoked: map[int64]time.Time{}}
+}
+
+// Revoked reports whether session id has been revoked. One caller reloads
+// the cache when it is due, outside the lock; everyone else, that caller
+// included if the reload fails, is answered from the last loaded list. A
+// failed reload is not retried until Refresh has passed again, so a
+// database outage costs one query per Refresh rather than one per request.
+func (r *Revocations) Revoked(ctx context.Context, id int64) bool {
+	r.mu.Lock()
+	now := time.Now()
+	if !r.loading && (r.loadedAt.IsZero() || now.Sub(r.loadedAt) >= r.Refresh) {
+		r.loading = true
+		r.loadedAt = now
+		r.mu.Unlock()
+
+		// The reload serves every later caller, so it outlives this one.
+		loaded, err := r.load(context.WithoutCancel(ctx))
+
+		r.mu.Lock()
+		r.loading = false
+		if err != nil {
+			r.Log.Sugar().Errorf("load session revocations: %v", err)
+		} else {
+			r.merge(loaded)
+		}
+	}
+	revokedAt, ok := r.revoked[id]
+	r.mu.Unlock()
+	return ok && now.Sub(revokedAt) <= r.Window
+}
+
+// Add records sessions this instance has just revoked.
+func (r *Revocations) Add(ids ...int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		r.revoked[id] = now
+	}
+}
+
+// merge replaces the cache with loaded. Entries added locally are kept
+// until they leave the window, even if the reload raced their commit.
+// r.mu must be held.
+func (r *Revocations) merge(loaded map[int64]time.Time) {
+	for id, at := range r.revoked {
+		if _, ok := loaded[id]; !ok && time.Since(at) <= r.Window {
+			loaded[id] = at
+		}
+	}
+	r.revoked = loaded
+}
+
+func (r *Revocations) load(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT id, revoked_at FROM sessions
+                                         WHERE revoked_at > now() - $1 * interval '1 second'`, int64(r.Window/time.Second)+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revoked := map[int64]time.Time{}
+	for rows.Next() {
+		var id int64
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		revoked[id] = at
+	}
+	return revoked, rows.Err()
+}
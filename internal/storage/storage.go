@@ -0,0 +1,58 @@
+// Package storage persists binary objects such as wound photographs. Object
+// keys are generated by the API and never derived from client input.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// Store is implemented by every storage driver.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns ErrNotFound when the key does not exist. Callers must
+	// close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New builds the driver selected by cfg.StorageDriver.
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return NewLocal(cfg.StorageLocalDir)
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+		})
+	default:
+		return nil, fmt.Errorf("storage: unknown driver %q", cfg.StorageDriver)
+	}
+}
+
+// validateKey rejects keys that could escape a bucket or directory.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
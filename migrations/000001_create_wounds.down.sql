@@ -0,0 +1,4 @@
+-- wounds predates this migration in some databases, so only the objects
+-- added here are removed.
+ALTER TABLE wounds DROP CONSTRAINT IF EXISTS wounds_status_check;
+DROP INDEX IF EXISTS wounds_patient_id_idx;
@@ -0,0 +1,19 @@
+-- Wounds referenced by assessments.wound_id. The table may already exist in
+-- older wound_iq databases, so every column is added idempotently.
+CREATE TABLE IF NOT EXISTS wounds (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE wounds ADD COLUMN IF NOT EXISTS anatomical_location TEXT NOT NULL DEFAULT '';
+ALTER TABLE wounds ADD COLUMN IF NOT EXISTS etiology TEXT NOT NULL DEFAULT '';
+ALTER TABLE wounds ADD COLUMN IF NOT EXISTS onset_date DATE;
+ALTER TABLE wounds ADD COLUMN IF NOT EXISTS present_on_admission BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE wounds ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open';
+
+ALTER TABLE wounds DROP CONSTRAINT IF EXISTS wounds_status_check;
+ALTER TABLE wounds ADD CONSTRAINT wounds_status_check CHECK (status IN ('open', 'healed', 'closed'));
+
+CREATE INDEX IF NOT EXISTS wounds_patient_id_idx ON wounds (patient_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS assessments_wound_id_created_at_idx;
+DROP TABLE IF EXISTS wound_measurements;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS wound_measurements (
+    id BIGSERIAL PRIMARY KEY,
+    assessment_id BIGINT NOT NULL UNIQUE REFERENCES assessments(id) ON DELETE CASCADE,
+    length_cm NUMERIC(7,2) NOT NULL CHECK (length_cm >= 0),
+    width_cm NUMERIC(7,2) NOT NULL CHECK (width_cm >= 0),
+    depth_cm NUMERIC(7,2) NOT NULL DEFAULT 0 CHECK (depth_cm >= 0),
+    area_cm2 NUMERIC(10,2) NOT NULL,
+    volume_cm3 NUMERIC(12,2) NOT NULL,
+    undermining JSONB NOT NULL DEFAULT '[]',
+    tunneling JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS assessments_wound_id_created_at_idx ON assessments (wound_id, created_at);
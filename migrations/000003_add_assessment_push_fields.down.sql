@@ -0,0 +1,2 @@
+ALTER TABLE assessments DROP COLUMN IF EXISTS tissue_type;
+ALTER TABLE assessments DROP COLUMN IF EXISTS exudate_amount;
@@ -0,0 +1,4 @@
+ALTER TABLE assessments ADD COLUMN IF NOT EXISTS exudate_amount TEXT
+    CHECK (exudate_amount IN ('none', 'light', 'moderate', 'heavy'));
+ALTER TABLE assessments ADD COLUMN IF NOT EXISTS tissue_type TEXT
+    CHECK (tissue_type IN ('closed', 'epithelial', 'granulation', 'slough', 'necrotic'));
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS braden_assessments;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS braden_assessments (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    clinician_id BIGINT NOT NULL REFERENCES clinicians(id),
+    sensory_perception SMALLINT NOT NULL CHECK (sensory_perception BETWEEN 1 AND 4),
+    moisture SMALLINT NOT NULL CHECK (moisture BETWEEN 1 AND 4),
+    activity SMALLINT NOT NULL CHECK (activity BETWEEN 1 AND 4),
+    mobility SMALLINT NOT NULL CHECK (mobility BETWEEN 1 AND 4),
+    nutrition SMALLINT NOT NULL CHECK (nutrition BETWEEN 1 AND 4),
+    friction_shear SMALLINT NOT NULL CHECK (friction_shear BETWEEN 1 AND 3),
+    total SMALLINT NOT NULL,
+    risk_band TEXT NOT NULL CHECK (risk_band IN ('no_risk', 'mild', 'moderate', 'high', 'very_high')),
+    assessed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS braden_assessments_patient_id_idx ON braden_assessments (patient_id, assessed_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS assessment_images (
+    id BIGSERIAL PRIMARY KEY,
+    assessment_id BIGINT NOT NULL REFERENCES assessments(id) ON DELETE CASCADE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS assessment_images_assessment_id_idx ON assessment_images (assessment_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS treatments (
+    id BIGSERIAL PRIMARY KEY,
+    assessment_id BIGINT NOT NULL REFERENCES assessments(id) ON DELETE CASCADE,
+    wound_id BIGINT NOT NULL REFERENCES wounds(id) ON DELETE CASCADE,
+    cleanser TEXT NOT NULL DEFAULT '',
+    primary_dressing TEXT NOT NULL DEFAULT '',
+    secondary_dressing TEXT NOT NULL DEFAULT '',
+    offloading TEXT NOT NULL DEFAULT '',
+    change_frequency TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'discontinued')),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    discontinued_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS treatments_assessment_id_idx ON treatments (assessment_id);
+CREATE INDEX IF NOT EXISTS treatments_wound_id_active_idx ON treatments (wound_id, started_at DESC) WHERE status = 'active';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS care_plan_versions;
+DROP TABLE IF EXISTS care_plan_assessments;
+DROP TABLE IF EXISTS care_plan_wounds;
+DROP TABLE IF EXISTS care_plans;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS care_plans (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    responsible_clinician_id BIGINT NOT NULL REFERENCES clinicians(id),
+    title TEXT NOT NULL,
+    goals JSONB NOT NULL DEFAULT '[]',
+    interventions JSONB NOT NULL DEFAULT '[]',
+    review_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'discontinued')),
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS care_plans_patient_id_idx ON care_plans (patient_id);
+CREATE INDEX IF NOT EXISTS care_plans_review_date_active_idx ON care_plans (review_date) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS care_plan_wounds (
+    care_plan_id BIGINT NOT NULL REFERENCES care_plans(id) ON DELETE CASCADE,
+    wound_id BIGINT NOT NULL REFERENCES wounds(id) ON DELETE CASCADE,
+    PRIMARY KEY (care_plan_id, wound_id)
+);
+
+CREATE TABLE IF NOT EXISTS care_plan_assessments (
+    care_plan_id BIGINT NOT NULL REFERENCES care_plans(id) ON DELETE CASCADE,
+    assessment_id BIGINT NOT NULL REFERENCES assessments(id) ON DELETE CASCADE,
+    PRIMARY KEY (care_plan_id, assessment_id)
+);
+
+-- Snapshot of each superseded version, written before the plan is edited.
+CREATE TABLE IF NOT EXISTS care_plan_versions (
+    care_plan_id BIGINT NOT NULL REFERENCES care_plans(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (care_plan_id, version)
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS appointments (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    clinician_id BIGINT NOT NULL REFERENCES clinicians(id) ON DELETE CASCADE,
+    wound_id BIGINT REFERENCES wounds(id) ON DELETE SET NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    location TEXT NOT NULL DEFAULT '',
+    visit_type TEXT NOT NULL CHECK (visit_type IN ('initial', 'follow_up', 'dressing_change', 'telehealth')),
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'completed', 'cancelled', 'no_show')),
+    assessment_id BIGINT UNIQUE REFERENCES assessments(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS appointments_clinician_starts_at_idx ON appointments (clinician_id, starts_at);
+CREATE INDEX IF NOT EXISTS appointments_patient_id_idx ON appointments (patient_id);
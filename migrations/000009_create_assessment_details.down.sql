@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS assessment_image_references;
+DROP TABLE IF EXISTS assessment_tissue_types;
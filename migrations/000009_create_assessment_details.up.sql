@@ -0,0 +1,19 @@
+-- Wound-bed composition recorded alongside an assessment, e.g. 60% granulation / 40% slough.
+CREATE TABLE IF NOT EXISTS assessment_tissue_types (
+    assessment_id BIGINT NOT NULL REFERENCES assessments(id) ON DELETE CASCADE,
+    tissue_type TEXT NOT NULL CHECK (tissue_type IN ('closed', 'epithelial', 'granulation', 'slough', 'necrotic')),
+    percent NUMERIC(5,2) NOT NULL CHECK (percent > 0 AND percent <= 100),
+    PRIMARY KEY (assessment_id, tissue_type)
+);
+
+-- Images held outside this service (PACS, EHR media store) that document an assessment.
+CREATE TABLE IF NOT EXISTS assessment_image_references (
+    id BIGSERIAL PRIMARY KEY,
+    assessment_id BIGINT NOT NULL REFERENCES assessments(id) ON DELETE CASCADE,
+    uri TEXT NOT NULL,
+    caption TEXT NOT NULL DEFAULT '',
+    captured_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS assessment_image_references_assessment_id_idx ON assessment_image_references (assessment_id);
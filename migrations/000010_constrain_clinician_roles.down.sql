@@ -0,0 +1 @@
+ALTER TABLE clinicians DROP CONSTRAINT IF EXISTS clinicians_role_check;
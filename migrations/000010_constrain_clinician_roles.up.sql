@@ -0,0 +1,10 @@
+-- Normalise recognisable legacy spellings ("Wound Specialist", "NURSE") to the defined role set.
+UPDATE clinicians
+   SET role = lower(replace(replace(trim(role), ' ', '_'), '-', '_'))
+ WHERE lower(replace(replace(trim(role), ' ', '_'), '-', '_')) IN ('admin', 'physician', 'nurse', 'wound_specialist', 'auditor');
+
+-- NOT VALID: enforce on new writes without rejecting unrecognised legacy rows,
+-- which simply get no permissions until corrected.
+ALTER TABLE clinicians DROP CONSTRAINT IF EXISTS clinicians_role_check;
+ALTER TABLE clinicians ADD CONSTRAINT clinicians_role_check
+    CHECK (role IS NULL OR role IN ('', 'admin', 'physician', 'nurse', 'wound_specialist', 'auditor')) NOT VALID;
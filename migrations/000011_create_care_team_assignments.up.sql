@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS care_team_assignments (
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    clinician_id BIGINT NOT NULL REFERENCES clinicians(id) ON DELETE CASCADE,
+    assigned_by BIGINT REFERENCES clinicians(id) ON DELETE SET NULL,
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (patient_id, clinician_id)
+);
+
+-- Scoped queries probe (patient_id, clinician_id) via the primary key; this
+-- serves "which patients is this clinician on".
+CREATE INDEX IF NOT EXISTS care_team_assignments_clinician_id_idx ON care_team_assignments (clinician_id);
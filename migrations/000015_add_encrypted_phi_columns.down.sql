@@ -0,0 +1,6 @@
+-- Run cmd/reencrypt -decrypt first, or encrypted values are lost.
+DROP INDEX IF EXISTS idx_patients_mrn_index;
+ALTER TABLE assessments DROP COLUMN IF EXISTS notes_encrypted;
+ALTER TABLE patients DROP COLUMN IF EXISTS dob_encrypted;
+ALTER TABLE patients DROP COLUMN IF EXISTS mrn_index;
+ALTER TABLE patients DROP COLUMN IF EXISTS mrn_encrypted;
@@ -0,0 +1,9 @@
+-- Encrypted twins of the PHI columns (see internal/fieldcrypt). Once a row
+-- is encrypted its plaintext column is NULL; cmd/reencrypt migrates
+-- existing rows and rotates keys.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS mrn_encrypted TEXT;
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS mrn_index TEXT;
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS dob_encrypted TEXT;
+ALTER TABLE assessments ADD COLUMN IF NOT EXISTS notes_encrypted TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_patients_mrn_index ON patients(mrn_index);
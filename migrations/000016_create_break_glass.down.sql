@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS break_glass_grants;
+ALTER TABLE patients DROP COLUMN IF EXISTS restricted;
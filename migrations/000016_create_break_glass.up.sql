@@ -0,0 +1,20 @@
+-- Restricted patients (VIPs, staff-as-patients) are hidden from everyone
+-- but admins unless the caller holds an unexpired break-the-glass grant.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS restricted BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS break_glass_grants (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    clinician_id BIGINT NOT NULL REFERENCES clinicians(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    reviewed_by BIGINT REFERENCES clinicians(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    review_note TEXT
+);
+
+-- Access checks probe (patient_id, clinician_id) for an unexpired grant;
+-- the review queue lists unreviewed grants.
+CREATE INDEX IF NOT EXISTS break_glass_grants_patient_clinician_idx ON break_glass_grants (patient_id, clinician_id, expires_at);
+CREATE INDEX IF NOT EXISTS break_glass_grants_unreviewed_idx ON break_glass_grants (granted_at) WHERE reviewed_at IS NULL;
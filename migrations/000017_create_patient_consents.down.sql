@@ -0,0 +1,2 @@
+ALTER TABLE patients DROP COLUMN IF EXISTS research_enrolled;
+DROP TABLE IF EXISTS patient_consents;
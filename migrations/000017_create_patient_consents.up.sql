@@ -0,0 +1,23 @@
+-- Consent records, one row per signed form. A patient's consent of a type
+-- is the most recent row of that type; revoking sets revoked_at rather
+-- than deleting, so the history stays complete.
+CREATE TABLE IF NOT EXISTS patient_consents (
+    id BIGSERIAL PRIMARY KEY,
+    patient_id BIGINT NOT NULL REFERENCES patients(id) ON DELETE CASCADE,
+    consent_type TEXT NOT NULL CHECK (consent_type IN ('photography', 'research')),
+    scope TEXT,
+    signed_by TEXT NOT NULL,
+    signer_relationship TEXT NOT NULL DEFAULT 'self',
+    document_ref TEXT,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    revoked_reason TEXT,
+    recorded_by BIGINT REFERENCES clinicians(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS patient_consents_patient_type_idx ON patient_consents (patient_id, consent_type, granted_at DESC);
+
+-- Research enrolment requires research consent and ends when it is revoked.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS research_enrolled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS refresh_tokens_session_idx;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS used_at;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS sessions;
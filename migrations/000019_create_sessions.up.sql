@@ -0,0 +1,27 @@
+-- Server-side sessions for local accounts: one per login. The refresh
+-- token is rotated on every use; presenting a rotated token again revokes
+-- the session. Sessions outlive their account so that revoking a deleted
+-- user's sessions still reaches every instance's revocation cache.
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES user_accounts(id) ON DELETE SET NULL,
+    user_agent TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_reason TEXT
+);
+
+-- Session lists show a user's live sessions; the revocation cache loads
+-- recent revocations.
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS sessions_revoked_idx ON sessions (revoked_at) WHERE revoked_at IS NOT NULL;
+
+-- Refresh tokens issued before sessions existed cannot be rotated; their
+-- holders log in again.
+UPDATE refresh_tokens SET revoked_at = now() WHERE revoked_at IS NULL;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id BIGINT REFERENCES sessions(id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS used_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
@@ -0,0 +1,10 @@
+-- Emails of deleted clinicians. The identity provider keeps issuing tokens
+-- for them, which would otherwise fall back to the token's roles claim or,
+-- with OIDC_AUTO_PROVISION, create a fresh clinician record. Creating a
+-- clinician with the email again lets them back in.
+CREATE TABLE IF NOT EXISTS disabled_identities (
+    email TEXT PRIMARY KEY CHECK (email = lower(email)),
+    clinician_id BIGINT,
+    disabled_by BIGINT REFERENCES clinicians(id) ON DELETE SET NULL,
+    disabled_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
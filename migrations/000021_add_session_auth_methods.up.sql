@@ -0,0 +1,4 @@
+-- How the user authenticated when the session started ("pwd", "pwd otp"),
+-- carried into the amr claim of refreshed access tokens. Existing sessions
+-- are assumed to be password-only.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auth_methods TEXT NOT NULL DEFAULT 'pwd';
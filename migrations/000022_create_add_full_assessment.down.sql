@@ -0,0 +1,8 @@
+-- Only drop the function the up migration created.
+DO $do$
+BEGIN
+    IF obj_description(to_regprocedure('add_full_assessment(jsonb)'), 'pg_proc') = 'wound_iq migration 000022' THEN
+        DROP FUNCTION add_full_assessment(jsonb);
+    END IF;
+END
+$do$;
@@ -0,0 +1,55 @@
+-- Writes the document POST /v1/assessments/full has already validated:
+-- the assessment, its measurement (derived fields computed by the API),
+-- tissue composition, treatment orders and image references. Returns the
+-- new assessment ID. Runs inside the caller's transaction. The inserts
+-- mirror insertAssessment, insertMeasurement and insertAssessmentDetails in
+-- internal/handlers; change both together.
+--
+-- A database that already ships add_full_assessment(jsonb) keeps its own
+-- function. The comment marks the one created here so that the down
+-- migration drops nothing else.
+DO $do$
+BEGIN
+    IF to_regprocedure('add_full_assessment(jsonb)') IS NULL THEN
+        EXECUTE $create$
+        CREATE FUNCTION add_full_assessment(doc jsonb) RETURNS bigint AS $fn$
+        DECLARE
+            a jsonb := doc->'assessment';
+            m jsonb := doc->'measurement';
+            new_id bigint;
+        BEGIN
+            INSERT INTO assessments (patient_id, clinician_id, wound_id, notes, exudate_amount, tissue_type, created_at, updated_at)
+            VALUES ((a->>'patient_id')::bigint, (a->>'clinician_id')::bigint, (a->>'wound_id')::bigint,
+                    COALESCE(a->>'notes', ''), a->>'exudate_amount', a->>'tissue_type', now(), now())
+            RETURNING id INTO new_id;
+
+            IF m IS NOT NULL AND jsonb_typeof(m) = 'object' THEN
+                INSERT INTO wound_measurements (assessment_id, length_cm, width_cm, depth_cm, area_cm2, volume_cm3, undermining, tunneling, created_at)
+                VALUES (new_id, (m->>'length_cm')::numeric, (m->>'width_cm')::numeric, COALESCE((m->>'depth_cm')::numeric, 0),
+                        (m->>'area_cm2')::numeric, (m->>'volume_cm3')::numeric,
+                        COALESCE(NULLIF(m->'undermining', 'null'::jsonb), '[]'), COALESCE(NULLIF(m->'tunneling', 'null'::jsonb), '[]'), now());
+            END IF;
+
+            INSERT INTO assessment_tissue_types (assessment_id, tissue_type, percent)
+            SELECT new_id, t->>'tissue_type', (t->>'percent')::numeric
+            FROM jsonb_array_elements(COALESCE(doc->'tissue_types', '[]')) AS t;
+
+            INSERT INTO treatments (assessment_id, wound_id, cleanser, primary_dressing, secondary_dressing, offloading,
+                                    change_frequency, status, started_at, created_at, updated_at)
+            SELECT new_id, (t->>'wound_id')::bigint, COALESCE(t->>'cleanser', ''), COALESCE(t->>'primary_dressing', ''),
+                   COALESCE(t->>'secondary_dressing', ''), COALESCE(t->>'offloading', ''), COALESCE(t->>'change_frequency', ''),
+                   COALESCE(t->>'status', 'active'), COALESCE((t->>'started_at')::timestamptz, now()), now(), now()
+            FROM jsonb_array_elements(COALESCE(doc->'treatments', '[]')) AS t;
+
+            INSERT INTO assessment_image_references (assessment_id, uri, caption, captured_at, created_at)
+            SELECT new_id, r->>'uri', COALESCE(r->>'caption', ''), (r->>'captured_at')::timestamptz, now()
+            FROM jsonb_array_elements(COALESCE(doc->'image_references', '[]')) AS r;
+
+            RETURN new_id;
+        END;
+        $fn$ LANGUAGE plpgsql
+        $create$;
+        COMMENT ON FUNCTION add_full_assessment(jsonb) IS 'wound_iq migration 000022';
+    END IF;
+END
+$do$;
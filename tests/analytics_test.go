@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/analytics"
+)
+
+func TestPercentAreaReduction(t *testing.T) {
+	cases := []struct {
+		baseline, current float64
+		want              float64
+		ok                bool
+	}{
+		{10, 6, 40, true},
+		{10, 0, 100, true},
+		{10, 12, -20, true},
+		{0, 1, 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := analytics.PercentAreaReduction(tc.baseline, tc.current)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("PercentAreaReduction(%v, %v) = %v, %v; want %v, %v", tc.baseline, tc.current, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAnalyzeTrajectory(t *testing.T) {
+	day := 24 * time.Hour
+	base := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	at := func(days int, area float64) analytics.Point {
+		return analytics.Point{MeasuredAt: base.Add(time.Duration(days) * day), AreaCm2: area}
+	}
+
+	cases := []struct {
+		name       string
+		points     []analytics.Point
+		now        time.Time
+		wantStatus string
+		wantWeek4  float64 // day of the week-4 point, -1 for none
+	}{
+		{"on track", []analytics.Point{at(0, 10), at(14, 7), at(28, 5)}, base.Add(30 * day), analytics.StatusOnTrack, 28},
+		{"non-healing", []analytics.Point{at(0, 10), at(14, 9), at(27, 7)}, base.Add(30 * day), analytics.StatusNonHealing, 27},
+		{"exactly 40 percent is on track", []analytics.Point{at(0, 10), at(28, 6)}, base.Add(28 * day), analytics.StatusOnTrack, 28},
+		{"closest to day 28 wins", []analytics.Point{at(0, 10), at(22, 5), at(30, 8)}, base.Add(31 * day), analytics.StatusNonHealing, 30},
+		{"pending", []analytics.Point{at(0, 10), at(7, 9)}, base.Add(10 * day), analytics.StatusPending, -1},
+		{"window missed", []analytics.Point{at(0, 10), at(14, 9), at(42, 2)}, base.Add(42 * day), analytics.StatusNotEvaluable, -1},
+		{"zero baseline", []analytics.Point{at(0, 0), at(28, 1)}, base.Add(28 * day), analytics.StatusNotEvaluable, 28},
+		{"unsorted input", []analytics.Point{at(28, 5), at(0, 10)}, base.Add(28 * day), analytics.StatusOnTrack, 28},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := analytics.Analyze(tc.points, tc.now)
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if got.Status != tc.wantStatus {
+				t.Errorf("status = %q (%s), want %q", got.Status, got.Reason, tc.wantStatus)
+			}
+			switch {
+			case tc.wantWeek4 < 0 && got.Week4 != nil:
+				t.Errorf("unexpected week-4 point at day %v", got.Week4.DaysFromBaseline)
+			case tc.wantWeek4 >= 0 && (got.Week4 == nil || got.Week4.DaysFromBaseline != tc.wantWeek4):
+				t.Errorf("week-4 point = %+v, want day %v", got.Week4, tc.wantWeek4)
+			}
+			if len(got.Points) != len(tc.points) {
+				t.Errorf("got %d points, want %d", len(got.Points), len(tc.points))
+			}
+		})
+	}
+}
+
+func TestAnalyzeHealingRate(t *testing.T) {
+	base := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	got, err := analytics.Analyze([]analytics.Point{
+		{MeasuredAt: base, AreaCm2: 12},
+		{MeasuredAt: base.Add(28 * 24 * time.Hour), AreaCm2: 4},
+	}, base.Add(28*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got.WeeksElapsed != 4 {
+		t.Errorf("weeks elapsed = %v, want 4", got.WeeksElapsed)
+	}
+	if got.WeeklyHealingRateCm2 == nil || *got.WeeklyHealingRateCm2 != 2 {
+		t.Errorf("weekly healing rate = %v, want 2", got.WeeklyHealingRateCm2)
+	}
+	if got.PercentAreaReduction == nil || *got.PercentAreaReduction != 66.67 {
+		t.Errorf("PAR = %v, want 66.67", got.PercentAreaReduction)
+	}
+	if got.WeeklyPercentReduction == nil || *got.WeeklyPercentReduction != 16.67 {
+		t.Errorf("weekly PAR = %v, want 16.67", got.WeeklyPercentReduction)
+	}
+
+	if _, err := analytics.Analyze(nil, base); err != analytics.ErrNoMeasurements {
+		t.Errorf("Analyze(nil) error = %v, want ErrNoMeasurements", err)
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/apikey"
+)
+
+func TestAPIKeyGenerateAndMatch(t *testing.T) {
+	k, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, ok := apikey.Split(k.Plaintext)
+	if !ok || prefix != k.Prefix {
+		t.Fatalf("Split(%q) = %q, %v; want %q", k.Plaintext, prefix, ok, k.Prefix)
+	}
+	if !apikey.Matches(k.Plaintext, k.Hash) {
+		t.Fatal("key does not match its own hash")
+	}
+	if apikey.Matches(k.Plaintext+"0", k.Hash) {
+		t.Fatal("altered key matched")
+	}
+
+	other, _ := apikey.Generate()
+	if other.Plaintext == k.Plaintext || other.Prefix == k.Prefix {
+		t.Fatal("two generated keys collided")
+	}
+}
+
+func TestAPIKeySplit(t *testing.T) {
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{"wiq_0123abcd_" + strings.Repeat("a", 64), true},
+		{"wiq_0123abcd_" + strings.Repeat("a", 63), false},
+		{"wiq_0123abc_" + strings.Repeat("a", 64), false},
+		{"abc_0123abcd_" + strings.Repeat("a", 64), false},
+		{"wiq_0123abcd", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, ok := apikey.Split(tt.key); ok != tt.ok {
+			t.Errorf("Split(%q) ok = %v, want %v", tt.key, ok, tt.ok)
+		}
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	valid := map[string]bool{
+		"patients:read":     true,
+		"assessments:write": true,
+		"care_plans:read":   true,
+		"patients:admin":    false,
+		"api_keys:read":     false,
+		"patients":          false,
+		"":                  false,
+	}
+	for s, want := range valid {
+		if got := apikey.ValidScope(s); got != want {
+			t.Errorf("ValidScope(%q) = %v, want %v", s, got, want)
+		}
+	}
+
+	scopes := []string{"patients:read", "assessments:write"}
+	allows := []struct {
+		resource string
+		write    bool
+		want     bool
+	}{
+		{"patients", false, true},
+		{"patients", true, false},
+		{"assessments", true, true},
+		{"assessments", false, false}, // write does not imply read
+		{"wounds", false, false},
+	}
+	for _, tt := range allows {
+		if got := apikey.Allows(scopes, tt.resource, tt.write); got != tt.want {
+			t.Errorf("Allows(%s, write=%v) = %v, want %v", tt.resource, tt.write, got, tt.want)
+		}
+	}
+}
+
+const apiKeyLookup = `SELECT id, name, key_hash, scopes, expires_at, revoked_at FROM api_keys`
+
+// apiKeyRow configures the key lookup to return key with the given scopes.
+func apiKeyRow(key apikey.Key, scopes string, expires, revoked interface{}) func(sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(apiKeyLookup).WithArgs(key.Prefix).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_hash", "scopes", "expires_at", "revoked_at"}).
+				AddRow(3, "ehr-engine", key.Hash, []byte(scopes), expires, revoked))
+		m.ExpectExec(`UPDATE api_keys SET last_used_at`).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	key, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		header string
+		lookup func(sqlmock.Sqlmock)
+		method string
+		path   string
+		want   int
+	}{
+		// Scope checks run before the handler, whose queries sqlmock does
+		// not expect, so an allowed request surfaces as 500 rather than 403.
+		{"read scope allows GET", key.Plaintext, apiKeyRow(key, `["patients:read"]`, nil, nil), http.MethodGet, "/v1/patients/1", http.StatusInternalServerError},
+		{"read scope denies PUT", key.Plaintext, apiKeyRow(key, `["patients:read"]`, nil, nil), http.MethodPut, "/v1/patients/1", http.StatusForbidden},
+		{"other resource denied", key.Plaintext, apiKeyRow(key, `["patients:read"]`, nil, nil), http.MethodGet, "/v1/assessments/1", http.StatusForbidden},
+		{"write scope does not cover admin routes", key.Plaintext, apiKeyRow(key, `["patients:write"]`, nil, nil), http.MethodDelete, "/v1/patients/1", http.StatusForbidden},
+		{"api keys cannot change care teams", key.Plaintext, apiKeyRow(key, `["patients:write"]`, nil, nil), http.MethodPost, "/v1/patients/1/care-team", http.StatusForbidden},
+		{"api keys cannot revoke consent", key.Plaintext, apiKeyRow(key, `["patients:write"]`, nil, nil), http.MethodPost, "/v1/consents/1/revoke", http.StatusForbidden},
+		{"api keys cannot manage clinicians", key.Plaintext, apiKeyRow(key, `["clinicians:write"]`, nil, nil), http.MethodDelete, "/v1/clinicians/1", http.StatusForbidden},
+		{"api keys cannot restrict patients", key.Plaintext, apiKeyRow(key, `["patients:write"]`, nil, nil), http.MethodPut, "/v1/patients/1/restricted", http.StatusForbidden},
+		{"api keys cannot manage keys", key.Plaintext, apiKeyRow(key, `["patients:write"]`, nil, nil), http.MethodPost, "/v1/api-keys", http.StatusForbidden},
+		{"revoked", key.Plaintext, apiKeyRow(key, `["patients:read"]`, nil, past), http.MethodGet, "/v1/patients/1", http.StatusUnauthorized},
+		{"expired", key.Plaintext, apiKeyRow(key, `["patients:read"]`, past, nil), http.MethodGet, "/v1/patients/1", http.StatusUnauthorized},
+		{"wrong secret", "wiq_" + key.Prefix + "_" + strings.Repeat("0", 64), apiKeyRow(key, `["patients:read"]`, nil, nil), http.MethodGet, "/v1/patients/1", http.StatusUnauthorized},
+		{"unknown prefix", key.Plaintext, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(apiKeyLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_hash", "scopes", "expires_at", "revoked_at"}))
+		}, http.MethodGet, "/v1/patients/1", http.StatusUnauthorized},
+		{"malformed", "not-a-key", nil, http.MethodGet, "/v1/patients/1", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRBACRouter(t, tt.lookup)
+			body := strings.NewReader("")
+			if tt.method != http.MethodGet {
+				body = strings.NewReader("{}")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(apikey.Header, tt.header)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
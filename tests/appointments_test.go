@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var appointmentColumns = []string{"id", "patient_id", "clinician_id", "wound_id", "starts_at", "ends_at", "location",
+	"visit_type", "status", "assessment_id", "created_at", "updated_at"}
+
+func TestAppointmentAssessmentScopedToCareTeam(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM appointments WHERE id=\$1 AND \(\(EXISTS .*ct.clinician_id = \$2.* FOR UPDATE`).
+		WithArgs("3", int64(7)).
+		WillReturnRows(sqlmock.NewRows(appointmentColumns))
+	mock.ExpectQuery(`SELECT p.restricted FROM appointments ap JOIN patients p`).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(false))
+	mock.ExpectRollback()
+
+	w := serveAs(t, r, http.MethodPost, "/v1/appointments/3/assessment")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "assessment_id") {
+		t.Errorf("body = %s, leaks the appointment", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateAppointmentConflict(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	expectPatientVisible(mock, int64(5))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM clinicians WHERE id = \$1 FOR UPDATE`).WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectQuery(`FROM appointments\s+WHERE clinician_id = \$1 AND id <> \$2\s+AND status IN \('scheduled', 'completed'\)`).
+		WithArgs(int64(8), int64(0), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(appointmentColumns).
+			AddRow(11, 6, 8, nil, time.Now(), time.Now().Add(time.Hour), "", "follow_up", "scheduled", nil, time.Now(), time.Now()))
+	mock.ExpectRollback()
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/appointments",
+		`{"patient_id":5,"clinician_id":8,"starts_at":"2026-11-02T09:00:00Z","ends_at":"2026-11-02T09:30:00Z","visit_type":"follow_up"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Conflict struct {
+			ID int64 `json:"id"`
+		} `json:"conflicting_appointment"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Conflict.ID != 11 {
+		t.Errorf("body = %s, want conflicting appointment 11", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateAppointmentStatus(t *testing.T) {
+	start := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	current := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows(appointmentColumns).
+			AddRow(3, 5, 8, nil, start, start.Add(30*time.Minute), "", "follow_up", status, nil, time.Now(), time.Now())
+	}
+	const conflictQuery = `FROM appointments\s+WHERE clinician_id = \$1 AND id <> \$2`
+	tests := []struct {
+		name   string
+		from   string
+		body   string
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"cancel", "scheduled", `{"status":"cancelled"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectExec(`UPDATE appointments SET`).
+				WithArgs(int64(8), start, start.Add(30*time.Minute), "", "follow_up", "cancelled", int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+		}, http.StatusNoContent},
+		{"complete", "scheduled", `{"status":"completed"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectExec(`UPDATE appointments SET`).
+				WithArgs(int64(8), start, start.Add(30*time.Minute), "", "follow_up", "completed", int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+		}, http.StatusNoContent},
+		{"reactivate into a conflict", "cancelled", `{"status":"scheduled"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`SELECT id FROM clinicians WHERE id = \$1 FOR UPDATE`).WithArgs(int64(8)).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+			m.ExpectQuery(conflictQuery).WithArgs(int64(8), int64(3), start, start.Add(30*time.Minute)).
+				WillReturnRows(sqlmock.NewRows(appointmentColumns).
+					AddRow(12, 6, 8, nil, start, start.Add(time.Hour), "", "initial", "scheduled", nil, time.Now(), time.Now()))
+			m.ExpectRollback()
+		}, http.StatusConflict},
+		{"reactivate into a free slot", "no_show", `{"status":"scheduled"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`SELECT id FROM clinicians WHERE id = \$1 FOR UPDATE`).WithArgs(int64(8)).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+			m.ExpectQuery(conflictQuery).WithArgs(int64(8), int64(3), start, start.Add(30*time.Minute)).
+				WillReturnRows(sqlmock.NewRows(appointmentColumns))
+			m.ExpectExec(`UPDATE appointments SET`).
+				WithArgs(int64(8), start, start.Add(30*time.Minute), "", "follow_up", "scheduled", int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+		}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "physician")
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM appointments WHERE id=\$1 AND .* FOR UPDATE`).WithArgs("3", int64(7)).
+				WillReturnRows(current(tt.from))
+			tt.expect(mock)
+
+			if w := sendJSON(t, r, http.MethodPut, "/v1/appointments/3", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	r, _ := scopedRouter(t, "physician")
+	if w := sendJSON(t, r, http.MethodPut, "/v1/appointments/3", `{"status":"done"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: status = %d, want 400", w.Code)
+	}
+}
+
+func TestAppointmentAssessment(t *testing.T) {
+	appointment := func(status string, assessmentID interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows(appointmentColumns).
+			AddRow(3, 5, 8, 9, time.Now().Add(-time.Hour), time.Now(), "", "follow_up", status, assessmentID, time.Now(), time.Now())
+	}
+	tests := []struct {
+		name   string
+		row    *sqlmock.Rows
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"linked", appointment("completed", nil), func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(5))
+			expectEnrolment(m, int64(5), false)
+			m.ExpectQuery(`SELECT patient_id FROM wounds WHERE id = \$1`).WithArgs(int64(9)).
+				WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+			m.ExpectQuery(`INSERT INTO assessments`).WithArgs(int64(5), int64(8), int64(9), "", nil, nil, nil).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+			m.ExpectExec(`UPDATE appointments SET assessment_id = \$1, updated_at = now\(\) WHERE id = \$2`).WithArgs(int64(30), int64(3)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+		}, http.StatusCreated},
+		{"not completed", appointment("scheduled", nil), func(m sqlmock.Sqlmock) { m.ExpectRollback() }, http.StatusConflict},
+		{"already linked", appointment("completed", 21), func(m sqlmock.Sqlmock) { m.ExpectRollback() }, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "physician")
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM appointments WHERE id=\$1 AND .* FOR UPDATE`).WithArgs("3", int64(7)).WillReturnRows(tt.row)
+			tt.expect(mock)
+
+			if w := serveAs(t, r, http.MethodPost, "/v1/appointments/3/assessment"); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
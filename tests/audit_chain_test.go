@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/audit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+var chainColumns = []string{"id", "occurred_at", "actor", "actor_clinician_id", "actor_api_key_id", "action", "resource_type",
+	"resource_id", "patient_id", "route", "status", "request_id", "client_ip", "prev_hash", "hash"}
+
+type chainedRow struct {
+	entry      models.AuditEntry
+	prev, hash string
+}
+
+// buildChain returns n correctly chained entries.
+func buildChain(n int) []chainedRow {
+	base := time.Date(2026, 3, 1, 9, 0, 0, 123456000, time.UTC)
+	out := make([]chainedRow, n)
+	prev := ""
+	for i := range out {
+		patient := int64(40 + i)
+		e := models.AuditEntry{
+			ID: int64(i + 1), OccurredAt: base.Add(time.Duration(i) * time.Minute), Actor: "user-1",
+			Action: audit.Read, ResourceType: "patients", ResourceID: "40", PatientID: &patient,
+			Route: "GET /v1/patients/:id", Status: 200, RequestID: "r", ClientIP: "10.0.0.1",
+		}
+		h := audit.EntryHash(prev, e)
+		out[i] = chainedRow{e, prev, h}
+		prev = h
+	}
+	return out
+}
+
+func chainRows(rows []chainedRow) *sqlmock.Rows {
+	r := sqlmock.NewRows(chainColumns)
+	for _, c := range rows {
+		e := c.entry
+		r.AddRow(e.ID, e.OccurredAt, e.Actor, nil, nil, e.Action, e.ResourceType, e.ResourceID, *e.PatientID,
+			e.Route, e.Status, e.RequestID, e.ClientIP, c.prev, c.hash)
+	}
+	return r
+}
+
+func signCheckpoint(key ed25519.PrivateKey, id int64, hash string) []driver.Value {
+	sig := ed25519.Sign(key, audit.CheckpointMessage(id, hash))
+	return []driver.Value{id, hash, audit.KeyID(key.Public().(ed25519.PublicKey)), base64.StdEncoding.EncodeToString(sig)}
+}
+
+func TestEntryHash(t *testing.T) {
+	chain := buildChain(2)
+	e := chain[1].entry
+	if audit.EntryHash(chain[1].prev, e) != chain[1].hash {
+		t.Fatal("hash is not deterministic")
+	}
+	if audit.EntryHash("other", e) == chain[1].hash {
+		t.Error("hash ignores the previous hash")
+	}
+	e.Actor = "user-2"
+	if audit.EntryHash(chain[1].prev, e) == chain[1].hash {
+		t.Error("hash ignores the actor")
+	}
+	e = chain[1].entry
+	e.OccurredAt = e.OccurredAt.In(time.FixedZone("x", 3600))
+	if audit.EntryHash(chain[1].prev, e) != chain[1].hash {
+		t.Error("hash depends on the time zone")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	pub := key.Public().(ed25519.PublicKey)
+	chain := buildChain(3)
+
+	edited := buildChain(3)
+	edited[1].entry.Actor = "someone-else"
+
+	forged := signCheckpoint(otherKey, 2, chain[1].hash)
+	forged[2] = audit.KeyID(pub)
+
+	tests := []struct {
+		name        string
+		rows        []chainedRow
+		checkpoints [][]driver.Value
+		breakAt     int64
+		reason      string
+		verified    int
+	}{
+		{"intact", chain, [][]driver.Value{signCheckpoint(key, 2, chain[1].hash)}, 0, "", 1},
+		{"edited", edited, nil, 2, "edited", 0},
+		{"removed", []chainedRow{chain[0], chain[2]}, nil, 3, "removed", 0},
+		{"truncated", chain[:1], [][]driver.Value{signCheckpoint(key, 2, chain[1].hash)}, 2, "truncated", 0},
+		{"forged signature", chain, [][]driver.Value{forged}, 2, "signature", 0},
+		{"rewritten after checkpoint", chain, [][]driver.Value{signCheckpoint(key, 2, strings.Repeat("0", 64))}, 2, "rewritten", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			cps := sqlmock.NewRows([]string{"entry_id", "entry_hash", "key_id", "signature"})
+			for _, cp := range tt.checkpoints {
+				cps.AddRow(cp...)
+			}
+			mock.ExpectQuery(`FROM audit_checkpoints`).WillReturnRows(cps)
+			mock.ExpectQuery(`FROM audit_log WHERE id > \$1`).WithArgs(int64(0), 1000).WillReturnRows(chainRows(tt.rows))
+
+			rep, err := audit.Verify(context.Background(), db, pub, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.breakAt == 0 {
+				if rep.Break != nil {
+					t.Fatalf("unexpected break: %s", rep.Break)
+				}
+			} else if rep.Break == nil || rep.Break.EntryID != tt.breakAt || !strings.Contains(rep.Break.Reason, tt.reason) {
+				t.Fatalf("break = %v, want entry %d (%s)", rep.Break, tt.breakAt, tt.reason)
+			}
+			if rep.Checkpoints != tt.verified {
+				t.Errorf("verified checkpoints = %d, want %d", rep.Checkpoints, tt.verified)
+			}
+		})
+	}
+}
+
+func TestCheckpointSignsHead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	head := strings.Repeat("a", 64)
+	mock.ExpectQuery(`SELECT l.id, l.hash FROM audit_log l`).WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).AddRow(9, head))
+	want := signCheckpoint(key, 9, head)
+	mock.ExpectExec(`INSERT INTO audit_checkpoints`).WithArgs(want...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cp := &audit.Checkpointer{DB: db, Log: zap.NewNop(), Key: key}
+	id, err := cp.Checkpoint(context.Background())
+	if err != nil || id != 9 {
+		t.Fatalf("Checkpoint = %d, %v", id, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadAuditKeys(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	dir := t.TempDir()
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	privPath := filepath.Join(dir, "audit.key")
+	pubPath := filepath.Join(dir, "audit.pub")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600)
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644)
+
+	if k, err := audit.LoadSigningKey(privPath); err != nil || !k.Equal(priv) {
+		t.Fatalf("LoadSigningKey: %v", err)
+	}
+	for _, p := range []string{privPath, pubPath} {
+		if k, err := audit.LoadPublicKey(p); err != nil || !k.Equal(pub) {
+			t.Fatalf("LoadPublicKey(%s): %v", filepath.Base(p), err)
+		}
+	}
+	if _, err := audit.LoadSigningKey(pubPath); err == nil {
+		t.Error("LoadSigningKey accepted a public key")
+	}
+}
+
+// capturedArg matches any argument and keeps it.
+type capturedArg struct{ v driver.Value }
+
+func (a *capturedArg) Match(v driver.Value) bool {
+	a.v = v
+	return true
+}
+
+func TestQueuedAuditDoesNotHoldUpRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rec := audit.NewRecorder(db, zap.NewNop(), 8)
+	r := gin.New()
+	r.GET("/v1/patients/:id", rec.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// The first append is slow, so the two requests made while it runs
+	// queue behind it and are appended together, chained to each other.
+	insert := func(id int64, patient string, prev driver.Value, hash *capturedArg) {
+		mock.ExpectQuery(`SELECT nextval`).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(id))
+		mock.ExpectExec(`INSERT INTO audit_log`).
+			WithArgs(id, sqlmock.AnyArg(), "anonymous", nil, nil, "read", "patients", patient, sqlmock.AnyArg(),
+				"GET /v1/patients/:id", 200, nil, sqlmock.AnyArg(), prev, hash).
+			WillReturnResult(sqlmock.NewResult(id, 1))
+	}
+	mock.ExpectBegin().WillDelayFor(300 * time.Millisecond)
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT hash FROM audit_log`).WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	insert(1, "1", "", &capturedArg{})
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT hash FROM audit_log`).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("h1"))
+	second, third := &capturedArg{}, &capturedArg{}
+	insert(2, "2", "h1", second)
+	insert(3, "3", sqlmock.AnyArg(), third)
+	mock.ExpectCommit()
+
+	var served time.Duration
+	for _, id := range []string{"1", "2", "3"} {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/patients/"+id, nil))
+		served += time.Since(start)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		if id == "1" {
+			time.Sleep(50 * time.Millisecond) // let the writer take the first entry
+		}
+	}
+	if served >= 300*time.Millisecond {
+		t.Errorf("requests took %s, waiting on the audit write", served)
+	}
+	rec.Close()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if second.v == nil || second.v == third.v {
+		t.Errorf("batched entries not chained: hashes %v, %v", second.v, third.v)
+	}
+}
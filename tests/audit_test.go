@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/requestid"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
+)
+
+// expectAudit expects one chained append whose insert carries args
+// followed by the generated id, timestamp and hashes.
+func expectAudit(mock sqlmock.Sqlmock, args ...driver.Value) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT hash FROM audit_log`).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("prev"))
+	mock.ExpectQuery(`SELECT nextval`).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(100))
+	full := append([]driver.Value{int64(100), sqlmock.AnyArg()}, args...)
+	full = append(full, "prev", sqlmock.AnyArg())
+	mock.ExpectExec(`INSERT INTO audit_log`).WithArgs(full...).WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectCommit()
+}
+
+func TestAuditRecordsPatientRead(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`FROM patients WHERE id=\$1`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(patientColumns).AddRow(5, "A Patient", nil, "f", "MRN5", time.Now(), time.Now(), nil, nil, false, false))
+	expectAudit(mock, "user-1", int64(7), nil, "read", "patients", "5", int64(5), "GET /v1/patients/:id", 200, "req-1", "192.0.2.1")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/patients/5", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()))
+	req.Header.Set(requestid.Header, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get(requestid.Header); got != "req-1" {
+		t.Errorf("%s = %q, want req-1", requestid.Header, got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditRecordsAssessmentPatient(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`DELETE FROM assessments WHERE id = \$1 AND \(\(EXISTS .* RETURNING patient_id`).
+		WithArgs("9", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(3))
+	expectAudit(mock, "user-1", int64(7), nil, "delete", "assessments", "9", int64(3), "DELETE /v1/assessments/:id", 204, sqlmock.AnyArg(), sqlmock.AnyArg())
+
+	if w := serveAs(t, r, http.MethodDelete, "/v1/assessments/9"); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditRecordsRefusedAccess(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p`).
+		WithArgs("42", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT restricted FROM patients WHERE id = \$1`).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"restricted"}))
+	expectAudit(mock, "user-1", int64(7), nil, "read", "patients", "42", int64(42), "GET /v1/patients/:id/history", 404, sqlmock.AnyArg(), sqlmock.AnyArg())
+
+	if w := serveAs(t, r, http.MethodGet, "/v1/patients/42/history"); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearchAuditLog(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`FROM audit_log WHERE patient_id = \$1 AND actor = \$2 AND occurred_at >= \$3 AND occurred_at < \$4 ORDER BY occurred_at DESC, id DESC LIMIT \$5 OFFSET \$6`).
+		WithArgs("5", "user-2", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at", "actor", "actor_clinician_id", "actor_api_key_id", "action",
+			"resource_type", "resource_id", "patient_id", "route", "status", "request_id", "client_ip"}).
+			AddRow(1, time.Now(), "user-2", 8, nil, "read", "patients", "5", 5, "GET /v1/patients/:id", 200, "r", "10.0.0.1"))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/audit-log?patient_id=5&actor=user-2&from=2026-01-01T00:00:00Z&to=2026-02-01T00:00:00Z")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	r, _ = scopedRouter(t, "admin")
+	if w := serveAs(t, r, http.MethodGet, "/v1/audit-log?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad date: status = %d, want 400", w.Code)
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	for _, incoming := range []string{"", "bad id with spaces"} {
+		r, _ := scopedRouter(t, "admin")
+		req := httptest.NewRequest(http.MethodGet, "/v1/audit-log?from=x", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()))
+		req.Header.Set(requestid.Header, incoming)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get(requestid.Header); len(got) != 32 {
+			t.Errorf("incoming %q: generated ID = %q, want 32 hex chars", incoming, got)
+		}
+	}
+}
+
+func TestAuditRecordsWoundRead(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`FROM wounds WHERE id=\$1`).WithArgs("9").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id", "anatomical_location", "etiology", "onset_date", "present_on_admission", "status", "created_at", "updated_at"}).
+			AddRow(9, 5, "sacrum", "pressure", nil, false, "open", time.Now(), time.Now()))
+	expectAudit(mock, "user-1", int64(7), nil, "read", "wounds", "9", int64(5), "GET /v1/wounds/:id", 200, sqlmock.AnyArg(), sqlmock.AnyArg())
+
+	if w := serveAs(t, r, http.MethodGet, "/v1/wounds/9"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestAuditNamesPatientOnRecordRoutes walks the PHI routes that identify a
+// record but not its patient, and checks each audit entry names patient 5.
+func TestAuditNamesPatientOnRecordRoutes(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	patient := func(m sqlmock.Sqlmock, query string) {
+		m.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+	}
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+		expect             func(sqlmock.Sqlmock)
+	}{
+		{http.MethodGet, "/v1/treatments/5", "", http.StatusOK, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`FROM treatments WHERE id=\$1`).WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "wound_id",
+				"cleanser", "primary_dressing", "secondary_dressing", "offloading", "change_frequency", "status", "started_at",
+				"discontinued_at", "created_at", "updated_at", "patient_id"}).
+				AddRow(5, 30, 9, "", "alginate", "", "", "daily", "active", now, nil, now, now, 5))
+		}},
+		{http.MethodPut, "/v1/treatments/5", `{}`, http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `UPDATE treatments SET .* RETURNING \(SELECT tw.patient_id`)
+		}},
+		{http.MethodDelete, "/v1/treatments/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `DELETE FROM treatments .* RETURNING \(SELECT tw.patient_id`)
+		}},
+		{http.MethodPost, "/v1/wounds", `{"patient_id":5,"anatomical_location":"sacrum"}`, http.StatusCreated, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(5))
+			m.ExpectQuery(`INSERT INTO wounds`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		}},
+		{http.MethodPut, "/v1/wounds/5", `{}`, http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `UPDATE wounds SET .* RETURNING patient_id`)
+		}},
+		{http.MethodDelete, "/v1/wounds/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `DELETE FROM wounds .* RETURNING patient_id`)
+		}},
+		{http.MethodPut, "/v1/care-plans/5", `{"title":"Offload heels"}`, http.StatusOK, func(m sqlmock.Sqlmock) {
+			m.ExpectBegin()
+			m.ExpectQuery(`SELECT id FROM care_plans WHERE id = \$1 AND .* FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+			m.ExpectQuery(`FROM care_plans p WHERE p.id = \$1`).WillReturnRows(sqlmock.NewRows(carePlanColumns).
+				AddRow(5, 5, 7, "Offload sacrum", `[]`, `[]`, now, "active", 1, now, now, `[]`, `[]`))
+			m.ExpectExec(`INSERT INTO care_plan_versions`).WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectExec(`UPDATE care_plans SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+		}},
+		{http.MethodDelete, "/v1/care-plans/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `DELETE FROM care_plans .* RETURNING patient_id`)
+		}},
+		{http.MethodDelete, "/v1/braden/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `DELETE FROM braden_assessments .* RETURNING patient_id`)
+		}},
+		{http.MethodPost, "/v1/appointments", `{"patient_id":5,"clinician_id":8,"starts_at":"2026-11-02T09:00:00Z",
+			"ends_at":"2026-11-02T09:30:00Z","visit_type":"follow_up"}`, http.StatusCreated, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(5))
+			m.ExpectBegin()
+			m.ExpectQuery(`SELECT id FROM clinicians WHERE id = \$1 FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+			m.ExpectQuery(`FROM appointments\s+WHERE clinician_id = \$1`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			m.ExpectQuery(`INSERT INTO appointments`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+			m.ExpectCommit()
+		}},
+		{http.MethodDelete, "/v1/appointments/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			patient(m, `DELETE FROM appointments .* RETURNING patient_id`)
+		}},
+		{http.MethodDelete, "/v1/images/5", "", http.StatusNoContent, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`DELETE FROM assessment_images .* RETURNING .*\(SELECT ia.patient_id`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "content_type", "size_bytes", "width", "height",
+					"storage_key", "thumbnail_key", "created_at", "patient_id"}).
+					AddRow(5, 30, "image/jpeg", 100, 10, 10, "assessments/30/a.jpg", "assessments/30/a_thumb.jpg", now, 5))
+		}},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r, mock := scopedRouterWithStore(t, "physician", &config.Config{}, store)
+			tt.expect(mock)
+			expectAudit(mock, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				sqlmock.AnyArg(), int64(5), sqlmock.AnyArg(), tt.status, sqlmock.AnyArg(), sqlmock.AnyArg())
+
+			if w := sendJSON(t, r, tt.method, tt.path, tt.body); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+)
+
+var testHS256Secret = []byte("0123456789abcdef0123456789abcdef")
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func rsaJWK(kid string, k *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+}
+
+func ecJWK(kid string, k *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32)))}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"email": "nurse@example.org",
+		"iss":   "https://idp.example.org",
+		"aud":   "wound-iq",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"nurse"},
+	}
+}
+
+func withClaim(name string, value interface{}) jwt.MapClaims {
+	c := validClaims()
+	if value == nil {
+		delete(c, name)
+	} else {
+		c[name] = value
+	}
+	return c
+}
+
+func TestVerifierHS256(t *testing.T) {
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret, Issuer: "https://idp.example.org", Audience: "wound-iq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := v.Verify(context.Background(), signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.Subject != "user-1" || p.Email != "nurse@example.org" || len(p.Roles) != 1 || p.Roles[0] != "nurse" {
+		t.Errorf("principal = %+v", p)
+	}
+
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"expired", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, withClaim("exp", time.Now().Add(-time.Hour).Unix()))},
+		{"no exp", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, withClaim("exp", nil))},
+		{"no sub", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, withClaim("sub", nil))},
+		{"wrong issuer", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, withClaim("iss", "https://evil.example"))},
+		{"wrong audience", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, withClaim("aud", "other-api"))},
+		{"wrong secret", signToken(t, jwt.SigningMethodHS256, "", []byte("another-secret-another-secret-xx"), validClaims())},
+		{"HS384 not allowed", signToken(t, jwt.SigningMethodHS384, "", testHS256Secret, validClaims())},
+		{"alg none", signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{"garbage", "not.a.jwt"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := v.Verify(context.Background(), tc.token); !errors.Is(err, auth.ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifierJWKSFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.LoadJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := auth.NewVerifier(auth.Options{Keys: keys, Audience: "wound-iq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := []string{
+		signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()),
+		signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims()),
+	}
+	for i, tok := range ok {
+		if _, err := v.Verify(context.Background(), tok); err != nil {
+			t.Errorf("token %d: %v", i, err)
+		}
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	bad := map[string]string{
+		"unknown kid":          signToken(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims()),
+		"wrong key":            signToken(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims()),
+		"alg/key mismatch":     signToken(t, jwt.SigningMethodES256, "rsa-1", ecKey, validClaims()),
+		"HS256 without secret": signToken(t, jwt.SigningMethodHS256, "rsa-1", testHS256Secret, validClaims()),
+	}
+	for name, tok := range bad {
+		if _, err := v.Verify(context.Background(), tok); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestVerifierMarksOnlyLocalHS256TokensLocal(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, rsaJWK("rsa-1", &rsaKey.PublicKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.LoadJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret, Keys: keys, Audience: "wound-iq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := func() jwt.MapClaims {
+		c := validClaims()
+		c["sub"], c["sid"], c[auth.LocalAccountClaim] = "user:5", 9, true
+		return c
+	}
+	unmarked := local()
+	delete(unmarked, auth.LocalAccountClaim)
+
+	cases := []struct {
+		name  string
+		token string
+		local bool
+	}{
+		{"local HS256", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, local()), true},
+		{"HS256 without the claim", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, unmarked), false},
+		{"RS256 claiming to be local", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, local()), false},
+	}
+	for _, tc := range cases {
+		p, err := v.Verify(context.Background(), tc.token)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if p.Local != tc.local || (p.SessionID == 9) != tc.local {
+			t.Errorf("%s: Local = %v, SessionID = %d", tc.name, p.Local, p.SessionID)
+		}
+	}
+}
+
+func TestRemoteKeySetRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if rotated.Load() {
+			w.Write(jwksJSON(t, rsaJWK("new", &newKey.PublicKey)))
+			return
+		}
+		w.Write(jwksJSON(t, rsaJWK("old", &oldKey.PublicKey)))
+	}))
+	defer srv.Close()
+
+	keys := auth.NewRemoteKeySet(srv.URL, srv.Client())
+	keys.MinRefresh = 0
+	v, err := auth.NewVerifier(auth.Options{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "old", oldKey, validClaims())); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "old", oldKey, validClaims())); err != nil {
+		t.Fatalf("old key (cached): %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1 (cached)", n)
+	}
+
+	rotated.Store(true)
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "new", newKey, validClaims())); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2 (refetch on unknown kid)", n)
+	}
+}
+
+func TestRemoteKeySetRateLimitsStaleRefresh(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches atomic.Int32
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(jwksJSON(t, rsaJWK("k1", &key.PublicKey)))
+	}))
+	defer srv.Close()
+
+	keys := auth.NewRemoteKeySet(srv.URL, srv.Client())
+	keys.TTL = time.Nanosecond
+	ctx := context.Background()
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	// Every later lookup finds the cache stale and the issuer down.
+	down.Store(true)
+	keys.MinRefresh = 0
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatalf("cached key through outage: %v", err)
+	}
+	keys.MinRefresh = time.Hour
+	for i := 0; i < 20; i++ {
+		if _, err := keys.Key(ctx, "k1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2 (stale refreshes limited by MinRefresh)", n)
+	}
+}
+
+func TestRemoteKeySetFetchesOutsideLock(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Write(jwksJSON(t, rsaJWK("k1", &key.PublicKey)))
+	}))
+	defer srv.Close()
+
+	keys := auth.NewRemoteKeySet(srv.URL, srv.Client())
+	keys.MinRefresh = 0
+	ctx := context.Background()
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown kids refetch, queueing behind the running fetch...
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.Key(ctx, "k2"); !errors.Is(err, auth.ErrUnknownKey) {
+				t.Errorf("unknown kid: err = %v", err)
+			}
+		}()
+	}
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// ...while known kids are served from the cache.
+	done := make(chan error)
+	go func() {
+		_, err := keys.Key(ctx, "k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached lookup blocked behind the refetch")
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(auth.Middleware(v, zap.NewNop()))
+	r.GET("/v1/me", func(c *gin.Context) {
+		p, ok := auth.PrincipalFrom(c)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, p.Subject)
+	})
+
+	cases := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{"missing", "", http.StatusUnauthorized, ""},
+		{"wrong scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+		{"invalid", "Bearer not.a.jwt", http.StatusUnauthorized, ""},
+		{"valid", "Bearer " + signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()), http.StatusOK, "user-1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			if tc.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+			if tc.body != "" && w.Body.String() != tc.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/braden"
+)
+
+func TestBradenRiskBand(t *testing.T) {
+	cases := []struct {
+		total int
+		want  string
+	}{
+		{23, braden.RiskNone},
+		{19, braden.RiskNone},
+		{18, braden.RiskMild},
+		{15, braden.RiskMild},
+		{14, braden.RiskModerate},
+		{13, braden.RiskModerate},
+		{12, braden.RiskHigh},
+		{10, braden.RiskHigh},
+		{9, braden.RiskVeryHigh},
+		{6, braden.RiskVeryHigh},
+	}
+	for _, tc := range cases {
+		if got := braden.RiskBand(tc.total); got != tc.want {
+			t.Errorf("RiskBand(%d) = %q, want %q", tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestBradenScore(t *testing.T) {
+	cases := []struct {
+		name      string
+		in        braden.Subscales
+		wantTotal int
+		wantBand  string
+		wantErr   bool
+	}{
+		{"best", bradenSubscales(4, 4, 4, 4, 4, 3), 23, braden.RiskNone, false},
+		{"worst", bradenSubscales(1, 1, 1, 1, 1, 1), 6, braden.RiskVeryHigh, false},
+		{"moderate", bradenSubscales(2, 2, 2, 3, 2, 2), 13, braden.RiskModerate, false},
+		{"friction out of range", bradenSubscales(4, 4, 4, 4, 4, 4), 0, "", true},
+		{"missing subscale", bradenSubscales(0, 4, 4, 4, 4, 3), 0, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			total, band, err := braden.Score(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d %q", total, band)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if total != tc.wantTotal || band != tc.wantBand {
+				t.Errorf("Score = %d %q, want %d %q", total, band, tc.wantTotal, tc.wantBand)
+			}
+		})
+	}
+}
+
+func bradenSubscales(sensory, moisture, activity, mobility, nutrition, friction int) braden.Subscales {
+	return braden.Subscales{
+		SensoryPerception: sensory,
+		Moisture:          moisture,
+		Activity:          activity,
+		Mobility:          mobility,
+		Nutrition:         nutrition,
+		FrictionShear:     friction,
+	}
+}
+
+func TestCreatePatientBraden(t *testing.T) {
+	const body = `{"clinician_id":8,"sensory_perception":3,"moisture":3,"activity":3,"mobility":3,"nutrition":3,"friction_shear":2}`
+	tests := []struct {
+		name      string
+		clinician bool
+		want      int
+	}{
+		{"created", true, http.StatusCreated},
+		{"unknown clinician", false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			expectPatientVisible(mock, "5")
+			mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM clinicians WHERE id = \$1\)`).WithArgs(int64(8)).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.clinician))
+			if tt.clinician {
+				mock.ExpectQuery(`INSERT INTO braden_assessments`).
+					WithArgs("5", int64(8), 3, 3, 3, 3, 3, 2, 17, braden.RiskMild, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+			}
+
+			if w := sendJSON(t, r, http.MethodPost, "/v1/patients/5/braden", body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/apikey"
+)
+
+// sendJSON is serveAs with a JSON body.
+func sendJSON(t *testing.T, r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRestrictedPatientIsForbidden(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1 AND .*break_glass_grants g`).
+		WithArgs("42", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT restricted FROM patients WHERE id = \$1`).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(true))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/42/history")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"restricted":true`) {
+		t.Errorf("body = %s, want restricted flag", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBreakGlassGrantOpensRestrictedPatient(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	mock.ExpectQuery(`FROM patients WHERE id=\$1 AND .*g.clinician_id = \$2 AND g.expires_at > now\(\)`).
+		WithArgs("42", int64(7)).
+		WillReturnRows(sqlmock.NewRows(patientColumns).AddRow(42, "A Patient", nil, "f", "MRN42", time.Now(), time.Now(), nil, nil, true, false))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/42")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"restricted":true`) {
+		t.Errorf("body = %s, want restricted flag", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestrictedPatientRecordsAreForbidden(t *testing.T) {
+	for _, tt := range []struct{ path, lookup, restricted string }{
+		{"/v1/images/5", `FROM assessment_images WHERE id=\$1 AND .*break_glass_grants g`, `FROM assessment_images i`},
+		{"/v1/images/5/thumbnail", `FROM assessment_images WHERE id=\$1 AND .*break_glass_grants g`, `FROM assessment_images i`},
+		{"/v1/treatments/5", `FROM treatments WHERE id=\$1 AND .*break_glass_grants g`, `FROM treatments t`},
+		{"/v1/care-plans/5", `FROM care_plans p WHERE p.id = \$1 AND .*break_glass_grants g`, `FROM care_plans cp`},
+		{"/v1/braden/5", `FROM braden_assessments WHERE id=\$1 AND .*break_glass_grants g`, `FROM braden_assessments b`},
+		{"/v1/wounds/5/trajectory", `FROM wounds WHERE wounds.id = \$1 AND .*break_glass_grants g`, `FROM wounds w JOIN patients`},
+	} {
+		r, mock := scopedRouter(t, "physician")
+		mock.ExpectQuery(tt.lookup).WithArgs("5", int64(7)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(tt.restricted).WithArgs("5").
+			WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(true))
+
+		w := serveAs(t, r, http.MethodGet, tt.path)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"restricted":true`) {
+			t.Fatalf("%s: status = %d, want 403: %s", tt.path, w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRestrictedPatientWritesAreForbidden(t *testing.T) {
+	t.Run("update patient", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE patients SET .*break_glass_grants g`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT restricted FROM patients WHERE id = \$1`).WithArgs("42").
+			WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(true))
+		mock.ExpectRollback()
+
+		w := sendJSON(t, r, http.MethodPut, "/v1/patients/42", `{"full_name":"A Patient"}`)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"restricted":true`) {
+			t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("delete assessment", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		mock.ExpectQuery(`DELETE FROM assessments WHERE id = \$1 AND .*break_glass_grants g.* RETURNING patient_id`).
+			WithArgs("5", int64(7)).WillReturnRows(sqlmock.NewRows([]string{"patient_id"}))
+		mock.ExpectQuery(`FROM assessments a JOIN patients`).WithArgs("5").
+			WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(true))
+
+		w := serveAs(t, r, http.MethodDelete, "/v1/assessments/5")
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"restricted":true`) {
+			t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestRestrictedPatientImagesHiddenFromAPIKeys(t *testing.T) {
+	key, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRBACRouter(t, func(m sqlmock.Sqlmock) {
+		apiKeyRow(key, `["images:read"]`, nil, nil)(m)
+		m.ExpectQuery(`FROM assessment_images WHERE id=\$1 AND NOT EXISTS \(SELECT 1 FROM patients rp`).WithArgs("5").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		m.ExpectQuery(`FROM assessment_images i`).WithArgs("5").
+			WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(true))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/images/5", nil)
+	req.Header.Set(apikey.Header, key.Plaintext)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+	}
+}
+
+func TestBreakGlass(t *testing.T) {
+	reason := "patient unconscious in ED, treating wound"
+	expires := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		body   string
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"granted", `{"reason":"` + reason + `"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`INSERT INTO break_glass_grants .* FROM patients WHERE id = \$1`).
+				WithArgs(int64(42), int64(7), reason, int64(3600)).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "expires_at"}).AddRow(3, expires))
+		}, http.StatusCreated},
+		{"unknown patient", `{"reason":"` + reason + `"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`INSERT INTO break_glass_grants`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "expires_at"}))
+		}, http.StatusNotFound},
+		{"reason required", `{}`, nil, http.StatusBadRequest},
+		{"reason too short", `{"reason":"urgent"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			w := sendJSON(t, r, http.MethodPost, "/v1/patients/42/break-glass", tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBreakGlassGrantCannotChangeCareTeam(t *testing.T) {
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodPost, "/v1/patients/42/care-team", `{"clinician_id":7}`},
+		{http.MethodDelete, "/v1/patients/42/care-team/9", ``},
+	} {
+		r, mock := scopedRouter(t, "nurse")
+		// The grant makes the patient visible...
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1 AND .*break_glass_grants g`).
+			WithArgs("42", int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		// ...but the caller is not on the team.
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM care_team_assignments ct\s+WHERE ct.patient_id = \$1 AND ct.clinician_id = \$2\)`).
+			WithArgs("42", int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		w := sendJSON(t, r, tt.method, tt.path, tt.body)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: status = %d, want 403: %s", tt.method, tt.path, w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBreakGlassRefusesAPIKeys(t *testing.T) {
+	key, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRBACRouter(t, apiKeyRow(key, `["patients:write"]`, nil, nil))
+	req := httptest.NewRequest(http.MethodPost, "/v1/patients/42/break-glass",
+		strings.NewReader(`{"reason":"integration wants to read this"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apikey.Header, key.Plaintext)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "clinician account") {
+		t.Fatalf("status = %d, want 403 from the handler: %s", w.Code, w.Body)
+	}
+}
+
+func TestReviewBreakGlassGrants(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`FROM break_glass_grants WHERE reviewed_at IS NULL ORDER BY granted_at DESC, id DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id", "clinician_id", "reason", "granted_at", "expires_at", "active",
+			"reviewed_by", "reviewed_at", "review_note"}).
+			AddRow(3, 42, 8, "patient unconscious in ED", time.Now(), time.Now().Add(time.Hour), true, nil, nil, ""))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/break-glass?reviewed=false")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"active":true`) {
+		t.Errorf("list body = %s", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	r, mock = scopedRouter(t, "admin")
+	mock.ExpectQuery(`UPDATE break_glass_grants SET reviewed_by = \$2, .*LEAST\(expires_at, now\(\)\)`).
+		WithArgs("3", int64(7), "not an emergency", true).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(42))
+	if w := sendJSON(t, r, http.MethodPost, "/v1/break-glass/3/review", `{"note":"not an emergency","revoke":true}`); w.Code != http.StatusNoContent {
+		t.Fatalf("review: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	r, _ = scopedRouter(t, "admin")
+	if w := serveAs(t, r, http.MethodGet, "/v1/break-glass?active=maybe"); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad filter: status = %d, want 400", w.Code)
+	}
+	r, _ = scopedRouter(t, "physician")
+	if w := serveAs(t, r, http.MethodGet, "/v1/break-glass"); w.Code != http.StatusForbidden {
+		t.Fatalf("physician: status = %d, want 403", w.Code)
+	}
+}
+
+func TestListPatientsWithholdsRestrictedDetails(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`FROM patients WHERE EXISTS`).
+		WithArgs(20, 0, int64(7)).
+		WillReturnRows(sqlmock.NewRows(patientColumns).
+			AddRow(1, "Open Patient", nil, "f", "MRN1", time.Now(), time.Now(), nil, nil, false, false).
+			AddRow(2, "Restricted Patient", time.Now(), "m", "MRN2", time.Now(), time.Now(), nil, nil, true, false))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "MRN1") || strings.Contains(body, "MRN2") || !strings.Contains(body, "Restricted Patient") {
+		t.Errorf("body = %s, want the restricted patient listed by name only", body)
+	}
+}
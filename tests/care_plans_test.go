@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var carePlanColumns = []string{"id", "patient_id", "responsible_clinician_id", "title", "goals", "interventions",
+	"review_date", "status", "version", "created_at", "updated_at", "wound_ids", "assessment_ids"}
+
+// snapshotOf matches a care plan snapshot argument by version and title.
+type snapshotOf struct {
+	version int
+	title   string
+}
+
+func (s snapshotOf) Match(v driver.Value) bool {
+	str, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var snap struct {
+		Version int    `json:"version"`
+		Title   string `json:"title"`
+	}
+	return json.Unmarshal([]byte(str), &snap) == nil && snap.Version == s.version && snap.Title == s.title
+}
+
+func TestUpdateCarePlanKeepsPreviousVersion(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	review := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM care_plans WHERE id = \$1 AND .* FOR UPDATE`).WithArgs("5", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`FROM care_plans p WHERE p.id = \$1`).WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(carePlanColumns).
+			AddRow(5, 3, 7, "Offload sacrum", `[]`, `["turn every 2h"]`, review, "active", 2, time.Now(), time.Now(), `[9]`, `[]`))
+	mock.ExpectExec(`INSERT INTO care_plan_versions \(care_plan_id, version, snapshot, created_at\)`).
+		WithArgs(int64(5), 2, snapshotOf{version: 2, title: "Offload sacrum"}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE care_plans SET .* version = version \+ 1`).
+		WithArgs(int64(7), "Offload sacrum and heels", `[]`, `["turn every 2h"]`, review, "active", int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := sendJSON(t, r, http.MethodPut, "/v1/care-plans/5", `{"title":"Offload sacrum and heels"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Version != 3 {
+		t.Errorf("body = %s, want version 3", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCarePlanRejectsAnotherPatientsWound(t *testing.T) {
+	const links = `SELECT \(SELECT count\(\*\) FROM wounds WHERE patient_id = \$1 AND id = ANY\(\$2\)\)`
+	t.Run("create", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		expectPatientVisible(mock, int64(3))
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM clinicians WHERE id = \$1\)`).WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectBegin()
+		mock.ExpectQuery(links).WithArgs(int64(3), []int64{42}, []int64{}).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+		mock.ExpectRollback()
+
+		w := sendJSON(t, r, http.MethodPost, "/v1/patients/3/care-plans",
+			`{"responsible_clinician_id":7,"title":"Offload sacrum","review_date":"2026-11-01T00:00:00Z","wound_ids":[42]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM care_plans WHERE id = \$1 AND .* FOR UPDATE`).WithArgs("5", int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery(`FROM care_plans p WHERE p.id = \$1`).WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows(carePlanColumns).
+				AddRow(5, 3, 7, "Offload sacrum", `[]`, `[]`, time.Now(), "active", 1, time.Now(), time.Now(), `[]`, `[]`))
+		mock.ExpectQuery(links).WithArgs(int64(3), []int64{42}, []int64{}).
+			WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+		mock.ExpectRollback()
+
+		if w := sendJSON(t, r, http.MethodPut, "/v1/care-plans/5", `{"wound_ids":[42]}`); w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestCarePlanRejectsUnknownClinician(t *testing.T) {
+	const clinician = `SELECT EXISTS \(SELECT 1 FROM clinicians WHERE id = \$1\)`
+	t.Run("create", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		expectPatientVisible(mock, int64(3))
+		mock.ExpectQuery(clinician).WithArgs(int64(99)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		w := sendJSON(t, r, http.MethodPost, "/v1/patients/3/care-plans",
+			`{"responsible_clinician_id":99,"title":"Offload sacrum","review_date":"2026-11-01T00:00:00Z"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("update", func(t *testing.T) {
+		r, mock := scopedRouter(t, "physician")
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM care_plans WHERE id = \$1 AND .* FOR UPDATE`).WithArgs("5", int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery(`FROM care_plans p WHERE p.id = \$1`).WithArgs(int64(5)).
+			WillReturnRows(sqlmock.NewRows(carePlanColumns).
+				AddRow(5, 3, 7, "Offload sacrum", `[]`, `[]`, time.Now(), "active", 1, time.Now(), time.Now(), `[]`, `[]`))
+		mock.ExpectQuery(clinician).WithArgs(int64(99)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		if w := sendJSON(t, r, http.MethodPut, "/v1/care-plans/5", `{"responsible_clinician_id":99}`); w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestUpdateCarePlanWithoutChangesKeepsVersion(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	review := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM care_plans WHERE id = \$1 AND .* FOR UPDATE`).WithArgs("5", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`FROM care_plans p WHERE p.id = \$1`).WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(carePlanColumns).
+			AddRow(5, 3, 7, "Offload sacrum", `[]`, `["turn every 2h"]`, review, "active", 2, time.Now(), time.Now(), `[4,9]`, `[]`))
+	mock.ExpectQuery(`SELECT \(SELECT count\(\*\) FROM wounds`).WithArgs(int64(3), []int64{9, 4}, []int64{}).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w := sendJSON(t, r, http.MethodPut, "/v1/care-plans/5",
+		`{"responsible_clinician_id":7,"title":"Offload sacrum","review_date":"2026-11-01T01:00:00+01:00","wound_ids":[9,4]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Version != 2 {
+		t.Errorf("body = %s, want version 2", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
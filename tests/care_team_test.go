@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
+)
+
+var patientColumns = []string{"id", "full_name", "date_of_birth", "gender", "medical_record_number", "created_at", "updated_at", "mrn_encrypted", "dob_encrypted", "restricted", "research_enrolled"}
+
+// scopedRouter returns the router and its sqlmock with the caller resolved
+// to clinician 7 holding role.
+func scopedRouter(t *testing.T, role string) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	return scopedRouterWithConfig(t, role, &config.Config{})
+}
+
+// pgArrays passes the ID slices handlers bind to ANY($n) through as pgx
+// does; database/sql's default converter rejects them.
+type pgArrays struct{}
+
+func (pgArrays) ConvertValue(v interface{}) (driver.Value, error) {
+	if ids, ok := v.([]int64); ok {
+		return ids, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func scopedRouterWithConfig(t *testing.T, role string, cfg *config.Config) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	return scopedRouterWithStore(t, role, cfg, nil)
+}
+
+// scopedRouterWithStore is scopedRouterWithConfig with an image store.
+func scopedRouterWithStore(t *testing.T, role string, cfg *config.Config, store storage.Store) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(pgArrays{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	mock.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, role))
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := router.New(db, zap.NewNop(), cfg, store, v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, mock
+}
+
+func serveAs(t *testing.T, r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, validClaims()))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestListPatientsScopedToCareTeam(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`FROM patients WHERE EXISTS \(SELECT 1 FROM care_team_assignments ct\s+WHERE ct.patient_id = patients.id AND ct.clinician_id = \$3\) ORDER BY id DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(20, 0, int64(7)).
+		WillReturnRows(sqlmock.NewRows(patientColumns).AddRow(1, "A Patient", nil, "f", "MRN1", time.Now(), time.Now(), nil, nil, false, false))
+
+	if w := serveAs(t, r, http.MethodGet, "/v1/patients"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListPatientsAdminUnscoped(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`FROM patients ORDER BY id DESC LIMIT \$1 OFFSET \$2`).
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows(patientColumns))
+
+	if w := serveAs(t, r, http.MethodGet, "/v1/patients"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatientOutsideCareTeamIsNotFound(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1 AND \(\(EXISTS \(SELECT 1 FROM care_team_assignments ct`).
+		WithArgs("42", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT restricted FROM patients WHERE id = \$1`).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(false))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/42/history")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordsOutsideCareTeamAreNotFound(t *testing.T) {
+	const (
+		patientCheck = `SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1 AND .*ct.clinician_id`
+		woundCheck   = `SELECT wounds.patient_id FROM wounds WHERE wounds.id = \$1 AND .*ct.clinician_id`
+	)
+	starts := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	ends := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	for _, tt := range []struct {
+		method, path, body string
+		tx                 bool
+		lookup             string // the scoped query, which finds nothing
+		restricted         string
+	}{
+		{method: http.MethodGet, path: "/v1/images/5", lookup: `FROM assessment_images WHERE id=\$1 AND .*ct.clinician_id`, restricted: `FROM assessment_images i`},
+		{method: http.MethodGet, path: "/v1/images/5/thumbnail", lookup: `FROM assessment_images WHERE id=\$1 AND .*ct.clinician_id`, restricted: `FROM assessment_images i`},
+		{method: http.MethodDelete, path: "/v1/images/5", lookup: `DELETE FROM assessment_images WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM assessment_images i`},
+		{method: http.MethodPost, path: "/v1/assessments/5/treatments", body: `{}`, lookup: `FROM assessments WHERE id = \$1 AND .*ct.clinician_id`, restricted: `FROM assessments a JOIN patients`},
+		{method: http.MethodGet, path: "/v1/treatments/5", lookup: `FROM treatments WHERE id=\$1 AND .*ct.clinician_id`, restricted: `FROM treatments t`},
+		{method: http.MethodPut, path: "/v1/treatments/5", body: `{}`, lookup: `UPDATE treatments SET .* WHERE id = \$7 AND .*ct.clinician_id = \$8.* RETURNING`, restricted: `FROM treatments t`},
+		{method: http.MethodDelete, path: "/v1/treatments/5", lookup: `DELETE FROM treatments WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM treatments t`},
+		{method: http.MethodGet, path: "/v1/wounds/5/treatments/current", lookup: woundCheck, restricted: `FROM wounds w JOIN patients`},
+		{method: http.MethodGet, path: "/v1/care-plans/5", lookup: `FROM care_plans p WHERE p.id = \$1 AND .*ct.clinician_id`, restricted: `FROM care_plans cp`},
+		{method: http.MethodPost, path: "/v1/patients/5/care-plans", body: `{"responsible_clinician_id":7,"title":"Offload heels","review_date":"` + starts + `"}`, lookup: patientCheck, restricted: `SELECT restricted FROM patients`},
+		{method: http.MethodPut, path: "/v1/care-plans/5", body: `{}`, tx: true, lookup: `SELECT id FROM care_plans WHERE id = \$1 AND .*ct.clinician_id.* FOR UPDATE`, restricted: `FROM care_plans cp`},
+		{method: http.MethodGet, path: "/v1/care-plans/5/versions", lookup: `SELECT care_plans.patient_id FROM care_plans WHERE care_plans.id = \$1 AND .*ct.clinician_id`, restricted: `FROM care_plans cp`},
+		{method: http.MethodDelete, path: "/v1/care-plans/5", lookup: `DELETE FROM care_plans WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM care_plans cp`},
+		{method: http.MethodPost, path: "/v1/patients/5/braden", body: `{"clinician_id":7,"sensory_perception":3,"moisture":3,"activity":3,"mobility":3,"nutrition":3,"friction_shear":2}`, lookup: patientCheck, restricted: `SELECT restricted FROM patients`},
+		{method: http.MethodGet, path: "/v1/braden/5", lookup: `FROM braden_assessments WHERE id=\$1 AND .*ct.clinician_id`, restricted: `FROM braden_assessments b`},
+		{method: http.MethodDelete, path: "/v1/braden/5", lookup: `DELETE FROM braden_assessments WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM braden_assessments b`},
+		{method: http.MethodPost, path: "/v1/wounds", body: `{"patient_id":5,"anatomical_location":"sacrum"}`, lookup: patientCheck, restricted: `SELECT restricted FROM patients`},
+		{method: http.MethodPut, path: "/v1/wounds/5", body: `{}`, lookup: `UPDATE wounds SET .* WHERE id = \$6 AND .*ct.clinician_id = \$7.* RETURNING`, restricted: `FROM wounds w JOIN patients`},
+		{method: http.MethodDelete, path: "/v1/wounds/5", lookup: `DELETE FROM wounds WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM wounds w JOIN patients`},
+		{method: http.MethodGet, path: "/v1/wounds/5/measurements", lookup: woundCheck, restricted: `FROM wounds w JOIN patients`},
+		{method: http.MethodGet, path: "/v1/wounds/5/push-scores", lookup: woundCheck, restricted: `FROM wounds w JOIN patients`},
+		{method: http.MethodGet, path: "/v1/appointments/5", lookup: `FROM appointments WHERE id=\$1 AND .*ct.clinician_id`, restricted: `FROM appointments ap`},
+		{method: http.MethodPost, path: "/v1/appointments", body: `{"patient_id":5,"clinician_id":7,"starts_at":"` + starts + `","ends_at":"` + ends + `","visit_type":"follow_up"}`, lookup: patientCheck, restricted: `SELECT restricted FROM patients`},
+		{method: http.MethodPut, path: "/v1/appointments/5", body: `{}`, tx: true, lookup: `FROM appointments WHERE id=\$1 AND .*ct.clinician_id.* FOR UPDATE`, restricted: `FROM appointments ap`},
+		{method: http.MethodDelete, path: "/v1/appointments/5", lookup: `DELETE FROM appointments WHERE id = \$1 AND .*ct.clinician_id.* RETURNING`, restricted: `FROM appointments ap`},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r, mock := scopedRouter(t, "physician")
+			if tt.tx {
+				mock.ExpectBegin()
+			}
+			if tt.lookup == patientCheck {
+				mock.ExpectQuery(tt.lookup).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			} else {
+				mock.ExpectQuery(tt.lookup).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+			mock.ExpectQuery(tt.restricted).WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(false))
+			if tt.tx {
+				mock.ExpectRollback()
+			}
+
+			w := sendJSON(t, r, tt.method, tt.path, tt.body)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCarePlanAndScheduleListsScopedToCareTeam(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`FROM care_plans p WHERE p.status = 'active' AND p.review_date < CURRENT_DATE AND .*ct.clinician_id = \$1.* LIMIT \$2 OFFSET \$3`).
+		WithArgs(int64(7), 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if w := serveAs(t, r, http.MethodGet, "/v1/care-plans/overdue"); w.Code != http.StatusOK {
+		t.Fatalf("overdue: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	r, mock = scopedRouter(t, "nurse")
+	mock.ExpectQuery(`FROM appointments\s+WHERE clinician_id = \$1 AND .*ct.clinician_id = \$4`).
+		WithArgs("3", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if w := serveAs(t, r, http.MethodGet, "/v1/clinicians/3/schedule"); w.Code != http.StatusOK {
+		t.Fatalf("schedule: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const consentStatusQuery = `SELECT CASE WHEN revoked_at IS NOT NULL THEN 'revoked'.* FROM patient_consents\s+WHERE patient_id = \$1 AND consent_type = \$2`
+
+var consentColumns = []string{"id", "patient_id", "consent_type", "status", "scope", "signed_by", "signer_relationship",
+	"document_ref", "granted_at", "expires_at", "revoked_at", "revoked_reason", "recorded_by", "created_at"}
+
+func expectPatientVisible(mock sqlmock.Sqlmock, id interface{}) {
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1`).
+		WithArgs(id, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+}
+
+func TestRecordConsent(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"recorded", `{"consent_type":"photography","scope":"wound photos","signed_by":"Pat Doe","document_ref":"scan-17"}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(5))
+			m.ExpectQuery(`INSERT INTO patient_consents`).
+				WithArgs(int64(5), "photography", "wound photos", "Pat Doe", "self", "scan-17", sqlmock.AnyArg(), nil, int64(7)).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		}, http.StatusCreated},
+		{"unknown type", `{"consent_type":"marketing","signed_by":"Pat Doe"}`, nil, http.StatusBadRequest},
+		{"signer required", `{"consent_type":"research"}`, nil, http.StatusBadRequest},
+		{"bad relationship", `{"consent_type":"research","signed_by":"Pat Doe","signer_relationship":"neighbour"}`, nil, http.StatusBadRequest},
+		{"expires before granted", `{"consent_type":"research","signed_by":"Pat Doe","granted_at":"2026-01-02T00:00:00Z","expires_at":"2026-01-01T00:00:00Z"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			if w := sendJSON(t, r, http.MethodPost, "/v1/patients/5/consents", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func expectEnrolment(mock sqlmock.Sqlmock, id interface{}, enrolled bool) {
+	mock.ExpectQuery(`SELECT research_enrolled FROM patients WHERE id = \$1`).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"research_enrolled"}).AddRow(enrolled))
+}
+
+func TestImageUploadChecksAccessBeforeConsent(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`SELECT id, patient_id FROM assessments WHERE id=\$1 AND`).WithArgs("3", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id"}))
+	mock.ExpectQuery(`SELECT p.restricted FROM assessments a JOIN patients p`).WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"restricted"}).AddRow(false))
+
+	if w := serveAs(t, r, http.MethodPost, "/v1/assessments/3/images"); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAssessmentOfEnrolledPatientRequiresResearchConsent(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectBegin()
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), true)
+	mock.ExpectQuery(consentStatusQuery).WithArgs(int64(5), "research").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("expired"))
+	mock.ExpectRollback()
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/assessments", `{"patient_id":5,"clinician_id":7}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMovingAssessmentToEnrolledPatientRequiresResearchConsent(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`SELECT a.patient_id FROM assessments a WHERE a.id = \$1 AND`).WithArgs("9", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(3))
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), true)
+	mock.ExpectQuery(consentStatusQuery).WithArgs(int64(5), "research").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("revoked"))
+
+	w := sendJSON(t, r, http.MethodPut, "/v1/assessments/9", `{"patient_id":5}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImageUploadRequiresPhotographyConsent(t *testing.T) {
+	for _, status := range []string{"revoked", "expired", ""} {
+		r, mock := scopedRouter(t, "nurse")
+		mock.ExpectQuery(`SELECT id, patient_id FROM assessments WHERE id=\$1 AND`).WithArgs("3", int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id"}).AddRow(3, 5))
+		rows := sqlmock.NewRows([]string{"status"})
+		if status != "" {
+			rows.AddRow(status)
+		}
+		mock.ExpectQuery(consentStatusQuery).WithArgs(int64(5), "photography").WillReturnRows(rows)
+
+		w := serveAs(t, r, http.MethodPost, "/v1/assessments/3/images")
+		if w.Code != http.StatusConflict {
+			t.Fatalf("%q: status = %d, want 409: %s", status, w.Code, w.Body)
+		}
+		var body struct {
+			ConsentType   string `json:"consent_type"`
+			ConsentStatus string `json:"consent_status"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		want := status
+		if want == "" {
+			want = "missing"
+		}
+		if body.ConsentType != "photography" || body.ConsentStatus != want {
+			t.Errorf("%q: body = %s", status, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFullAssessmentImagesRequirePhotographyConsent(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), false)
+	mock.ExpectQuery(consentStatusQuery).WithArgs(int64(5), "photography").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	body := `{"assessment":{"patient_id":5,"clinician_id":7},"image_references":[{"uri":"https://pacs.example.org/1"}]}`
+	if w := sendJSON(t, r, http.MethodPost, "/v1/assessments/full", body); w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResearchEnrolmentRequiresConsent(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectBegin()
+	expectPatientVisible(mock, "5")
+	mock.ExpectQuery(consentStatusQuery+`.* FOR SHARE`).WithArgs("5", "research").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("revoked"))
+	mock.ExpectRollback()
+	if w := sendJSON(t, r, http.MethodPut, "/v1/patients/5", `{"research_enrolled":true}`); w.Code != http.StatusConflict {
+		t.Fatalf("revoked: status = %d, want 409: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	r, mock = scopedRouter(t, "nurse")
+	mock.ExpectBegin()
+	expectPatientVisible(mock, "5")
+	mock.ExpectQuery(consentStatusQuery+`.* FOR SHARE`).WithArgs("5", "research").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("granted"))
+	mock.ExpectExec(`UPDATE patients SET .*research_enrolled = COALESCE\(\$9, research_enrolled\)`).
+		WithArgs(nil, nil, nil, nil, "5", nil, nil, nil, true, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if w := sendJSON(t, r, http.MethodPut, "/v1/patients/5", `{"research_enrolled":true}`); w.Code != http.StatusNoContent {
+		t.Fatalf("granted: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRevokeResearchConsentEndsEnrolment(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`SELECT patient_id, consent_type FROM patient_consents WHERE id = \$1`).WithArgs("4").
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id", "consent_type"}).AddRow(5, "research"))
+	expectPatientVisible(mock, int64(5))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE patient_consents SET revoked_at = now\(\)`).WithArgs("4", "withdrew at clinic").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE patients SET research_enrolled = false`).WithArgs(int64(5), "research").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if w := sendJSON(t, r, http.MethodPost, "/v1/consents/4/revoke", `{"reason":"withdrew at clinic"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatientHistoryIncludesConsents(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT get_patient_wound_history\(\$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"history"}).AddRow(`{"patient_id":5}`))
+	mock.ExpectQuery(`FROM braden_assessments`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	revoked := time.Now()
+	mock.ExpectQuery(`FROM patient_consents WHERE patient_id = \$1 ORDER BY granted_at DESC, id DESC`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(consentColumns).
+			AddRow(12, 5, "research", "revoked", "", "Pat Doe", "self", "", time.Now().Add(-time.Hour), nil, revoked, "withdrew", 7, time.Now()).
+			AddRow(11, 5, "photography", "granted", "wound photos", "Pat Doe", "self", "scan-17", time.Now().Add(-2*time.Hour), nil, nil, "", nil, time.Now()))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/5/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		PatientID int `json:"patient_id"`
+		Consents  []struct {
+			ConsentType string `json:"consent_type"`
+			Status      string `json:"status"`
+		} `json:"consents"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.PatientID != 5 || len(out.Consents) != 2 || out.Consents[0].Status != "revoked" || out.Consents[1].ConsentType != "photography" {
+		t.Errorf("history = %s", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+var bradenColumns = []string{"id", "patient_id", "clinician_id", "sensory_perception", "moisture", "activity", "mobility",
+	"nutrition", "friction_shear", "total", "risk_band", "assessed_at", "created_at"}
+
+func TestPatientHistoryWrapsNonObjectDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		history interface{}
+		want    string
+	}{
+		{"json null", `null`, `null`},
+		{"array", `[{"wound_id":1}]`, `[{"wound_id":1}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "admin")
+			mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1\)`).WithArgs("5").
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(`SELECT get_patient_wound_history\(\$1\)`).WithArgs("5").
+				WillReturnRows(sqlmock.NewRows([]string{"history"}).AddRow(tt.history))
+			mock.ExpectQuery(`FROM braden_assessments`).WithArgs("5").
+				WillReturnRows(sqlmock.NewRows(bradenColumns).
+					AddRow(4, 5, 7, 2, 2, 2, 3, 2, 2, 13, "moderate", time.Now(), time.Now()))
+			mock.ExpectQuery(`FROM patient_consents WHERE patient_id = \$1`).WithArgs("5").
+				WillReturnRows(sqlmock.NewRows(consentColumns))
+
+			w := serveAs(t, r, http.MethodGet, "/v1/patients/5/history")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var out struct {
+				History json.RawMessage `json:"history"`
+				Braden  []struct {
+					ID    int `json:"id"`
+					Total int `json:"total"`
+				} `json:"braden_assessments"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if string(out.History) != tt.want || len(out.Braden) != 1 || out.Braden[0].Total != 13 {
+				t.Errorf("history = %s", w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPatientHistoryKeepsConsentsWithoutHistory(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT get_patient_wound_history\(\$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"history"}).AddRow(nil))
+	mock.ExpectQuery(`FROM braden_assessments`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(bradenColumns))
+	mock.ExpectQuery(`FROM patient_consents WHERE patient_id = \$1`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(consentColumns).
+			AddRow(11, 5, "photography", "granted", "wound photos", "Pat Doe", "self", "scan-17", time.Now().Add(-time.Hour), nil, nil, "", nil, time.Now()))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/5/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		History  json.RawMessage `json:"history"`
+		Consents []struct {
+			ConsentType string `json:"consent_type"`
+		} `json:"consents"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if string(out.History) != "null" || len(out.Consents) != 1 || out.Consents[0].ConsentType != "photography" {
+		t.Errorf("history = %s", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
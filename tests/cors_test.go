@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/cors"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/logger"
+)
+
+const portal = "https://portal.example.org"
+
+func corsRequest(r *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/v1/patients", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	preflight := func(method, headers string) map[string]string {
+		return map[string]string{"Access-Control-Request-Method": method, "Access-Control-Request-Headers": headers}
+	}
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		method      string
+		origin      string
+		headers     map[string]string
+		status      int
+		allowOrigin string
+		credentials string
+		maxAge      string
+	}{
+		{"no origin", config.Config{}, http.MethodGet, "", nil, http.StatusOK, "", "", ""},
+		{"nothing allowed", config.Config{}, http.MethodGet, portal, nil, http.StatusForbidden, "", "", ""},
+		{"allowed origin", config.Config{CORSAllowedOrigins: []string{portal}}, http.MethodGet, portal, nil, http.StatusOK, portal, "", ""},
+		{"origin compared case-insensitively", config.Config{CORSAllowedOrigins: []string{portal + "/"}}, http.MethodGet, "https://Portal.example.org", nil, http.StatusOK, "https://Portal.example.org", "", ""},
+		{"other origin", config.Config{CORSAllowedOrigins: []string{portal}}, http.MethodPost, "https://evil.example", nil, http.StatusForbidden, "", "", ""},
+		{"credentials", config.Config{CORSAllowedOrigins: []string{portal}, CORSAllowCredentials: true}, http.MethodGet, portal, nil, http.StatusOK, portal, "true", ""},
+		{"wildcard", config.Config{CORSAllowedOrigins: []string{"*"}}, http.MethodGet, "http://localhost:3000", nil, http.StatusOK, "*", "", ""},
+		{"preflight", config.Config{CORSAllowedOrigins: []string{portal}, CORSAllowCredentials: true, CORSMaxAge: 10 * time.Minute},
+			http.MethodOptions, portal, preflight("PUT", "Authorization, content-type"), http.StatusNoContent, portal, "true", "600"},
+		{"preflight method", config.Config{CORSAllowedOrigins: []string{portal}, CORSAllowedMethods: []string{"GET"}},
+			http.MethodOptions, portal, preflight("DELETE", ""), http.StatusForbidden, "", "", ""},
+		{"preflight header", config.Config{CORSAllowedOrigins: []string{portal}},
+			http.MethodOptions, portal, preflight("GET", "X-Custom"), http.StatusForbidden, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, logs := observedLogger(logger.DefaultRedactFields)
+			r := gin.New()
+			r.Use(cors.Middleware(cors.FromConfig(&tt.cfg), log))
+			r.GET("/v1/patients", func(c *gin.Context) { c.Status(http.StatusOK) })
+			r.POST("/v1/patients", func(c *gin.Context) { t.Error("handler ran for a refused origin") })
+
+			w := corsRequest(r, tt.method, tt.origin, tt.headers)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			h := w.Header()
+			if h.Get("Access-Control-Allow-Origin") != tt.allowOrigin || h.Get("Access-Control-Allow-Credentials") != tt.credentials ||
+				h.Get("Access-Control-Max-Age") != tt.maxAge {
+				t.Errorf("headers = %v", h)
+			}
+			if h.Values("Vary")[0] != "Origin" {
+				t.Errorf("Vary = %v", h.Values("Vary"))
+			}
+			if rejected := logs.FilterMessage("cors request rejected").Len(); (rejected > 0) != (tt.status == http.StatusForbidden) {
+				t.Errorf("%d rejections logged", rejected)
+			}
+		})
+	}
+}
+
+// Preflights carry no credentials, so they are answered before /v1 auth.
+func TestCORSPreflightBeforeAuth(t *testing.T) {
+	r, _ := scopedRouterWithConfig(t, "nurse", &config.Config{CORSAllowedOrigins: []string{portal}})
+	w := corsRequest(r, http.MethodOptions, portal, map[string]string{"Access-Control-Request-Method": "POST"})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != portal {
+		t.Fatalf("status = %d, headers = %v", w.Code, w.Header())
+	}
+}
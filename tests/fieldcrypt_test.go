@@ -0,0 +1,287 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/fieldcrypt"
+)
+
+var (
+	fieldKeyA  = bytes.Repeat([]byte{1}, 32)
+	fieldKeyB  = bytes.Repeat([]byte{2}, 32)
+	indexKey   = bytes.Repeat([]byte{3}, 32)
+	fieldCfgV1 = &config.Config{FieldKeys: map[string][]byte{"k1": fieldKeyA}, FieldCurrentKey: "k1", FieldIndexKey: indexKey}
+)
+
+func TestFieldCryptRoundTrip(t *testing.T) {
+	ring, err := fieldcrypt.FromConfig(fieldCfgV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ring.Encrypt(fieldcrypt.PatientMRN, 5, "MRN-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "MRN-1234") || fieldcrypt.KeyID(sealed) != "k1" {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if again, _ := ring.Encrypt(fieldcrypt.PatientMRN, 5, "MRN-1234"); again == sealed {
+		t.Error("encryption is deterministic")
+	}
+	if got, err := ring.Decrypt(fieldcrypt.PatientMRN, 5, sealed); err != nil || got != "MRN-1234" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	if _, err := ring.Decrypt(fieldcrypt.AssessmentNotes, 5, sealed); err == nil {
+		t.Error("value decrypted under another column")
+	}
+	if _, err := ring.Decrypt(fieldcrypt.PatientMRN, 6, sealed); err == nil {
+		t.Error("value decrypted under another row")
+	}
+	i := len(sealed) - 10
+	flip := byte('A')
+	if sealed[i] == 'A' {
+		flip = 'B'
+	}
+	tampered := sealed[:i] + string(flip) + sealed[i+1:]
+	if _, err := ring.Decrypt(fieldcrypt.PatientMRN, 5, tampered); err == nil {
+		t.Error("tampered value decrypted")
+	}
+	if _, err := ring.Decrypt(fieldcrypt.PatientMRN, 5, "MRN-1234"); !errors.Is(err, fieldcrypt.ErrMalformed) {
+		t.Errorf("plaintext: err = %v", err)
+	}
+	if fieldcrypt.KeyID("MRN-1234") != "" {
+		t.Error("KeyID of plaintext")
+	}
+}
+
+func TestFieldCryptRotation(t *testing.T) {
+	old, _ := fieldcrypt.NewKeyRing(map[string][]byte{"k1": fieldKeyA}, "k1", indexKey)
+	rotated, err := fieldcrypt.NewKeyRing(map[string][]byte{"k1": fieldKeyA, "k2": fieldKeyB}, "k2", indexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := old.Encrypt(fieldcrypt.AssessmentNotes, 3, "stage 2, sacrum")
+	if got, err := rotated.Decrypt(fieldcrypt.AssessmentNotes, 3, sealed); err != nil || got != "stage 2, sacrum" {
+		t.Fatalf("old value after rotation = %q, %v", got, err)
+	}
+	fresh, _ := rotated.Encrypt(fieldcrypt.AssessmentNotes, 3, "x")
+	if fieldcrypt.KeyID(fresh) != "k2" {
+		t.Errorf("new value sealed with %q", fieldcrypt.KeyID(fresh))
+	}
+	if _, err := old.Decrypt(fieldcrypt.AssessmentNotes, 3, fresh); !errors.Is(err, fieldcrypt.ErrUnknownKey) {
+		t.Errorf("retired ring: err = %v", err)
+	}
+
+	if old.BlindIndex(fieldcrypt.PatientMRN, " mrn-1 ") != rotated.BlindIndex(fieldcrypt.PatientMRN, "MRN-1") {
+		t.Error("blind index changes with rotation or formatting")
+	}
+	if old.BlindIndex(fieldcrypt.PatientMRN, "MRN-1") == old.BlindIndex(fieldcrypt.PatientMRN, "MRN-2") {
+		t.Error("blind index collides")
+	}
+}
+
+func TestListPatientsByEncryptedMRN(t *testing.T) {
+	ring, _ := fieldcrypt.FromConfig(fieldCfgV1)
+	mrn, _ := ring.Encrypt(fieldcrypt.PatientMRN, 1, "MRN-77")
+	dob, _ := ring.Encrypt(fieldcrypt.PatientDOB, 1, "1950-04-02")
+
+	r, mock := scopedRouterWithConfig(t, "admin", fieldCfgV1)
+	mock.ExpectQuery(`FROM patients WHERE \(mrn_index = \$3 OR medical_record_number = \$4\) ORDER BY id DESC`).
+		WithArgs(20, 0, ring.BlindIndex(fieldcrypt.PatientMRN, "MRN-77"), "mrn-77").
+		WillReturnRows(sqlmock.NewRows(patientColumns).AddRow(1, "A Patient", nil, "f", nil, time.Now(), time.Now(), mrn, dob, false, false))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients?mrn=mrn-77")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Data []struct {
+			MRN string    `json:"medical_record_number"`
+			DOB time.Time `json:"date_of_birth"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || len(out.Data) != 1 {
+		t.Fatalf("body = %s", w.Body)
+	}
+	if out.Data[0].MRN != "MRN-77" || out.Data[0].DOB.Format("2006-01-02") != "1950-04-02" {
+		t.Errorf("decrypted = %+v", out.Data[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatientHistoryDecryptsFields(t *testing.T) {
+	ring, _ := fieldcrypt.FromConfig(fieldCfgV1)
+	mrn, _ := ring.Encrypt(fieldcrypt.PatientMRN, 5, "MRN-77")
+	dob, _ := ring.Encrypt(fieldcrypt.PatientDOB, 5, "1950-04-02")
+	notes, _ := ring.Encrypt(fieldcrypt.AssessmentNotes, 3, "stage 2, sacrum")
+
+	r, mock := scopedRouterWithConfig(t, "admin", fieldCfgV1)
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT get_patient_wound_history\(\$1\)`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"history"}).AddRow(
+			`{"patient":{"id":5,"medical_record_number":null,"date_of_birth":null},"assessments":[{"id":3,"notes":null},{"id":4,"notes":"plain"}]}`))
+	mock.ExpectQuery(`SELECT id, mrn_encrypted, dob_encrypted FROM patients WHERE id = \$1`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "mrn_encrypted", "dob_encrypted"}).AddRow(5, mrn, dob))
+	mock.ExpectQuery(`SELECT id, notes_encrypted FROM assessments WHERE patient_id = \$1 AND notes_encrypted IS NOT NULL`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes_encrypted"}).AddRow(3, notes))
+	mock.ExpectQuery(`FROM braden_assessments`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`FROM patient_consents`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(consentColumns))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/patients/5/history")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Patient struct {
+			ID  int    `json:"id"`
+			MRN string `json:"medical_record_number"`
+			DOB string `json:"date_of_birth"`
+		} `json:"patient"`
+		Assessments []struct {
+			ID    int    `json:"id"`
+			Notes string `json:"notes"`
+		} `json:"assessments"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Patient.ID != 5 || out.Patient.MRN != "MRN-77" || out.Patient.DOB != "1950-04-02" {
+		t.Errorf("patient = %+v", out.Patient)
+	}
+	if len(out.Assessments) != 2 || out.Assessments[0].Notes != "stage 2, sacrum" || out.Assessments[1].Notes != "plain" {
+		t.Errorf("assessments = %+v", out.Assessments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	old, _ := fieldcrypt.NewKeyRing(map[string][]byte{"k1": fieldKeyA}, "k1", indexKey)
+	ring, _ := fieldcrypt.NewKeyRing(map[string][]byte{"k1": fieldKeyA, "k2": fieldKeyB}, "k2", indexKey)
+	oldNotes, _ := old.Encrypt(fieldcrypt.AssessmentNotes, 9, "stage 3")
+	unbound := legacySeal(t, fieldKeyB, "k2", fieldcrypt.AssessmentNotes, "stage 4")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(`FROM patients\s+WHERE id > \$1 AND \(medical_record_number IS NOT NULL`).WithArgs(int64(0), 2, "v2:k2:%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medical_record_number", "date_of_birth", "mrn_encrypted", "dob_encrypted"}).
+			AddRow(4, "MRN-4", time.Date(1960, 5, 1, 0, 0, 0, 0, time.UTC), nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE patients SET medical_record_number = \$1`).
+		WithArgs(nil, sqlmock.AnyArg(), ring.BlindIndex(fieldcrypt.PatientMRN, "MRN-4"), nil, sqlmock.AnyArg(), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM patients`).WithArgs(int64(4), 2, "v2:k2:%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medical_record_number", "date_of_birth", "mrn_encrypted", "dob_encrypted"}))
+	mock.ExpectQuery(`FROM assessments\s+WHERE id > \$1 AND \(notes IS NOT NULL OR notes_encrypted NOT LIKE \$3\)`).WithArgs(int64(0), 2, "v2:k2:%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes", "notes_encrypted"}).AddRow(9, nil, oldNotes).AddRow(10, nil, unbound))
+	mock.ExpectBegin()
+	resealed := &capturedArg{}
+	mock.ExpectExec(`UPDATE assessments SET notes = \$1, notes_encrypted = \$2 WHERE id = \$3`).
+		WithArgs(nil, sqlmock.AnyArg(), int64(9)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE assessments SET notes = \$1, notes_encrypted = \$2 WHERE id = \$3`).
+		WithArgs(nil, resealed, int64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM assessments`).WithArgs(int64(10), 2, "v2:k2:%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes", "notes_encrypted"}))
+
+	res, err := fieldcrypt.Reencrypt(context.Background(), db, ring, fieldcrypt.Options{Batch: 2})
+	if err != nil || res.Patients != 1 || res.Assessments != 2 {
+		t.Fatalf("Reencrypt = %+v, %v", res, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if v, _ := resealed.v.(string); !strings.HasPrefix(v, "v2:k2:") {
+		t.Errorf("v1 value re-sealed as %q", v)
+	} else if got, err := ring.Decrypt(fieldcrypt.AssessmentNotes, 10, v); err != nil || got != "stage 4" {
+		t.Errorf("re-sealed value = %q, %v", got, err)
+	}
+}
+
+// legacySeal seals plaintext the way v1 did, bound to the column only.
+func legacySeal(t *testing.T, kek []byte, keyID, column, plaintext string) string {
+	t.Helper()
+	gcmSeal := func(key, pt, ad []byte) []byte {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := make([]byte, aead.NonceSize())
+		rand.Read(nonce)
+		return aead.Seal(nonce, nonce, pt, ad)
+	}
+	dek := bytes.Repeat([]byte{9}, 32)
+	wrapped := gcmSeal(kek, dek, []byte(column+"|"+keyID))
+	ct := gcmSeal(dek, []byte(plaintext), []byte(column))
+	enc := base64.RawStdEncoding.EncodeToString
+	return strings.Join([]string{"v1", keyID, enc(wrapped), enc(ct)}, ":")
+}
+
+func TestFieldCryptOpensV1Values(t *testing.T) {
+	ring, _ := fieldcrypt.FromConfig(fieldCfgV1)
+	sealed := legacySeal(t, fieldKeyA, "k1", fieldcrypt.PatientMRN, "MRN-1")
+	if fieldcrypt.KeyID(sealed) != "k1" {
+		t.Errorf("KeyID = %q", fieldcrypt.KeyID(sealed))
+	}
+	if got, err := ring.Decrypt(fieldcrypt.PatientMRN, 5, sealed); err != nil || got != "MRN-1" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := ring.Decrypt(fieldcrypt.PatientDOB, 5, sealed); err == nil {
+		t.Error("v1 value decrypted under another column")
+	}
+}
+
+func TestCreateAssessmentSealsNotesForNewRow(t *testing.T) {
+	ring, _ := fieldcrypt.FromConfig(fieldCfgV1)
+	r, mock := scopedRouterWithConfig(t, "physician", fieldCfgV1)
+	mock.ExpectBegin()
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), false)
+	mock.ExpectQuery(`INSERT INTO assessments`).WithArgs(int64(5), int64(8), nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	sealed := &capturedArg{}
+	mock.ExpectExec(`UPDATE assessments SET notes_encrypted = \$1 WHERE id = \$2`).WithArgs(sealed, int64(30)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/assessments", `{"patient_id":5,"clinician_id":8,"notes":"stage 2, sacrum"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	v, _ := sealed.v.(string)
+	if got, err := ring.Decrypt(fieldcrypt.AssessmentNotes, 30, v); err != nil || got != "stage 2, sacrum" {
+		t.Errorf("notes sealed for row 30 = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const fullAssessmentBody = `{"assessment":{"patient_id":5,"clinician_id":8,"wound_id":9,"notes":"clean"},
+	"measurement":{"length_cm":4,"width_cm":2.5,"depth_cm":1},
+	"tissue_types":[{"tissue_type":"granulation","percent":60},{"tissue_type":"slough","percent":40}],
+	"treatments":[{"primary_dressing":"alginate","change_frequency":"daily"}]}`
+
+const addFullAssessmentDefined = `SELECT EXISTS \(SELECT 1 FROM pg_proc\s+WHERE proname = 'add_full_assessment'`
+
+// expectFullAssessmentChecks expects each validation query of
+// fullAssessmentBody exactly once.
+func expectFullAssessmentChecks(mock sqlmock.Sqlmock) {
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), false)
+	for i := 0; i < 2; i++ { // the assessment's wound, then the treatment's
+		mock.ExpectQuery(`SELECT patient_id FROM wounds WHERE id = \$1`).WithArgs(int64(9)).
+			WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+	}
+}
+
+// fullAssessmentDocument matches the add_full_assessment argument built from
+// fullAssessmentBody.
+type fullAssessmentDocument struct{}
+
+type docAssessment struct {
+	PatientID   int64           `json:"patient_id"`
+	ClinicianID int64           `json:"clinician_id"`
+	WoundID     int64           `json:"wound_id"`
+	Notes       string          `json:"notes"`
+	TissueType  string          `json:"tissue_type"`
+	Measurement json.RawMessage `json:"measurement"`
+}
+
+type docMeasurement struct {
+	LengthCm  float64 `json:"length_cm"`
+	WidthCm   float64 `json:"width_cm"`
+	DepthCm   float64 `json:"depth_cm"`
+	AreaCm2   float64 `json:"area_cm2"`
+	VolumeCm3 float64 `json:"volume_cm3"`
+}
+
+type docTissueType struct {
+	TissueType string  `json:"tissue_type"`
+	Percent    float64 `json:"percent"`
+}
+
+type docTreatment struct {
+	WoundID         int64  `json:"wound_id"`
+	PrimaryDressing string `json:"primary_dressing"`
+	ChangeFrequency string `json:"change_frequency"`
+	Status          string `json:"status"`
+}
+
+type docFields struct {
+	Assessment      docAssessment     `json:"assessment"`
+	Measurement     docMeasurement    `json:"measurement"`
+	TissueTypes     []docTissueType   `json:"tissue_types"`
+	Treatments      []docTreatment    `json:"treatments"`
+	ImageReferences []json.RawMessage `json:"image_references"`
+}
+
+func (fullAssessmentDocument) Match(v driver.Value) bool {
+	str, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var got docFields
+	if err := json.Unmarshal([]byte(str), &got); err != nil {
+		return false
+	}
+	want := docFields{
+		// The measurement moves out of the assessment; the worst tissue
+		// present becomes the assessment's tissue type.
+		Assessment:      docAssessment{PatientID: 5, ClinicianID: 8, WoundID: 9, Notes: "clean", TissueType: "slough", Measurement: json.RawMessage("null")},
+		Measurement:     docMeasurement{LengthCm: 4, WidthCm: 2.5, DepthCm: 1, AreaCm2: 10, VolumeCm3: 10},
+		TissueTypes:     []docTissueType{{"granulation", 60}, {"slough", 40}},
+		Treatments:      []docTreatment{{WoundID: 9, PrimaryDressing: "alginate", ChangeFrequency: "daily", Status: "active"}},
+		ImageReferences: []json.RawMessage{},
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+func TestCreateFullAssessmentInsertsInOneTransaction(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	expectFullAssessmentChecks(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(addFullAssessmentDefined).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`INSERT INTO assessments`).WithArgs(int64(5), int64(8), int64(9), "clean", nil, nil, "slough").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	mock.ExpectQuery(`INSERT INTO wound_measurements`).WithArgs(int64(30), 4.0, 2.5, 1.0, 10.0, 10.0, "[]", "[]").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectExec(`INSERT INTO assessment_tissue_types`).WithArgs(int64(30), "granulation", 60.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO assessment_tissue_types`).WithArgs(int64(30), "slough", 40.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO treatments`).
+		WithArgs(int64(30), int64(9), "", "alginate", "", "", "daily", "active", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(50, time.Now(), time.Now()))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT get_assessment_full\(\$1\)`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"doc"}).AddRow(nil))
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/assessments/full", fullAssessmentBody)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"id":30}` {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateFullAssessmentRollsBackFailedChildInsert(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	expectFullAssessmentChecks(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(addFullAssessmentDefined).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`INSERT INTO assessments`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	mock.ExpectQuery(`INSERT INTO wound_measurements`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectExec(`INSERT INTO assessment_tissue_types`).WithArgs(int64(30), "granulation", 60.0).
+		WillReturnError(errors.New("deadlock detected"))
+	mock.ExpectRollback()
+
+	if w := sendJSON(t, r, http.MethodPost, "/v1/assessments/full", fullAssessmentBody); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateFullAssessmentUsesDatabaseFunction(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	expectFullAssessmentChecks(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(addFullAssessmentDefined).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT add_full_assessment\(\$1::jsonb\)`).WithArgs(fullAssessmentDocument{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT get_assessment_full\(\$1\)`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"doc"}).AddRow(nil))
+
+	if w := sendJSON(t, r, http.MethodPost, "/v1/assessments/full", fullAssessmentBody); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateFullAssessmentRespondsWithFullDocument(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	expectFullAssessmentChecks(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(addFullAssessmentDefined).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT add_full_assessment\(\$1::jsonb\)`).WithArgs(fullAssessmentDocument{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT get_assessment_full\(\$1\)`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"doc"}).
+			AddRow(`{"assessment_id":30,"patient_id":5,"notes":"clean","measurement":{"length_cm":4,"width_cm":2.5}}`))
+	mock.ExpectQuery(`FROM assessments a LEFT JOIN wound_measurements m`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"exudate", "tissue", "length", "width"}).AddRow("light", "slough", 4.0, 2.5))
+	started := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM treatments WHERE assessment_id = \$1`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "wound_id", "cleanser", "primary_dressing",
+			"secondary_dressing", "offloading", "change_frequency", "status", "started_at", "discontinued_at", "created_at", "updated_at"}).
+			AddRow(50, 30, 9, "", "alginate", "", "", "daily", "active", started, nil, started, started))
+	mock.ExpectQuery(`FROM assessment_tissue_types`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"tissue_type", "percent"}).AddRow("granulation", 60.0).AddRow("slough", 40.0))
+	mock.ExpectQuery(`FROM assessment_image_references`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "uri", "caption", "captured_at", "created_at"}))
+	mock.ExpectQuery(`SELECT id, notes_encrypted FROM assessments`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes_encrypted"}).AddRow(30, nil))
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/assessments/full", fullAssessmentBody)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		AssessmentID int64 `json:"assessment_id"`
+		PatientID    int64 `json:"patient_id"`
+		Notes        string
+		Measurement  struct {
+			LengthCm float64 `json:"length_cm"`
+		} `json:"measurement"`
+		PushScore *struct {
+			Total int `json:"total"`
+		} `json:"push_score"`
+		Treatments []struct {
+			ID              int64  `json:"id"`
+			PrimaryDressing string `json:"primary_dressing"`
+		} `json:"treatments"`
+		TissueTypes []struct {
+			TissueType string  `json:"tissue_type"`
+			Percent    float64 `json:"percent"`
+		} `json:"tissue_types"`
+		ImageReferences []json.RawMessage `json:"image_references"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.AssessmentID != 30 || out.PatientID != 5 || out.Notes != "clean" || out.Measurement.LengthCm != 4 {
+		t.Errorf("assessment fields not kept at the top level: %s", w.Body)
+	}
+	if out.PushScore == nil || len(out.Treatments) != 1 || out.Treatments[0].PrimaryDressing != "alginate" ||
+		len(out.TissueTypes) != 2 || out.TissueTypes[0].TissueType != "granulation" || out.ImageReferences == nil {
+		t.Errorf("body = %s, want push_score, treatments, tissue_types and image_references merged in", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetAssessmentFullWrapsNonObjectDocument(t *testing.T) {
+	r, mock := scopedRouter(t, "physician")
+	mock.ExpectQuery(`SELECT a.patient_id FROM assessments a WHERE a.id = \$1 AND`).WithArgs("30", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT get_assessment_full\(\$1\)`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"doc"}).AddRow(`[{"assessment_id":30}]`))
+	mock.ExpectQuery(`FROM assessments a LEFT JOIN wound_measurements m`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"exudate", "tissue", "length", "width"}).AddRow("light", "slough", 4.0, 2.5))
+	mock.ExpectQuery(`FROM treatments WHERE assessment_id = \$1`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "wound_id", "cleanser", "primary_dressing",
+			"secondary_dressing", "offloading", "change_frequency", "status", "started_at", "discontinued_at", "created_at", "updated_at"}))
+	mock.ExpectQuery(`FROM assessment_tissue_types`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"tissue_type", "percent"}))
+	mock.ExpectQuery(`FROM assessment_image_references`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "assessment_id", "uri", "caption", "captured_at", "created_at"}))
+	mock.ExpectQuery(`SELECT id, notes_encrypted FROM assessments`).WithArgs("30").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "notes_encrypted"}).AddRow(30, nil))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/assessments/30/full")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Assessment []struct {
+			AssessmentID int64 `json:"assessment_id"`
+		} `json:"assessment"`
+		PushScore  json.RawMessage   `json:"push_score"`
+		Treatments []json.RawMessage `json:"treatments"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Assessment) != 1 || out.Assessment[0].AssessmentID != 30 || out.PushScore == nil || out.Treatments == nil {
+		t.Errorf("body = %s, want the document under assessment with the extra keys beside it", w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/imaging"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+// withExifOrientation inserts an APP1 EXIF segment carrying the given
+// orientation and a GPS-looking payload right after the JPEG SOI marker.
+func withExifOrientation(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, binary.LittleEndian, uint32(8))
+	binary.Write(&tiff, binary.LittleEndian, uint16(1))      // one IFD entry
+	binary.Write(&tiff, binary.LittleEndian, uint16(0x0112)) // orientation
+	binary.Write(&tiff, binary.LittleEndian, uint16(3))      // SHORT
+	binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	binary.Write(&tiff, binary.LittleEndian, orientation)
+	binary.Write(&tiff, binary.LittleEndian, uint16(0))
+	binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString("GPSLatitude=51.5074N")
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var seg bytes.Buffer
+	seg.Write([]byte{0xFF, 0xE1})
+	binary.Write(&seg, binary.BigEndian, uint16(len(payload)+2))
+	seg.Write(payload)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, seg.Bytes()...)
+	return append(out, jpg[2:]...)
+}
+
+func TestImagingStripsExifAndAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	in := withExifOrientation(t, buf.Bytes(), 6)
+
+	res, err := imaging.Process(in, imaging.DefaultOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if res.ContentType != imaging.TypeJPEG {
+		t.Errorf("content type = %q", res.ContentType)
+	}
+	if res.Width != 20 || res.Height != 40 {
+		t.Errorf("dimensions = %dx%d, want 20x40 after 90° rotation", res.Width, res.Height)
+	}
+	if bytes.Contains(res.Data, []byte("Exif")) || bytes.Contains(res.Data, []byte("GPSLatitude")) {
+		t.Error("output still contains EXIF metadata")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(res.Data)); err != nil {
+		t.Errorf("output is not a valid JPEG: %v", err)
+	}
+}
+
+func TestImagingThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(600, 300)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := imaging.Process(buf.Bytes(), imaging.DefaultOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if res.ContentType != imaging.TypePNG {
+		t.Errorf("content type = %q", res.ContentType)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(res.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if cfg.Width != 256 || cfg.Height != 128 {
+		t.Errorf("thumbnail = %dx%d, want 256x128", cfg.Width, cfg.Height)
+	}
+}
+
+func TestImagingRejects(t *testing.T) {
+	if _, err := imaging.Process([]byte("%PDF-1.4 not an image"), imaging.DefaultOptions); !errors.Is(err, imaging.ErrUnsupportedType) {
+		t.Errorf("pdf: err = %v, want ErrUnsupportedType", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(100, 100)); err != nil {
+		t.Fatal(err)
+	}
+	opts := imaging.DefaultOptions
+	opts.MaxPixels = 100
+	if _, err := imaging.Process(buf.Bytes(), opts); !errors.Is(err, imaging.ErrTooLarge) {
+		t.Errorf("oversized: err = %v, want ErrTooLarge", err)
+	}
+}
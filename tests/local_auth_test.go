@@ -0,0 +1,314 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/totp"
+)
+
+const loginQuery = `SELECT u.id, u.password_hash, u.totp_secret, u.totp_enabled, u.totp_last_step, u.locked_until,.*WHERE lower\(u.username\) = lower\(\$1\)`
+
+var loginColumns = []string{"id", "password_hash", "totp_secret", "totp_enabled", "totp_last_step", "locked_until", "email", "full_name"}
+
+func localAuthRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := &config.Config{
+		LocalAuth:        true,
+		JWTHS256Secret:   string(testHS256Secret),
+		AccessTokenTTL:   15 * time.Minute,
+		RefreshTokenTTL:  24 * time.Hour,
+		LoginMaxFailures: 5,
+		LoginLockout:     15 * time.Minute,
+		PasswordResetTTL: time.Hour,
+
+		SessionRevocationRefresh: time.Minute,
+	}
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := router.New(db, zap.NewNop(), cfg, nil, v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, mock
+}
+
+func passwordHash(t *testing.T, password string) string {
+	t.Helper()
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// postAs sends a JSON POST with bearer, which may be empty.
+func postAs(r *gin.Engine, path, bearer, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTOTPMatchesRFC6238(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	for _, tt := range []struct {
+		unix int64
+		want string
+	}{{59, "287082"}, {1111111109, "081804"}, {1234567890, "005924"}, {2000000000, "279037"}} {
+		got, err := totp.Code(secret, totp.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("T=%d: code = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+	if _, ok := totp.Validate(secret, "287082", time.Unix(59+30, 0)); !ok {
+		t.Error("code from the previous step rejected")
+	}
+	if _, ok := totp.Validate(secret, "287082", time.Unix(59+90, 0)); ok {
+		t.Error("code from three steps ago accepted")
+	}
+}
+
+func TestLocalLoginAndMFAEnrolment(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	mock.ExpectQuery(loginQuery).WithArgs("nurse").
+		WillReturnRows(sqlmock.NewRows(loginColumns).
+			AddRow(3, passwordHash(t, "correct horse battery"), nil, false, nil, nil, "nurse@example.org", "Nina Nurse"))
+	mock.ExpectExec(`UPDATE user_accounts SET failed_logins = 0`).WithArgs(int64(3), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSessionStart(mock, 3, 12)
+
+	w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status = %d: %s", w.Code, w.Body)
+	}
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	if tokens.AccessToken == "" || len(tokens.RefreshToken) != 64 || tokens.ExpiresIn <= 0 || tokens.ExpiresIn > 900 {
+		t.Fatalf("login response = %s", w.Body)
+	}
+
+	// The access token authenticates as the linked clinician.
+	expectRevocationLoad(mock)
+	mock.ExpectQuery(clinicianLookup).WithArgs("nurse@example.org").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`UPDATE user_accounts SET totp_secret = \$2`).WithArgs(int64(3), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("nurse"))
+	w = postAs(r, "/v1/auth/mfa/enroll", tokens.AccessToken, ``)
+	if w.Code != http.StatusOK {
+		t.Fatalf("enroll: status = %d: %s", w.Code, w.Body)
+	}
+	var enrol struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &enrol)
+	if enrol.Secret == "" || !strings.HasPrefix(enrol.URI, "otpauth://totp/Wound%20IQ:nurse?") {
+		t.Fatalf("enroll response = %s", w.Body)
+	}
+
+	code, err := totp.Code(enrol.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(clinicianLookup).WithArgs("nurse@example.org").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`SELECT totp_secret, totp_enabled FROM user_accounts WHERE id = \$1`).WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(enrol.Secret, false))
+	mock.ExpectExec(`UPDATE user_accounts SET totp_enabled = true`).WithArgs(int64(3), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if w := postAs(r, "/v1/auth/mfa/verify", tokens.AccessToken, `{"code":"`+code+`"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("verify: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoginWithMFA(t *testing.T) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(secret, step)
+	hash := passwordHash(t, "correct horse battery")
+	account := func(lastStep interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows(loginColumns).AddRow(3, hash, secret, true, lastStep, nil, "nurse@example.org", "Nina Nurse")
+	}
+
+	r, mock := localAuthRouter(t)
+	mock.ExpectQuery(loginQuery).WillReturnRows(account(nil))
+	w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery"}`)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"mfa_required":true`) {
+		t.Fatalf("without code: status = %d: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectQuery(loginQuery).WillReturnRows(account(nil))
+	mock.ExpectExec(`UPDATE user_accounts SET failed_logins = 0`).WithArgs(int64(3), step).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`WITH s AS \(\s+INSERT INTO sessions \(user_id, user_agent, ip_address, auth_methods`).
+		WithArgs(int64(3), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(86400), sqlmock.AnyArg(), "pwd otp").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "expires_at"}).AddRow(12, time.Now().Add(24*time.Hour)))
+	if w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery","totp_code":"`+code+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("with code: status = %d: %s", w.Code, w.Body)
+	}
+
+	// The same code cannot be used twice.
+	mock.ExpectQuery(loginQuery).WillReturnRows(account(step))
+	mock.ExpectQuery(`UPDATE user_accounts\s+SET locked_until`).WithArgs(int64(3), 5, int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	if w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery","totp_code":"`+code+`"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed code: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoginFailuresAndLockout(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	hash := passwordHash(t, "correct horse battery")
+
+	mock.ExpectQuery(loginQuery).WithArgs("nobody").WillReturnRows(sqlmock.NewRows(loginColumns))
+	if w := postAs(r, "/v1/auth/login", "", `{"username":"nobody","password":"correct horse battery"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown user: status = %d: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectQuery(loginQuery).WillReturnRows(sqlmock.NewRows(loginColumns).
+		AddRow(3, hash, nil, false, nil, nil, "nurse@example.org", "Nina Nurse"))
+	mock.ExpectQuery(`UPDATE user_accounts\s+SET locked_until`).WithArgs(int64(3), 5, int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"wrong password!"}`)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid username or password") {
+		t.Fatalf("wrong password: status = %d: %s", w.Code, w.Body)
+	}
+
+	// Once locked, even the right password is refused.
+	mock.ExpectQuery(loginQuery).WillReturnRows(sqlmock.NewRows(loginColumns).
+		AddRow(3, hash, nil, false, nil, time.Now().Add(10*time.Minute), "nurse@example.org", "Nina Nurse"))
+	if w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery"}`); w.Code != http.StatusLocked {
+		t.Fatalf("locked: status = %d: %s", w.Code, w.Body)
+	}
+
+	// A wrong password does not reveal the lockout, or that the user exists.
+	mock.ExpectQuery(loginQuery).WillReturnRows(sqlmock.NewRows(loginColumns).
+		AddRow(3, hash, nil, false, nil, time.Now().Add(10*time.Minute), "nurse@example.org", "Nina Nurse"))
+	mock.ExpectQuery(`UPDATE user_accounts\s+SET locked_until`).WithArgs(int64(3), 5, int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	w = postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"wrong password!"}`)
+	if w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "locked") {
+		t.Fatalf("locked, wrong password: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangePasswordCountsFailures(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	hash := passwordHash(t, "correct horse battery")
+	body := `{"current_password":"wrong password!","new_password":"a much better password"}`
+
+	expectRevocationLoad(mock)
+	mock.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`SELECT password_hash, locked_until FROM user_accounts WHERE id = \$1`).WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash", "locked_until"}).AddRow(hash, nil))
+	mock.ExpectQuery(`UPDATE user_accounts\s+SET locked_until`).WithArgs(int64(3), 5, int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	if w := postAs(r, "/v1/auth/password", sessionToken(t, 12), body); w.Code != http.StatusForbidden {
+		t.Fatalf("wrong password: status = %d: %s", w.Code, w.Body)
+	}
+
+	// Once locked, no more guesses are checked.
+	mock.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`SELECT password_hash, locked_until FROM user_accounts WHERE id = \$1`).WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash", "locked_until"}).AddRow(hash, time.Now().Add(10*time.Minute)))
+	if w := postAs(r, "/v1/auth/password", sessionToken(t, 12), body); w.Code != http.StatusLocked {
+		t.Fatalf("locked: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens SET used_at = now\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE user_accounts SET password_hash = \$2`).WithArgs(int64(3), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE sessions SET revoked_at = now\(\)`).WithArgs("password changed", int64(3), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12).AddRow(13))
+	mock.ExpectCommit()
+	if w := postAs(r, "/v1/auth/password-reset", "", `{"token":"abc","new_password":"a much better password"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens SET used_at = now\(\)`).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectRollback()
+	if w := postAs(r, "/v1/auth/password-reset", "", `{"token":"used","new_password":"a much better password"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("used token: status = %d: %s", w.Code, w.Body)
+	}
+
+	if w := postAs(r, "/v1/auth/password-reset", "", `{"token":"abc","new_password":"short"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("short password: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateUserAccount(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`SELECT COALESCE\(email, ''\) FROM clinicians WHERE id = \$1`).WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("pat@example.org"))
+	mock.ExpectQuery(`INSERT INTO user_accounts .* WHERE NOT EXISTS`).WithArgs(int64(9), "pat", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if w := sendJSON(t, r, http.MethodPost, "/v1/users", `{"clinician_id":9,"username":"pat","password":"correct horse battery"}`); w.Code != http.StatusConflict {
+		t.Fatalf("taken: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoginRoutesNeedLocalAuth(t *testing.T) {
+	r, _ := scopedRouter(t, "admin")
+	if w := postAs(r, "/v1/auth/login", "", `{"username":"nurse","password":"correct horse battery"}`); w.Code != http.StatusUnauthorized && w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 401 or 404: %s", w.Code, w.Body)
+	}
+}
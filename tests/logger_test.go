@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/logger"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/requestid"
+)
+
+// observedLogger returns a redacting logger and the entries written to it.
+func observedLogger(fields []string) (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(logger.RedactCore(core, logger.NewRedactor(fields))), logs
+}
+
+func TestRedactorString(t *testing.T) {
+	r := logger.NewRedactor(logger.DefaultRedactFields)
+	tests := []struct{ in, want string }{
+		{`full_name=Jane`, `full_name=[REDACTED]`},
+		{`{"full_name":"Jane Doe","gender":"f"}`, `{"full_name":[REDACTED],"gender":"f"}`},
+		{`update patient: medical_record_number: MRN-1234`, `update patient: medical_record_number: [REDACTED]`},
+		{`NOTES='pressure injury, stage 2'`, `NOTES=[REDACTED]`},
+		{`duplicate key value: Key (medical_record_number)=(MRN-1234) already exists`, `duplicate key value: Key (medical_record_number)=([REDACTED]) already exists`},
+		{`ERROR: invalid input syntax for type date: "1990-13-45" (SQLSTATE 22007)`, `ERROR: invalid input syntax for type date: "[REDACTED]" (SQLSTATE 22007)`},
+		{`list patients: connection refused`, `list patients: connection refused`},
+	}
+	for _, tt := range tests {
+		if got := r.String(tt.in); got != tt.want {
+			t.Errorf("String(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactCoreFields(t *testing.T) {
+	log, logs := observedLogger(logger.DefaultRedactFields)
+	log.With(zap.String("mrn", "MRN-9")).Sugar().Errorf("create patient: %v", errors.New(`Key (medical_record_number)=(MRN-9) exists`))
+	log.Info("patient",
+		zap.String("full_name", "Jane Doe"),
+		zap.Error(errors.New(`notes="fell at home"`)),
+		zap.Any("body", map[string]interface{}{"date_of_birth": "1950-01-01", "wound": map[string]interface{}{"note": "x", "stage": 2}}),
+		zap.Int64("patient_id", 5))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	if got := entries[0].Message; strings.Contains(got, "MRN-9") {
+		t.Errorf("message not redacted: %q", got)
+	}
+	if got := entries[0].ContextMap()["mrn"]; got != logger.Redacted {
+		t.Errorf("With field mrn = %v", got)
+	}
+
+	ctx := entries[1].ContextMap()
+	if ctx["full_name"] != logger.Redacted {
+		t.Errorf("full_name = %v", ctx["full_name"])
+	}
+	if got := ctx["error"].(string); strings.Contains(got, "fell") {
+		t.Errorf("error = %q", got)
+	}
+	body := ctx["body"].(map[string]interface{})
+	if body["date_of_birth"] != logger.Redacted || body["wound"].(map[string]interface{})["note"] != logger.Redacted {
+		t.Errorf("body = %v", body)
+	}
+	if ctx["patient_id"] != int64(5) {
+		t.Errorf("patient_id = %v", ctx["patient_id"])
+	}
+}
+
+func TestRedactorConfigurableFields(t *testing.T) {
+	r := logger.NewRedactor([]string{"Wound_Location"})
+	if !r.Field("wound_location") || r.Field("full_name") {
+		t.Fatal("custom field list not applied")
+	}
+	if got := r.String("wound_location=sacrum full_name=Jane"); got != "wound_location=[REDACTED] full_name=Jane" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log, logs := observedLogger(logger.DefaultRedactFields)
+	r := gin.New()
+	r.Use(requestid.Middleware(), logger.AccessLog(log, logger.NewRedactor(logger.DefaultRedactFields)), logger.Recovery(log))
+	r.GET("/v1/patients/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/v1/boom", func(c *gin.Context) { panic("name=Jane") })
+
+	for _, path := range []string{"/v1/patients/123?full_name=Jane+Doe&page=2", "/v1/boom", "/v1/patients-by-name/Jane"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var access []observer.LoggedEntry
+	for _, e := range logs.AllUntimed() {
+		for _, f := range e.Context {
+			if f.Key == "route" || f.Key == "query" || f.Key == "panic" {
+				if strings.Contains(f.String, "Jane") || strings.Contains(f.String, "123") {
+					t.Errorf("%s leaked in %s: %q", f.Key, e.Message, f.String)
+				}
+			}
+		}
+		if e.Message == "request" {
+			access = append(access, e)
+		}
+	}
+	if len(access) != 3 {
+		t.Fatalf("got %d access entries, want 3", len(access))
+	}
+	ctx := access[0].ContextMap()
+	if ctx["route"] != "/v1/patients/:id" || ctx["query"] != "full_name=[REDACTED]&page=2" || ctx["request_id"] == "" {
+		t.Errorf("access entry = %v", ctx)
+	}
+	if access[1].Level != zapcore.ErrorLevel || access[1].ContextMap()["status"] != int64(500) {
+		t.Errorf("panic access entry = %v %v", access[1].Level, access[1].ContextMap())
+	}
+	if access[2].ContextMap()["route"] != "(unmatched)" {
+		t.Errorf("unmatched route = %v", access[2].ContextMap()["route"])
+	}
+}
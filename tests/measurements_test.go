@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/models"
+)
+
+func TestMeasurementComputeDerived(t *testing.T) {
+	cases := []struct {
+		name                 string
+		length, width, depth float64
+		wantArea, wantVolume float64
+	}{
+		{"flat", 4, 2.5, 0, 10, 0},
+		{"cavity", 4, 2.5, 1.2, 10, 12},
+		{"rounds to two decimals", 1.234, 2.345, 0.5, 2.89, 1.45},
+		{"unmeasured", 0, 0, 0, 0, 0},
+		{"zero width", 3, 0, 2, 0, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := models.WoundMeasurement{LengthCm: tc.length, WidthCm: tc.width, DepthCm: tc.depth, AreaCm2: 99, VolumeCm3: 99}
+			m.ComputeDerived()
+			if m.AreaCm2 != tc.wantArea || m.VolumeCm3 != tc.wantVolume {
+				t.Errorf("area, volume = %v, %v, want %v, %v", m.AreaCm2, m.VolumeCm3, tc.wantArea, tc.wantVolume)
+			}
+		})
+	}
+}
+
+var measurementColumns = []string{"id", "assessment_id", "wound_id", "length_cm", "width_cm", "depth_cm", "area_cm2", "volume_cm3", "undermining", "tunneling", "created_at"}
+
+func TestListWoundMeasurements(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectQuery(`SELECT wounds.patient_id FROM wounds WHERE wounds.id = \$1 AND .*ct.clinician_id`).
+		WithArgs("5", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(42))
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM wound_measurements m JOIN assessments a ON a.id = m.assessment_id\s+WHERE a.wound_id = \$1 ORDER BY a.created_at ASC, m.id ASC`).
+		WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(measurementColumns).
+			AddRow(1, 10, 5, 4.0, 3.0, 1.0, 12.0, 12.0, []byte(`[{"from_clock":12,"to_clock":3,"depth_cm":0.8}]`), []byte(`[]`), day).
+			AddRow(2, 11, 5, 3.0, 2.0, 0.5, 6.0, 3.0, []byte(`[]`), []byte(`[{"clock_position":6,"depth_cm":1.5}]`), day.AddDate(0, 0, 7)))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/wounds/5/measurements")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data []models.WoundMeasurement `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data) != 2 {
+		t.Fatalf("got %d measurements, want 2: %s", len(body.Data), w.Body)
+	}
+	first, second := body.Data[0], body.Data[1]
+	if first.AssessmentID != 10 || second.AssessmentID != 11 {
+		t.Errorf("order = %d, %d, want oldest first", first.AssessmentID, second.AssessmentID)
+	}
+	if first.WoundID == nil || *first.WoundID != 5 {
+		t.Errorf("wound_id = %v, want 5", first.WoundID)
+	}
+	if first.AreaCm2 != 12 || second.VolumeCm3 != 3 {
+		t.Errorf("derived fields = %+v, %+v", first, second)
+	}
+	if len(first.Undermining) != 1 || first.Undermining[0].FromClock != 12 || len(second.Tunneling) != 1 || second.Tunneling[0].DepthCm != 1.5 {
+		t.Errorf("undermining/tunneling = %+v, %+v", first.Undermining, second.Tunneling)
+	}
+	if !first.MeasuredAt.Equal(day) {
+		t.Errorf("measured_at = %v, want %v", first.MeasuredAt, day)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListWoundMeasurementsEmpty(t *testing.T) {
+	r, mock := scopedRouter(t, "admin")
+	mock.ExpectQuery(`SELECT wounds.patient_id FROM wounds WHERE wounds.id = \$1$`).
+		WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(42))
+	mock.ExpectQuery(`FROM wound_measurements m JOIN assessments a`).WithArgs("5").
+		WillReturnRows(sqlmock.NewRows(measurementColumns))
+
+	w := serveAs(t, r, http.MethodGet, "/v1/wounds/5/measurements")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Body.String(); got != `{"data":[]}` {
+		t.Errorf("body = %s, want an empty list rather than null", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateAssessmentComputesMeasurement(t *testing.T) {
+	r, mock := scopedRouter(t, "nurse")
+	mock.ExpectBegin()
+	expectPatientVisible(mock, int64(5))
+	expectEnrolment(mock, int64(5), false)
+	mock.ExpectQuery(`SELECT patient_id FROM wounds WHERE id = \$1`).WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+	mock.ExpectQuery(`INSERT INTO assessments`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+	// Client-supplied area and volume are ignored in favour of the computed ones.
+	mock.ExpectQuery(`INSERT INTO wound_measurements`).
+		WithArgs(int64(30), 4.0, 2.5, 1.2, 10.0, 12.0, `[]`, `[{"clock_position":6,"depth_cm":1.5}]`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := sendJSON(t, r, http.MethodPost, "/v1/assessments", `{"patient_id":5,"clinician_id":7,"wound_id":9,
+		"measurement":{"length_cm":4,"width_cm":2.5,"depth_cm":1.2,"area_cm2":1,"volume_cm3":1,"tunneling":[{"clock_position":6,"depth_cm":1.5}]}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateAssessmentRejectsOversizedMeasurement(t *testing.T) {
+	tests := []struct {
+		name        string
+		measurement string
+		checked     bool // whether the request gets past binding to the patient checks
+	}{
+		{"length", `{"length_cm":100000,"width_cm":1}`, false},
+		{"width", `{"length_cm":1,"width_cm":100000}`, false},
+		{"depth", `{"length_cm":1,"width_cm":1,"depth_cm":100000}`, false},
+		{"area", `{"length_cm":99999,"width_cm":99999}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			if tt.checked {
+				mock.ExpectBegin()
+				expectPatientVisible(mock, int64(5))
+				expectEnrolment(mock, int64(5), false)
+				mock.ExpectRollback()
+			}
+			w := sendJSON(t, r, http.MethodPost, "/v1/assessments", `{"patient_id":5,"clinician_id":7,"wound_id":9,"measurement":`+tt.measurement+`}`)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
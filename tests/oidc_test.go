@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+)
+
+// mockIssuer is a local OpenID Connect issuer serving discovery and a
+// JWKS that tests can rotate.
+type mockIssuer struct {
+	*httptest.Server
+	discoveries atomic.Int32
+	jwksFetches atomic.Int32
+
+	mu       sync.Mutex
+	issuer   string // advertised issuer; defaults to the server URL
+	jwksPath string
+	keys     map[string]*rsa.PrivateKey
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	m := &mockIssuer{jwksPath: "/jwks", keys: map[string]*rsa.PrivateKey{}}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			m.discoveries.Add(1)
+			issuer := m.issuer
+			if issuer == "" {
+				issuer = m.URL
+			}
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": m.URL + m.jwksPath})
+		case m.jwksPath:
+			m.jwksFetches.Add(1)
+			var keys []map[string]string
+			for kid, k := range m.keys {
+				keys = append(keys, rsaJWK(kid, &k.PublicKey))
+			}
+			w.Write(jwksJSON(t, keys...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// rotate replaces the published keys with a fresh one under kid.
+func (m *mockIssuer) rotate(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.keys = map[string]*rsa.PrivateKey{kid: k}
+	m.mu.Unlock()
+	return k
+}
+
+func (m *mockIssuer) keySet() *auth.OIDCKeySet {
+	keys := auth.NewOIDCKeySet(m.URL, m.Client())
+	keys.MinRefresh = 0
+	return keys
+}
+
+func (m *mockIssuer) claims() jwt.MapClaims {
+	c := validClaims()
+	c["iss"] = m.URL
+	return c
+}
+
+func TestOIDCDiscoveryAndKeyRotation(t *testing.T) {
+	idp := newMockIssuer(t)
+	first := idp.rotate(t, "k1")
+	v, err := auth.NewVerifier(auth.Options{Keys: idp.keySet(), Issuer: idp.URL, Audience: "wound-iq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "k1", first, idp.claims())); err != nil {
+			t.Fatalf("k1: %v", err)
+		}
+	}
+
+	second := idp.rotate(t, "k2")
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "k2", second, idp.claims())); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "k1", first, idp.claims())); err == nil {
+		t.Error("retired key still accepted")
+	}
+	if n := idp.discoveries.Load(); n != 1 {
+		t.Errorf("discoveries = %d, want 1 (metadata cached)", n)
+	}
+	if n := idp.jwksFetches.Load(); n != 3 {
+		t.Errorf("jwks fetches = %d, want 3 (first use and one per unknown kid)", n)
+	}
+
+	other := validClaims() // issued by https://idp.example.org
+	if _, err := v.Verify(ctx, signToken(t, jwt.SigningMethodRS256, "k2", second, other)); err == nil {
+		t.Error("token from another issuer accepted")
+	}
+}
+
+func TestOIDCFollowsMovedJWKS(t *testing.T) {
+	idp := newMockIssuer(t)
+	idp.rotate(t, "k1")
+	keys := idp.keySet()
+	keys.MetadataTTL = 0
+	v, err := auth.NewVerifier(auth.Options{Keys: keys, Issuer: idp.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.jwksPath = "/keys/v2"
+	idp.mu.Unlock()
+	k := idp.rotate(t, "k2")
+	if _, err := v.Verify(context.Background(), signToken(t, jwt.SigningMethodRS256, "k2", k, idp.claims())); err != nil {
+		t.Fatalf("moved jwks: %v", err)
+	}
+}
+
+func TestOIDCDiscoversOutsideLock(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var discoveries atomic.Int32
+	release := make(chan struct{})
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jwks" {
+			w.Write(jwksJSON(t, rsaJWK("k1", &key.PublicKey)))
+			return
+		}
+		if discoveries.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/jwks"})
+	}))
+	defer srv.Close()
+
+	keys := auth.NewOIDCKeySet(srv.URL, srv.Client())
+	keys.MinRefresh = 0
+	ctx := context.Background()
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stale metadata starts a rediscovery that hangs...
+	keys.MetadataTTL = 0
+	rediscovered := make(chan error)
+	go func() {
+		_, err := keys.Key(ctx, "k1")
+		rediscovered <- err
+	}()
+	for discoveries.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// ...while other lookups keep using the keys already discovered.
+	done := make(chan error)
+	go func() {
+		_, err := keys.Key(ctx, "k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key lookup blocked behind discovery")
+	}
+	close(release)
+	if err := <-rediscovered; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIssuer(t)
+	k := idp.rotate(t, "k1")
+	idp.issuer = "https://attacker.example.org"
+	v, err := auth.NewVerifier(auth.Options{Keys: idp.keySet()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), signToken(t, jwt.SigningMethodRS256, "k1", k, idp.claims())); err == nil {
+		t.Fatal("token accepted with keys from a mismatched discovery document")
+	}
+	if n := idp.jwksFetches.Load(); n != 0 {
+		t.Errorf("jwks fetches = %d, want 0", n)
+	}
+}
+
+func TestUnverifiedEmailIsIgnored(t *testing.T) {
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret, EmailClaim: "upn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		verified interface{}
+		want     string
+	}{
+		{"absent", nil, "nurse@example.org"},
+		{"true", true, "nurse@example.org"},
+		{"false", false, ""},
+		{"string false", "false", ""},
+	} {
+		c := validClaims()
+		c["upn"] = "nurse@example.org"
+		delete(c, "email")
+		if tt.verified != nil {
+			c["email_verified"] = tt.verified
+		}
+		p, err := v.Verify(context.Background(), signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, c))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if p.Email != tt.want {
+			t.Errorf("%s: email = %q, want %q", tt.name, p.Email, tt.want)
+		}
+	}
+}
+
+func TestOIDCAutoProvisionsClinician(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	idp := newMockIssuer(t)
+	k := idp.rotate(t, "k1")
+	v, err := auth.NewVerifier(auth.Options{Keys: idp.keySet(), Issuer: idp.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := &config.Config{OIDCIssuerURL: idp.URL, OIDCAutoProvision: true, OIDCDefaultRole: "nurse"}
+	r, err := router.New(db, zap.NewNop(), cfg, nil, v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(clinicianLookup).WithArgs("new.nurse@example.org").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}))
+	mock.ExpectQuery(`INSERT INTO clinicians .* WHERE NOT EXISTS`).
+		WithArgs("New Nurse", "new.nurse@example.org", "nurse").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(`FROM patients WHERE EXISTS .* LIMIT \$1 OFFSET \$2`).
+		WithArgs(20, 0, int64(9)).
+		WillReturnRows(sqlmock.NewRows(patientColumns))
+
+	c := idp.claims()
+	c["email"], c["name"], c["email_verified"] = "new.nurse@example.org", "New Nurse", true
+	delete(c, "roles")
+	req := httptest.NewRequest(http.MethodGet, "/v1/patients", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodRS256, "k1", k, c))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/push"
+)
+
+func TestPushAreaScore(t *testing.T) {
+	cases := []struct {
+		area float64
+		want int
+	}{
+		{0, 0},
+		{0.1, 1},
+		{0.29, 1},
+		{0.3, 2},
+		{0.6, 2},
+		{0.65, 3},
+		{1.0, 3},
+		{1.1, 4},
+		{2.0, 4},
+		{2.1, 5},
+		{3.0, 5},
+		{3.5, 6},
+		{4.0, 6},
+		{4.1, 7},
+		{8.0, 7},
+		{8.1, 8},
+		{12.0, 8},
+		{12.1, 9},
+		{24.0, 9},
+		{24.1, 10},
+		{100, 10},
+	}
+	for _, tc := range cases {
+		if got := push.AreaScore(tc.area); got != tc.want {
+			t.Errorf("AreaScore(%v) = %d, want %d", tc.area, got, tc.want)
+		}
+	}
+}
+
+func TestPushExudateScore(t *testing.T) {
+	cases := []struct {
+		amount string
+		want   int
+	}{
+		{push.ExudateNone, 0},
+		{push.ExudateLight, 1},
+		{push.ExudateModerate, 2},
+		{push.ExudateHeavy, 3},
+	}
+	for _, tc := range cases {
+		got, err := push.ExudateScore(tc.amount)
+		if err != nil || got != tc.want {
+			t.Errorf("ExudateScore(%q) = %d, %v; want %d", tc.amount, got, err, tc.want)
+		}
+	}
+	if _, err := push.ExudateScore("copious"); err == nil {
+		t.Error("ExudateScore accepted an unknown amount")
+	}
+}
+
+func TestPushTissueScore(t *testing.T) {
+	cases := []struct {
+		tissue string
+		want   int
+	}{
+		{push.TissueClosed, 0},
+		{push.TissueEpithelial, 1},
+		{push.TissueGranulation, 2},
+		{push.TissueSlough, 3},
+		{push.TissueNecrotic, 4},
+	}
+	for _, tc := range cases {
+		got, err := push.TissueScore(tc.tissue)
+		if err != nil || got != tc.want {
+			t.Errorf("TissueScore(%q) = %d, %v; want %d", tc.tissue, got, err, tc.want)
+		}
+	}
+	if _, err := push.TissueScore("eschar"); err == nil {
+		t.Error("TissueScore accepted an unknown tissue type")
+	}
+}
+
+func TestPushCompute(t *testing.T) {
+	cases := []struct {
+		name          string
+		length, width float64
+		exudate       string
+		tissue        string
+		wantArea      float64
+		wantTotal     int
+		wantAreaScore int
+		wantErr       bool
+	}{
+		{"closed wound", 0, 0, push.ExudateNone, push.TissueClosed, 0, 0, 0, false},
+		{"small granulating", 0.5, 0.5, push.ExudateLight, push.TissueGranulation, 0.25, 4, 1, false},
+		{"float rounding at band edge", 0.1, 3, push.ExudateNone, push.TissueEpithelial, 0.3, 3, 2, false},
+		{"mid-size sloughy", 2, 1.5, push.ExudateModerate, push.TissueSlough, 3, 10, 5, false},
+		{"worst case", 6, 5, push.ExudateHeavy, push.TissueNecrotic, 30, 17, 10, false},
+		{"unknown exudate", 1, 1, "copious", push.TissueSlough, 0, 0, 0, true},
+		{"negative length", -1, 1, push.ExudateNone, push.TissueSlough, 0, 0, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := push.Compute(tc.length, tc.width, tc.exudate, tc.tissue)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.AreaCm2 != tc.wantArea || got.AreaScore != tc.wantAreaScore || got.Total != tc.wantTotal {
+				t.Errorf("Compute = %+v; want area %v, area score %d, total %d", got, tc.wantArea, tc.wantAreaScore, tc.wantTotal)
+			}
+			if got.Total != got.AreaScore+got.ExudateScore+got.TissueScore {
+				t.Errorf("total %d is not the sum of sub-scores %+v", got.Total, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/ratelimit"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+)
+
+func redisStore(t *testing.T) (*ratelimit.RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return ratelimit.NewRedisStore(client, "test:"), mr
+}
+
+func TestRateLimitStores(t *testing.T) {
+	rs, _ := redisStore(t)
+	stores := map[string]ratelimit.Store{"memory": ratelimit.NewMemoryStore(), "redis": rs}
+	limit := config.RateLimit{Requests: 3, Per: time.Minute}
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take(ctx, "user:a", limit, start)
+				if err != nil || !res.Allowed || res.Remaining != i {
+					t.Fatalf("take %d = %+v, %v", 3-i, res, err)
+				}
+			}
+			res, _ := store.Take(ctx, "user:a", limit, start)
+			if res.Allowed || res.RetryAfter != 20*time.Second || res.Reset != time.Minute {
+				t.Fatalf("over limit = %+v", res)
+			}
+			if res, _ := store.Take(ctx, "user:b", limit, start); !res.Allowed {
+				t.Error("buckets are shared between clients")
+			}
+			if res, _ := store.Take(ctx, "user:a", limit, start.Add(20*time.Second)); !res.Allowed || res.Remaining != 0 {
+				t.Errorf("after refill = %+v", res)
+			}
+			if res, _ := store.Take(ctx, "user:a", limit, start.Add(time.Hour)); !res.Allowed || res.Remaining != 2 {
+				t.Errorf("refill is not capped at the burst: %+v", res)
+			}
+		})
+	}
+}
+
+func limitedEngine(t *testing.T, l *ratelimit.Limiter, p *auth.Principal) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if p != nil {
+			auth.SetPrincipal(c, p)
+		}
+	}, l.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/v1/assessments", ok)
+	r.POST("/v1/assessments", ok)
+	r.GET("/v1/patients/:id/history", ok)
+	return r
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	minute := func(n int) config.RateLimit { return config.RateLimit{Requests: n, Per: time.Minute} }
+	l := &ratelimit.Limiter{
+		Store: ratelimit.NewMemoryStore(), Log: zap.NewNop(),
+		Default: minute(3), Write: minute(1), Export: minute(1),
+		Routes:  map[string]config.RateLimit{"GET /v1/assessments": minute(2)},
+		Exports: map[string]bool{"GET /v1/patients/:id/history": true},
+	}
+	key := limitedEngine(t, l, &auth.Principal{Subject: "api-key:4", APIKeyID: 4})
+	user := limitedEngine(t, l, &auth.Principal{Subject: "user-1"})
+	anon := limitedEngine(t, l, nil)
+
+	do := func(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do(key, http.MethodGet, "/v1/assessments")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" ||
+		w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	do(key, http.MethodGet, "/v1/assessments")
+	w = do(key, http.MethodGet, "/v1/assessments")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("override limit: %d %v", w.Code, w.Header())
+	}
+
+	// Other buckets and other clients are unaffected.
+	for _, tt := range []struct {
+		r      *gin.Engine
+		method string
+		path   string
+	}{
+		{key, http.MethodPost, "/v1/assessments"},
+		{key, http.MethodGet, "/v1/patients/1/history"},
+		{user, http.MethodGet, "/v1/assessments"},
+		{anon, http.MethodGet, "/v1/assessments"},
+	} {
+		if w := do(tt.r, tt.method, tt.path); w.Code != http.StatusOK {
+			t.Errorf("%s %s: status = %d", tt.method, tt.path, w.Code)
+		}
+	}
+	if w := do(key, http.MethodPost, "/v1/assessments"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("write limit: status = %d", w.Code)
+	}
+	if w := do(key, http.MethodGet, "/v1/patients/2/history"); w.Code != http.StatusTooManyRequests || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("export limit: status = %d", w.Code)
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	rs, mr := redisStore(t)
+	mr.Close()
+	log, logs := observedLogger(nil)
+	l := &ratelimit.Limiter{Store: rs, Log: log, Default: config.RateLimit{Requests: 1, Per: time.Minute}}
+	r := limitedEngine(t, l, nil)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/assessments", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d with the store down", w.Code)
+		}
+	}
+	if logs.FilterMessage("rate limit store").Len() != 2 {
+		t.Error("store errors not logged")
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	if l, err := config.ParseRateLimit("120/1m"); err != nil || l.Requests != 120 || l.Per != time.Minute {
+		t.Errorf("120/1m = %+v, %v", l, err)
+	}
+	if l, err := config.ParseRateLimit("off"); err != nil || l.Requests != 0 {
+		t.Errorf("off = %+v, %v", l, err)
+	}
+	for _, bad := range []string{"120", "0/1m", "x/1m", "5/soon", "5/-1s"} {
+		if _, err := config.ParseRateLimit(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestRateLimitByIPBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRouter := func(proxies []string) *gin.Engine {
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		cfg := &config.Config{RateLimitIP: config.RateLimit{Requests: 2, Per: time.Minute}, TrustedProxies: proxies}
+		r, err := router.New(db, zap.NewNop(), cfg, nil, v, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// httptest requests come from 192.0.2.1; each claims a different client.
+	guess := func(r *gin.Engine, i int) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/patients", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	r := newRouter(nil)
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if got := guess(r, i); got != want {
+			t.Fatalf("untrusted X-Forwarded-For, request %d: status = %d, want %d", i+1, got, want)
+		}
+	}
+
+	r = newRouter([]string{"192.0.2.0/24"})
+	for i := 0; i < 3; i++ {
+		if got := guess(r, i); got != http.StatusUnauthorized {
+			t.Fatalf("trusted proxy, request %d: status = %d, want 401", i+1, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/rbac"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+)
+
+const clinicianLookup = `SELECT id, role FROM clinicians`
+
+// newRBACRouter builds the real router over a sqlmock database. lookup
+// configures the clinician-by-email query; handler queries after it are
+// unexpected and fail, which is fine: only 401/403 matter here.
+func newRBACRouter(t *testing.T, lookup func(sqlmock.Sqlmock)) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if lookup != nil {
+		lookup(mock)
+	}
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := router.New(db, zap.NewNop(), &config.Config{}, nil, v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func clinicianWithRole(role string) func(sqlmock.Sqlmock) {
+	return func(m sqlmock.Sqlmock) {
+		m.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, role))
+	}
+}
+
+func noClinician(m sqlmock.Sqlmock) {
+	m.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}))
+}
+
+func rbacRequest(t *testing.T, r *gin.Engine, method, path string, claims jwt.MapClaims) int {
+	t.Helper()
+	var body *strings.Reader
+	if method == http.MethodPost || method == http.MethodPut {
+		body = strings.NewReader("{}")
+	} else {
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, claims))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestPermissionMatrixCoversEveryRoute(t *testing.T) {
+	r := newRBACRouter(t, nil)
+	registered := map[string]bool{}
+	for _, rt := range r.Routes() {
+		if !strings.HasPrefix(rt.Path, "/v1/") {
+			continue
+		}
+		key := rbac.Key(rt.Method, rt.Path)
+		registered[key] = true
+		if _, ok := router.Permissions[key]; !ok {
+			t.Errorf("route %s has no permission entry", key)
+		}
+	}
+	for key := range router.Permissions {
+		if !registered[key] {
+			t.Errorf("permission entry %s matches no route", key)
+		}
+	}
+}
+
+func TestPermissionMatrixEnforced(t *testing.T) {
+	for key, allowed := range router.Permissions {
+		method, path, _ := strings.Cut(key, " ")
+		target := strings.NewReplacer(":id", "1", ":clinician_id", "2").Replace(path)
+		for _, role := range rbac.Roles {
+			want := false
+			for _, a := range allowed {
+				if a == role {
+					want = true
+				}
+			}
+			r := newRBACRouter(t, clinicianWithRole(string(role)))
+			code := rbacRequest(t, r, method, target, validClaims())
+			if code == http.StatusUnauthorized {
+				t.Fatalf("%s as %s: unexpected 401", key, role)
+			}
+			if got := code != http.StatusForbidden; got != want {
+				t.Errorf("%s as %s: status %d, allowed = %v", key, role, code, want)
+			}
+		}
+	}
+}
+
+func TestRoleResolution(t *testing.T) {
+	cases := []struct {
+		name   string
+		lookup func(sqlmock.Sqlmock)
+		claims jwt.MapClaims
+		method string
+		status int
+	}{
+		// The clinician record wins over the token claim ("nurse").
+		{"clinician admin may delete clinicians", clinicianWithRole("admin"), validClaims(), http.MethodDelete, http.StatusInternalServerError},
+		{"legacy role spelling is normalised", clinicianWithRole("Admin"), validClaims(), http.MethodDelete, http.StatusInternalServerError},
+		{"clinician without role is denied", clinicianWithRole(""), validClaims(), http.MethodGet, http.StatusForbidden},
+		{"unknown clinician falls back to token role", noClinician, withClaim("roles", []string{"auditor"}), http.MethodGet, http.StatusInternalServerError},
+		{"token auditor may not write", noClinician, withClaim("roles", []string{"auditor"}), http.MethodDelete, http.StatusForbidden},
+		{"no role anywhere is denied", noClinician, withClaim("roles", nil), http.MethodGet, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newRBACRouter(t, tc.lookup)
+			if code := rbacRequest(t, r, tc.method, "/v1/clinicians/1", tc.claims); code != tc.status {
+				t.Errorf("status = %d, want %d", code, tc.status)
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	cases := map[string]rbac.Role{
+		"admin":            rbac.Admin,
+		" Physician ":      rbac.Physician,
+		"Wound Specialist": rbac.WoundSpecialist,
+		"wound-specialist": rbac.WoundSpecialist,
+		"AUDITOR":          rbac.Auditor,
+	}
+	for in, want := range cases {
+		if got, ok := rbac.ParseRole(in); !ok || got != want {
+			t.Errorf("ParseRole(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "superuser", "doctor"} {
+		if _, ok := rbac.ParseRole(in); ok {
+			t.Errorf("ParseRole(%q) accepted", in)
+		}
+	}
+}
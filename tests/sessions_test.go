@@ -0,0 +1,317 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/auth"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/config"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/router"
+	"github.com/vellalasantosh/wound_iq_api_new/internal/session"
+)
+
+const (
+	revocationQuery = `SELECT id, revoked_at FROM sessions\s+WHERE revoked_at > now\(\)`
+	refreshQuery    = `SELECT rt.id, rt.session_id, rt.used_at IS NOT NULL,.*WHERE rt.token_hash = \$1\s+FOR UPDATE OF rt`
+)
+
+var refreshColumns = []string{"id", "session_id", "spent", "live", "user_id", "email", "full_name", "auth_methods"}
+
+func expectSessionStart(mock sqlmock.Sqlmock, userID, sessionID int64) {
+	mock.ExpectQuery(`WITH s AS \(\s+INSERT INTO sessions`).
+		WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(86400), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "expires_at"}).AddRow(sessionID, time.Now().Add(24*time.Hour)))
+}
+
+func expectRevocationLoad(mock sqlmock.Sqlmock, revoked ...int64) {
+	rows := sqlmock.NewRows([]string{"id", "revoked_at"})
+	for _, id := range revoked {
+		rows.AddRow(id, time.Now())
+	}
+	mock.ExpectQuery(revocationQuery).WillReturnRows(rows)
+}
+
+// sessionToken is an access token for local user 3 in session sid.
+func sessionToken(t *testing.T, sid int64) string {
+	c := validClaims()
+	c["sub"], c["sid"], c[auth.LocalAccountClaim] = "user:3", sid, true
+	return signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, c)
+}
+
+func serveWithToken(r *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(refreshQuery).WillReturnRows(sqlmock.NewRows(refreshColumns).
+		AddRow(40, 12, false, true, 3, "nurse@example.org", "Nina Nurse", "pwd otp"))
+	mock.ExpectExec(`UPDATE refresh_tokens SET used_at = now\(\) WHERE id = \$1`).WithArgs(int64(40)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO refresh_tokens .* FROM sessions WHERE id = \$2`).WithArgs(int64(3), int64(12), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(time.Now().Add(time.Hour)))
+	mock.ExpectExec(`UPDATE sessions SET last_used_at = now\(\)`).WithArgs(int64(12)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	old := strings.Repeat("a", 64)
+	w := postAs(r, "/v1/auth/refresh", "", `{"refresh_token":"`+old+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &out)
+	if out.RefreshToken == "" || out.RefreshToken == old {
+		t.Errorf("refresh token not rotated: %s", w.Body)
+	}
+	v, _ := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	p, err := v.Verify(context.Background(), out.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "user:3" || p.SessionID != 12 || p.Email != "nurse@example.org" {
+		t.Errorf("principal = %+v", p)
+	}
+	// The session was started with a TOTP code; refreshing keeps it in amr.
+	if amr, _ := json.Marshal(p.Claims["amr"]); string(amr) != `["pwd","otp"]` {
+		t.Errorf("amr = %s, want [\"pwd\",\"otp\"]", amr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(refreshQuery).WillReturnRows(sqlmock.NewRows(refreshColumns).
+		AddRow(40, 12, true, true, 3, "nurse@example.org", "Nina Nurse", "pwd"))
+	mock.ExpectQuery(`UPDATE sessions SET revoked_at = now\(\)`).WithArgs("refresh token reuse", int64(12)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectCommit()
+	if w := postAs(r, "/v1/auth/refresh", "", `{"refresh_token":"spent"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reuse: status = %d: %s", w.Code, w.Body)
+	}
+
+	// The session's access tokens stop working at once, and the check
+	// is served from the cache after its first load.
+	expectRevocationLoad(mock)
+	for i := 0; i < 2; i++ {
+		w := serveWithToken(r, http.MethodGet, "/v1/auth/sessions", sessionToken(t, 12))
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "session has been revoked") {
+			t.Fatalf("revoked session: status = %d: %s", w.Code, w.Body)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSessionListAndLogout(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	expectRevocationLoad(mock)
+	mock.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`FROM sessions\s+WHERE user_id = \$1 AND revoked_at IS NULL`).WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_agent", "ip_address", "created_at", "last_used_at", "expires_at"}).
+			AddRow(12, "Firefox", "192.0.2.1", time.Now(), time.Now(), time.Now().Add(time.Hour)).
+			AddRow(13, "iPad", "192.0.2.9", time.Now(), time.Now(), time.Now().Add(time.Hour)))
+	w := serveWithToken(r, http.MethodGet, "/v1/auth/sessions", sessionToken(t, 12))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", w.Code, w.Body)
+	}
+	var list struct {
+		Data []struct {
+			ID      int64 `json:"id"`
+			Current bool  `json:"current"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Data) != 2 || !list.Data[0].Current || list.Data[1].Current {
+		t.Errorf("sessions = %s", w.Body)
+	}
+
+	mock.ExpectQuery(clinicianLookup).WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(7, "nurse"))
+	mock.ExpectQuery(`UPDATE sessions SET revoked_at = now\(\)`).WithArgs("logout everywhere", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12).AddRow(13))
+	if w := postAs(r, "/v1/auth/logout-all", sessionToken(t, 12), ``); w.Code != http.StatusNoContent {
+		t.Fatalf("logout-all: status = %d: %s", w.Code, w.Body)
+	}
+	for _, sid := range []int64{12, 13} {
+		if w := serveWithToken(r, http.MethodGet, "/v1/auth/sessions", sessionToken(t, sid)); w.Code != http.StatusUnauthorized {
+			t.Errorf("session %d after logout-all: status = %d", sid, w.Code)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteClinicianRevokesSessions(t *testing.T) {
+	r, mock := scopedRouterWithConfig(t, "admin", &config.Config{AccessTokenTTL: 15 * time.Minute, SessionRevocationRefresh: time.Minute})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE sessions SET revoked_at = now\(\).*user_id IN \(SELECT id FROM user_accounts WHERE clinician_id = \$2\)`).
+		WithArgs("clinician deleted", "5").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectExec(`INSERT INTO disabled_identities .* FROM clinicians WHERE id = \$1`).WithArgs("5", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM clinicians WHERE id = \$1`).WithArgs("5").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if w := serveAs(t, r, http.MethodDelete, "/v1/clinicians/5"); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d: %s", w.Code, w.Body)
+	}
+
+	// Even if this instance's first load does not see the revocation yet,
+	// the local entry holds.
+	expectRevocationLoad(mock)
+	if w := serveWithToken(r, http.MethodGet, "/v1/patients", sessionToken(t, 21)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("deleted clinician's session: status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLocalTokenWithoutSessionIsRefused(t *testing.T) {
+	r, mock := localAuthRouter(t)
+	c := validClaims()
+	c["sub"], c[auth.LocalAccountClaim] = "user:3", true
+	w := serveWithToken(r, http.MethodGet, "/v1/patients", signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, c))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "access token has no session") {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeletedClinicianIsRefused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	v, err := auth.NewVerifier(auth.Options{HS256Secret: testHS256Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither the roles claim nor auto-provisioning lets them back in.
+	r, err := router.New(db, zap.NewNop(), &config.Config{OIDCAutoProvision: true, OIDCDefaultRole: "nurse"}, nil, v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(clinicianLookup + `.* UNION ALL SELECT NULL, NULL FROM disabled_identities`).WithArgs("nurse@example.org").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(nil, nil))
+
+	claims := validClaims()
+	claims["email"], claims["roles"] = "nurse@example.org", []string{"nurse"}
+	req := httptest.NewRequest(http.MethodGet, "/v1/patients", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, "", testHS256Secret, claims))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRevocationsReloadOncePerRefresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	revs := session.NewRevocations(db, zap.NewNop(), 15*time.Minute, time.Minute)
+	expectRevocationLoad(mock, 4)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if !revs.Revoked(ctx, 4) || revs.Revoked(ctx, 5) {
+			t.Fatal("wrong revocation state")
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	// Within the refresh interval the database is not asked again.
+	expectRevocationLoad(mock, 5)
+	if revs.Revoked(ctx, 5) {
+		t.Error("reloaded before the refresh interval")
+	}
+	if mock.ExpectationsWereMet() == nil {
+		t.Error("revocations reloaded before the refresh interval")
+	}
+}
+
+func TestRevocationsFailedReloadBacksOff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	revs := session.NewRevocations(db, zap.NewNop(), 15*time.Minute, time.Minute)
+	revs.Add(4)
+	mock.ExpectQuery(revocationQuery).WillReturnError(errors.New("connection refused"))
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if !revs.Revoked(ctx, 4) {
+			t.Fatal("lost the last loaded list after a failed reload")
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevocationsReloadDoesNotBlockOtherCallers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	revs := session.NewRevocations(db, zap.NewNop(), 15*time.Minute, time.Minute)
+	revs.Add(4)
+	mock.ExpectQuery(revocationQuery).WillDelayFor(500 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "revoked_at"}).AddRow(5, time.Now()))
+
+	ctx := context.Background()
+	done := make(chan bool)
+	go func() { done <- revs.Revoked(ctx, 5) }()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if !revs.Revoked(ctx, 4) {
+		t.Error("session 4 not revoked during the reload")
+	}
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("caller waited %v for another caller's reload", d)
+	}
+	if !<-done {
+		t.Error("session 5 not revoked after the reload")
+	}
+	if !revs.Revoked(ctx, 4) {
+		t.Error("reload dropped a locally added revocation")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
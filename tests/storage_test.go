@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vellalasantosh/wound_iq_api_new/internal/storage"
+)
+
+func exerciseStore(t *testing.T, s storage.Store) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Put(ctx, "assessments/1/abc.jpg", []byte("jpeg-bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := s.Get(ctx, "assessments/1/abc.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "jpeg-bytes" {
+		t.Errorf("Get = %q", got)
+	}
+	if err := s.Delete(ctx, "assessments/1/abc.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "assessments/1/abc.jpg"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get after delete: err = %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"../etc/passwd", "/abs", "a//b", "a/./b", ""} {
+		if err := s.Put(ctx, key, nil, ""); !errors.Is(err, storage.ErrInvalidKey) {
+			t.Errorf("Put(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	s, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseStore(t, s)
+}
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible service. It
+// checks that every request is SigV4-signed for the expected credentials
+// and that the declared payload hash matches the body.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "Signature=") || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		obj, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(obj)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := storage.NewS3(storage.S3Options{
+		Endpoint:        srv.URL,
+		Region:          "eu-west-1",
+		Bucket:          "wound-images",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseStore(t, s)
+
+	if err := s.Put(context.Background(), "assessments/2/x.png", []byte("png"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["/wound-images/assessments/2/x.png"]; !ok {
+		t.Errorf("object not stored under path-style key, have %v", fake.objects)
+	}
+}
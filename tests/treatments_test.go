@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateTreatmentChecksWoundPatient(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		assessWith interface{} // the assessment's wound, if any
+		owner      interface{} // patient of wound 9, nil when it does not exist
+		want       int
+	}{
+		{"wound of another patient", `{"wound_id":9,"primary_dressing":"foam"}`, nil, 6, http.StatusBadRequest},
+		{"unknown wound", `{"wound_id":9,"primary_dressing":"foam"}`, nil, nil, http.StatusBadRequest},
+		{"not the assessment's wound", `{"wound_id":9,"primary_dressing":"foam"}`, 4, 5, http.StatusBadRequest},
+		{"patient's wound", `{"wound_id":9,"primary_dressing":"foam"}`, nil, 5, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "physician")
+			mock.ExpectQuery(`SELECT id, patient_id, wound_id FROM assessments WHERE id = \$1 AND`).WithArgs("3", int64(7)).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "patient_id", "wound_id"}).AddRow(3, 5, tt.assessWith))
+			if tt.assessWith == nil {
+				owner := sqlmock.NewRows([]string{"patient_id"})
+				if tt.owner != nil {
+					owner.AddRow(tt.owner)
+				}
+				mock.ExpectQuery(`SELECT patient_id FROM wounds WHERE id = \$1`).WithArgs(int64(9)).WillReturnRows(owner)
+			}
+			if tt.want == http.StatusCreated {
+				mock.ExpectQuery(`INSERT INTO treatments`).
+					WithArgs(int64(3), int64(9), "", "foam", "", "", "", "active", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(50, time.Now(), time.Now()))
+			}
+
+			if w := sendJSON(t, r, http.MethodPost, "/v1/assessments/3/treatments", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateWound(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"created", `{"patient_id":5,"anatomical_location":"sacrum"}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(5))
+			m.ExpectQuery(`INSERT INTO wounds`).
+				WithArgs(int64(5), "sacrum", "", nil, false, "open").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		}, http.StatusCreated},
+		{"unknown patient", `{"patient_id":404,"anatomical_location":"sacrum"}`, func(m sqlmock.Sqlmock) {
+			m.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM patients p WHERE p.id = \$1`).WithArgs(int64(404), int64(7)).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			m.ExpectQuery(`SELECT restricted FROM patients WHERE id = \$1`).WithArgs(int64(404)).
+				WillReturnRows(sqlmock.NewRows([]string{"restricted"}))
+		}, http.StatusNotFound},
+		{"location required", `{"patient_id":5}`, nil, http.StatusBadRequest},
+		{"bad onset", `{"patient_id":5,"anatomical_location":"sacrum","onset_date":"last week"}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			if w := sendJSON(t, r, http.MethodPost, "/v1/wounds", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUpdateAssessmentWoundLink(t *testing.T) {
+	current := func(m sqlmock.Sqlmock, wound interface{}) {
+		m.ExpectQuery(`SELECT patient_id, wound_id FROM assessments WHERE id = \$1`).WithArgs("9").
+			WillReturnRows(sqlmock.NewRows([]string{"patient_id", "wound_id"}).AddRow(5, wound))
+	}
+	owner := func(m sqlmock.Sqlmock, wound, patient int64) {
+		m.ExpectQuery(`SELECT patient_id FROM wounds WHERE id = \$1`).WithArgs(wound).
+			WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(patient))
+	}
+	update := func(m sqlmock.Sqlmock, patient, wound interface{}) {
+		m.ExpectExec(`UPDATE assessments SET`).WithArgs(patient, nil, wound, nil, nil, nil, "9", nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	tests := []struct {
+		name   string
+		body   string
+		expect func(sqlmock.Sqlmock)
+		want   int
+	}{
+		{"wound only", `{"wound_id":12}`, func(m sqlmock.Sqlmock) {
+			current(m, 9)
+			owner(m, 12, 5)
+			update(m, nil, int64(12))
+		}, http.StatusNoContent},
+		{"wound only, another patient's", `{"wound_id":12}`, func(m sqlmock.Sqlmock) {
+			current(m, 9)
+			owner(m, 12, 6)
+		}, http.StatusBadRequest},
+		{"patient only, no wound", `{"patient_id":6}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(6))
+			expectEnrolment(m, int64(6), false)
+			current(m, nil)
+			update(m, int64(6), nil)
+		}, http.StatusNoContent},
+		{"patient only, wound stays with the old patient", `{"patient_id":6}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(6))
+			expectEnrolment(m, int64(6), false)
+			current(m, 9)
+			owner(m, 9, 5)
+		}, http.StatusBadRequest},
+		{"mismatched pair", `{"patient_id":6,"wound_id":12}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(6))
+			expectEnrolment(m, int64(6), false)
+			current(m, 9)
+			owner(m, 12, 5)
+		}, http.StatusBadRequest},
+		{"matching pair", `{"patient_id":6,"wound_id":12}`, func(m sqlmock.Sqlmock) {
+			expectPatientVisible(m, int64(6))
+			expectEnrolment(m, int64(6), false)
+			current(m, 9)
+			owner(m, 12, 6)
+			update(m, int64(6), int64(12))
+		}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := scopedRouter(t, "nurse")
+			mock.ExpectQuery(`SELECT a.patient_id FROM assessments a WHERE a.id = \$1 AND`).WithArgs("9", int64(7)).
+				WillReturnRows(sqlmock.NewRows([]string{"patient_id"}).AddRow(5))
+			tt.expect(mock)
+			if w := sendJSON(t, r, http.MethodPut, "/v1/assessments/9", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}